@@ -3,13 +3,19 @@ package main
 import (
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/pressly/goose/v3"
 	"github.com/ramanasai/local-game-play/config"
+	"github.com/ramanasai/local-game-play/internal/achievements"
+	"github.com/ramanasai/local-game-play/internal/admin"
 	"github.com/ramanasai/local-game-play/internal/auth"
 	"github.com/ramanasai/local-game-play/internal/db"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
 	"github.com/ramanasai/local-game-play/internal/games/blockblast"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
 	"github.com/ramanasai/local-game-play/internal/games/game2048"
 	"github.com/ramanasai/local-game-play/internal/games/memory"
 	"github.com/ramanasai/local-game-play/internal/games/tictactoe"
@@ -17,6 +23,7 @@ import (
 	"github.com/ramanasai/local-game-play/internal/http/handlers"
 	"github.com/ramanasai/local-game-play/internal/http/middleware"
 	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
 	"github.com/ramanasai/local-game-play/migrations"
 	"github.com/ramanasai/local-game-play/pkg/logger"
 	"github.com/rs/zerolog/log"
@@ -57,26 +64,107 @@ func main() {
 	matchRepo := repos.NewMatchRepo(database)
 	game2048Repo := repos.NewGame2048Repo(database)
 	blockBlastRepo := repos.NewBlockBlastRepo(database)
+	loginAttemptRepo := repos.NewLoginAttemptRepo(database)
+	pinResetRepo := repos.NewPinResetRepo(database)
+	auditRepo := repos.NewAuditRepo(database)
+	resultRepo := repos.NewResultRepo(database)
+	seasonRepo := repos.NewSeasonRepo(database)
+	achievementRepo := repos.NewAchievementRepo(database)
+
+	// Signing keys
+	keyring, err := auth.LoadKeyring(cfg.JWTKeyFile, cfg.JWTSecret, cfg.JWTKeyRotation)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load JWT signing keys")
+	}
+	if err := keyring.RotateIfDue(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to rotate JWT signing key")
+	}
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := keyring.RotateIfDue(); err != nil {
+				log.Error().Err(err).Msg("Failed to rotate JWT signing key")
+			}
+		}
+	}()
+
+	// PIN hashing
+	argon2Params := auth.DefaultArgon2Params
+	argon2Params.Memory, argon2Params.Time, argon2Params.Threads = cfg.Argon2MemoryKiB, cfg.Argon2Time, cfg.Argon2Threads
+	pinHashing, err := auth.PINHashingFor(cfg.PINHashAlgorithm, argon2Params, cfg.BcryptCost)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid PIN hashing configuration")
+	}
+
+	// Daily challenges
+	dailySecret, err := daily.LoadSecret(cfg.DailySecretFile, cfg.DailySecret)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load daily challenge secret")
+	}
+	dailySeeder := daily.NewSeeder(dailySecret)
+
+	usernamePolicy := usernames.NewPolicy(cfg.ReservedUsernames, cfg.BlockedUsernameWords)
 
 	// Services
-	authService := auth.NewAuthService(userRepo, sessionRepo)
-	memService := memory.NewService(scoreRepo)
-	tttService := tictactoe.NewService(matchRepo)
-	game2048Service := game2048.NewService(game2048Repo)
-	blockBlastService := blockblast.NewService(blockBlastRepo)
+	authService := auth.NewAuthService(userRepo, sessionRepo, pinResetRepo, pinHashing, auth.NewLoginGuard(loginAttemptRepo), keyring, usernamePolicy)
+	resultEvents := games.NewEvents()
+	memService := memory.NewService(scoreRepo, dailySeeder, resultEvents)
+	tttService := tictactoe.NewService(matchRepo, resultEvents)
+	game2048Service := game2048.NewService(game2048Repo, dailySeeder, resultEvents)
+	blockBlastService := blockblast.NewService(blockBlastRepo, dailySeeder, resultEvents)
+	registry := games.NewRegistry(memService, tttService, game2048Service, blockBlastService)
+	gamesService := games.NewService(registry, resultRepo, seasonRepo)
+	adminService := admin.NewService(userRepo, auditRepo, authService, usernamePolicy)
+	achievementsService, err := achievements.NewService(achievementRepo, registry, achievements.Rules)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid achievement rules")
+	}
+	achievementsService.Subscribe(resultEvents)
+
+	if err := adminService.Bootstrap(cfg.AdminUsernames); err != nil {
+		log.Fatal().Err(err).Msg("Failed to promote configured admins")
+	}
+
+	seasons := make([]domain.Season, 0, len(cfg.Seasons))
+	for _, season := range cfg.Seasons {
+		seasons = append(seasons, domain.Season{Name: season.Name, StartsAt: season.Start, EndsAt: season.End})
+	}
+	if err := gamesService.SyncSeasons(seasons); err != nil {
+		log.Fatal().Err(err).Msg("Failed to sync configured seasons")
+	}
+	if err := gamesService.StartSeasonArchiver(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to archive ended seasons")
+	}
+	if err := achievementsService.Backfill(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to backfill achievements")
+	}
+
+	// Build the Tic-Tac-Toe solution table up front so the first hard move
+	// doesn't wait for it.
+	go tictactoe.Solution()
 
 	// Middleware
 	authMw := middleware.NewAuthMiddleware(authService)
 
 	// Handlers
-	userHandler := handlers.NewUserHandler(authService)
-	memHandler := handlers.NewMemoryHandler(memService)
-	tttHandler := handlers.NewTicTacToeHandler(tttService)
-	game2048Handler := handlers.NewGame2048Handler(game2048Service)
-	blockBlastHandler := handlers.NewBlockBlastHandler(blockBlastService)
+	routes := internalHttp.Handlers{
+		User:         handlers.NewUserHandler(authService),
+		Games:        handlers.NewGamesHandler(gamesService),
+		Memory:       handlers.NewMemoryHandler(memService),
+		TicTacToe:    handlers.NewTicTacToeHandler(tttService),
+		Game2048:     handlers.NewGame2048Handler(game2048Service),
+		BlockBlast:   handlers.NewBlockBlastHandler(blockBlastService),
+		Admin:        handlers.NewAdminHandler(adminService),
+		Achievements: handlers.NewAchievementsHandler(achievementsService),
+	}
+
+	// Tic-Tac-Toe PvP hub
+	pvpHub := internalHttp.NewHub(tttService, []string{cfg.CORSOrigin, "http://localhost:5173", "http://localhost:4173"})
+	if err := pvpHub.Start(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to start PvP hub")
+	}
 
 	// Router
-	r := internalHttp.NewRouter(cfg, userHandler, memHandler, tttHandler, game2048Handler, blockBlastHandler, authMw)
+	r := internalHttp.NewRouter(cfg, routes, pvpHub, authMw)
 
 	log.Info().Str("port", cfg.Port).Msg("Server starting")
 	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -10,6 +15,49 @@ type Config struct {
 	Port       string
 	DBPath     string
 	CORSOrigin string
+
+	// TrustedProxies are the addresses allowed to say which client they
+	// forward a request for. Requests from anywhere else are attributed to
+	// their own address.
+	TrustedProxies []*net.IPNet
+
+	// JWTSecret pins the signing key. When empty a key is generated and
+	// kept in JWTKeyFile, and replaced every JWTKeyRotation.
+	JWTSecret      string
+	JWTKeyFile     string
+	JWTKeyRotation time.Duration
+
+	// AdminUsernames are promoted to admin at startup while no admin exists.
+	AdminUsernames []string
+
+	// ReservedUsernames can't be registered, on top of the built-in list;
+	// an entry ending in "*" reserves a prefix. Names containing any of
+	// BlockedUsernameWords are refused.
+	ReservedUsernames    []string
+	BlockedUsernameWords []string
+
+	// PINHashAlgorithm is argon2id or bcrypt; the cost settings apply to
+	// newly hashed PINs.
+	PINHashAlgorithm string
+	Argon2MemoryKiB  uint32
+	Argon2Time       uint32
+	Argon2Threads    uint8
+	BcryptCost       int
+
+	// Seasons are the named leaderboard seasons, from SEASONS.
+	Seasons []Season
+
+	// DailySecret seeds the daily challenges. When empty one is generated
+	// and kept in DailySecretFile.
+	DailySecret     string
+	DailySecretFile string
+}
+
+// Season is one configured leaderboard season. End is exclusive.
+type Season struct {
+	Name  string
+	Start time.Time
+	End   time.Time
 }
 
 func Load() *Config {
@@ -17,11 +65,117 @@ func Load() *Config {
 	log.Info().Str("port", getEnv("PORT", "8080")).Msg("Port")
 	log.Info().Str("db_path", getEnv("DB_PATH", "./data/local_games.db")).Msg("DB Path")
 	log.Info().Str("cors_origin", getEnv("CORS_ORIGIN", "http://localhost:5173")).Msg("CORS Origin")
+	dbPath := getEnv("DB_PATH", "./data/local_games.db")
+	rotation, err := time.ParseDuration(getEnv("JWT_KEY_ROTATION", "720h"))
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid JWT_KEY_ROTATION, using 720h")
+		rotation = 720 * time.Hour
+	}
 	return &Config{
 		Port:       getEnv("PORT", "8080"),
-		DBPath:     getEnv("DB_PATH", "./data/local_games.db"),
+		DBPath:     dbPath,
 		CORSOrigin: getEnv("CORS_ORIGIN", "http://localhost:5173"),
+
+		TrustedProxies: parseNetworks(getEnv("TRUSTED_PROXIES", "")),
+		// Read directly: getEnv logs the values it finds.
+		JWTSecret:      os.Getenv("JWT_SECRET"),
+		JWTKeyFile:     getEnv("JWT_KEY_FILE", filepath.Join(filepath.Dir(dbPath), "jwt_keys.json")),
+		JWTKeyRotation: rotation,
+		AdminUsernames: splitList(getEnv("ADMIN_USERNAMES", "")),
+
+		ReservedUsernames:    splitList(getEnv("RESERVED_USERNAMES", "")),
+		BlockedUsernameWords: splitList(getEnv("BLOCKED_USERNAME_WORDS", "")),
+
+		PINHashAlgorithm: getEnv("PIN_HASH_ALGORITHM", "argon2id"),
+		Argon2MemoryKiB:  uint32(getEnvInt("ARGON2_MEMORY_KIB", 19*1024)),
+		Argon2Time:       uint32(getEnvInt("ARGON2_TIME", 2)),
+		Argon2Threads:    uint8(getEnvInt("ARGON2_THREADS", 1)),
+		BcryptCost:       getEnvInt("BCRYPT_COST", 10),
+
+		Seasons: parseSeasons(getEnv("SEASONS", "")),
+
+		DailySecret:     os.Getenv("DAILY_SECRET"),
+		DailySecretFile: getEnv("DAILY_SECRET_FILE", filepath.Join(filepath.Dir(dbPath), "daily_secret")),
+	}
+}
+
+// parseSeasons reads "Name=start/end" entries separated by ";", e.g.
+// "Spring 2026=2026-03-01/2026-06-01". Dates are YYYY-MM-DD, meaning
+// midnight UTC, or RFC 3339. Malformed entries are logged and skipped.
+func parseSeasons(s string) []Season {
+	var out []Season
+	for _, entry := range strings.Split(s, ";") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, span, _ := strings.Cut(entry, "=")
+		rawStart, rawEnd, _ := strings.Cut(span, "/")
+		start, errStart := parseDate(rawStart)
+		end, errEnd := parseDate(rawEnd)
+		name = strings.TrimSpace(name)
+		if name == "" || errStart != nil || errEnd != nil || !end.After(start) {
+			log.Warn().Str("season", entry).Msg("Invalid season, expected Name=start/end with end after start; skipping")
+			continue
+		}
+		out = append(out, Season{Name: name, Start: start, End: end})
+	}
+	return out
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err
+}
+
+// parseNetworks reads a comma-separated list of CIDR ranges or single IP
+// addresses. Malformed entries are logged and skipped.
+func parseNetworks(s string) []*net.IPNet {
+	var out []*net.IPNet
+	for _, entry := range splitList(s) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Warn().Str("proxy", entry).Msg("Invalid trusted proxy, expected an IP or CIDR range; skipping")
+			continue
+		}
+		out = append(out, network)
+	}
+	return out
+}
+
+// getEnvInt reads a non-negative integer, falling back on anything else.
+func getEnvInt(key string, fallback int) int {
+	raw := getEnv(key, strconv.Itoa(fallback))
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Warn().Str("key", key).Str("value", raw).Int("fallback", fallback).Msg("Invalid integer, using fallback")
+		return fallback
+	}
+	return n
+}
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
 
 func getEnv(key, fallback string) string {
@@ -3,6 +3,7 @@ module github.com/ramanasai/local-game-play
 go 1.24.1
 
 require (
+	github.com/coder/websocket v1.8.14
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/go-chi/cors v1.2.2
 	github.com/golang-jwt/jwt/v5 v5.3.0
@@ -11,6 +12,7 @@ require (
 	github.com/pressly/goose/v3 v3.26.0
 	github.com/rs/zerolog v1.34.0
 	golang.org/x/crypto v0.40.0
+	golang.org/x/text v0.27.0
 	modernc.org/sqlite v1.41.0
 )
 
@@ -0,0 +1,43 @@
+package achievements
+
+import "github.com/ramanasai/local-game-play/internal/domain"
+
+// Rules are the achievements on offer. Adding one here is enough: at the
+// next start it is unlocked for everyone whose past results already meet
+// it. Never reuse or repurpose an ID; see domain.AchievementRule.
+var Rules = []domain.AchievementRule{
+	{
+		ID:          "2048-reach-2048",
+		Name:        "2048!",
+		Description: "Reach the 2048 tile",
+		Game:        "2048",
+		Where:       []domain.Condition{{Field: "max_tile", Op: ">=", Value: 2048}},
+		RankedOnly:  true,
+	},
+	{
+		ID:          "tictactoe-beat-players-10",
+		Name:        "Table Champion",
+		Description: "Beat other players at Tic-Tac-Toe 10 times",
+		Game:        "tictactoe",
+		Where: []domain.Condition{
+			{Field: "difficulty", Op: "=", Value: "pvp"},
+			{Field: "result", Op: "=", Value: "win"},
+		},
+		Count:      10,
+		RankedOnly: true,
+	},
+	{
+		ID:          "memory-under-30s",
+		Name:        "Photographic",
+		Description: "Clear a Memory board in under 30 seconds",
+		Game:        "memory",
+		Where:       []domain.Condition{{Field: "time_seconds", Op: "<", Value: 30}},
+		RankedOnly:  true,
+	},
+	{
+		ID:              "every-game-one-day",
+		Name:            "Game Night",
+		Description:     "Play every game on the same day (UTC)",
+		EveryGameInADay: true,
+	},
+}
@@ -0,0 +1,138 @@
+// Package achievements unlocks achievements as players' results come in.
+// Rules are declarative, see Rules; whether a player meets one is worked
+// out from the shared results store, so new rules can be backfilled from
+// history the same way they are checked live.
+package achievements
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/rs/zerolog/log"
+)
+
+type Service struct {
+	repo     *repos.AchievementRepo
+	registry *games.Registry
+	rules    []domain.AchievementRule
+	gameIDs  []string
+}
+
+// NewService checks rules against the registered games. A rule that can't
+// work is a programming mistake, reported here rather than on first use.
+func NewService(repo *repos.AchievementRepo, registry *games.Registry, rules []domain.AchievementRule) (*Service, error) {
+	s := &Service{repo: repo, rules: rules, registry: registry}
+	for _, g := range registry.All() {
+		s.gameIDs = append(s.gameIDs, g.ID())
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if rule.ID == "" || seen[rule.ID] {
+			return nil, fmt.Errorf("achievement %q: ID missing or used twice", rule.ID)
+		}
+		seen[rule.ID] = true
+		if err := s.check(rule); err != nil {
+			return nil, fmt.Errorf("achievement %s: %w", rule.ID, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Service) check(rule domain.AchievementRule) error {
+	if rule.EveryGameInADay && (rule.Game != "" || len(rule.Where) > 0 || rule.Count > 1) {
+		return fmt.Errorf("every-game rules take no game, conditions or count")
+	}
+	var fields []string
+	if rule.Game != "" {
+		g, err := s.registry.Get(rule.Game)
+		if err != nil {
+			return err
+		}
+		for _, f := range g.Schema() {
+			fields = append(fields, f.Name)
+		}
+	}
+	for _, c := range rule.Where {
+		if c.Field != "score" && c.Field != "tie_break" && !slices.Contains(fields, c.Field) {
+			return fmt.Errorf("unknown field %q", c.Field)
+		}
+		switch c.Op {
+		case "=", "!=", "<", "<=", ">", ">=":
+		default:
+			return fmt.Errorf("unknown operator %q", c.Op)
+		}
+	}
+	return nil
+}
+
+// Subscribe checks every result recorded from now on.
+func (s *Service) Subscribe(events *games.Events) {
+	events.Subscribe(s.OnResult)
+}
+
+// OnResult unlocks whatever result completed for its player. Failures are
+// logged rather than returned: the result is stored either way, and the
+// next one checks again.
+func (s *Service) OnResult(result *domain.GameResult) {
+	for _, rule := range s.rules {
+		if rule.Game != "" && rule.Game != result.Game {
+			continue
+		}
+		unlocked, err := s.repo.Unlock(rule, s.gameIDs, result.UserID)
+		if err != nil {
+			log.Error().Err(err).Str("achievement", rule.ID).Str("user_id", result.UserID).Msg("Achievements Service: Failed to check achievement")
+			continue
+		}
+		if unlocked {
+			log.Info().Str("achievement", rule.ID).Str("user_id", result.UserID).Msg("Achievements Service: Achievement unlocked")
+		}
+	}
+}
+
+// Backfill unlocks each rule that hasn't been backfilled yet for everyone
+// whose existing results meet it. Results recorded while it runs are also
+// checked by OnResult, and unlocking twice is harmless.
+func (s *Service) Backfill() error {
+	done, err := s.repo.Backfilled()
+	if err != nil {
+		return err
+	}
+	for _, rule := range s.rules {
+		if done[rule.ID] {
+			continue
+		}
+		n, err := s.repo.Backfill(rule, s.gameIDs)
+		if err != nil {
+			return err
+		}
+		log.Info().Str("achievement", rule.ID).Int64("unlocked", n).Msg("Achievements Service: Backfilled achievement")
+	}
+	return nil
+}
+
+// ForUser lists every achievement, with when userID unlocked those they
+// have. Unlocks of rules that have since been removed are left out.
+func (s *Service) ForUser(userID string) ([]domain.Achievement, error) {
+	unlocks, err := s.repo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	unlockedAt := map[string]domain.Unlock{}
+	for _, u := range unlocks {
+		unlockedAt[u.AchievementID] = *u
+	}
+
+	list := make([]domain.Achievement, 0, len(s.rules))
+	for _, rule := range s.rules {
+		a := domain.Achievement{AchievementRule: rule}
+		if u, ok := unlockedAt[rule.ID]; ok {
+			a.UnlockedAt = &u.UnlockedAt
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/auth"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions written to the audit trail.
+const (
+	ActionRename    = "rename"
+	ActionPINReset  = "pin_reset"
+	ActionSuspend   = "suspend"
+	ActionUnsuspend = "unsuspend"
+	ActionSetRole   = "set_role"
+	ActionSetParent = "set_parent"
+	ActionDelete    = "delete"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrSelf          = errors.New("admins can't do this to their own account")
+	ErrInvalidRole   = errors.New("role must be player or admin")
+	ErrInvalidParent = errors.New("parent must be another existing user")
+)
+
+// systemAdmin is the actor recorded for changes made from configuration
+// rather than by a signed-in admin.
+var systemAdmin = &domain.User{ID: "system", Username: "system"}
+
+// Service carries out user administration. Every change is written to the
+// audit trail in the same transaction as the change itself, so a change
+// whose audit entry can't be written doesn't happen.
+type Service struct {
+	userRepo  *repos.UserRepo
+	auditRepo *repos.AuditRepo
+	auth      *auth.AuthService
+	names     *usernames.Policy
+}
+
+func NewService(userRepo *repos.UserRepo, auditRepo *repos.AuditRepo, authService *auth.AuthService, names *usernames.Policy) *Service {
+	return &Service{userRepo: userRepo, auditRepo: auditRepo, auth: authService, names: names}
+}
+
+// UserPage is one page of a user search.
+type UserPage struct {
+	Users  []*domain.User `json:"users"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+func (s *Service) SearchUsers(query string, limit, offset int) (*UserPage, error) {
+	users, total, err := s.userRepo.Search(strings.TrimSpace(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{Users: users, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+func (s *Service) GetUser(id string) (*domain.User, error) {
+	return s.lookup(id)
+}
+
+// Rename gives a user a new username, which must pass the same policy as
+// names people pick themselves.
+func (s *Service) Rename(admin *domain.User, id, username string) (*domain.User, error) {
+	username, err := s.names.Check(username)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := audit(admin, ActionRename, target, map[string]string{"from": target.Username, "to": username})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Rename(id, username, entry); err != nil {
+		return nil, err
+	}
+	target.Username = username
+	return target, nil
+}
+
+// IssuePINReset creates a one-time code the user can redeem to set a new
+// PIN. The code itself is never stored or audited.
+func (s *Service) IssuePINReset(admin *domain.User, id string) (string, time.Time, error) {
+	target, err := s.lookup(id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	entry, err := audit(admin, ActionPINReset, target, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return s.auth.IssueResetCode(target.ID, admin.ID, entry)
+}
+
+// Suspend stops a user from signing in and logs them out everywhere.
+func (s *Service) Suspend(admin *domain.User, id, reason string) (*domain.User, error) {
+	if id == admin.ID {
+		return nil, ErrSelf
+	}
+	target, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := audit(admin, ActionSuspend, target, map[string]string{"reason": reason})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	revoked, err := s.userRepo.Suspend(id, now, reason, entry)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("user_id", id).Int("revoked_sessions", revoked).Msg("Admin Service: Suspended user logged out")
+	target.SuspendedAt = &now
+	target.SuspendReason = reason
+	return target, nil
+}
+
+func (s *Service) Unsuspend(admin *domain.User, id string) (*domain.User, error) {
+	target, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := audit(admin, ActionUnsuspend, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.userRepo.Suspend(id, time.Time{}, "", entry); err != nil {
+		return nil, err
+	}
+	target.SuspendedAt = nil
+	target.SuspendReason = ""
+	return target, nil
+}
+
+// SetRole grants or removes admin rights. Admins can't demote themselves,
+// so there is always at least the one who is acting.
+func (s *Service) SetRole(admin *domain.User, id, role string) (*domain.User, error) {
+	if role != domain.RolePlayer && role != domain.RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+	if id == admin.ID {
+		return nil, ErrSelf
+	}
+	target, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := audit(admin, ActionSetRole, target, map[string]string{"from": target.Role, "to": role})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetRole(id, role, entry); err != nil {
+		return nil, err
+	}
+	target.Role = role
+	return target, nil
+}
+
+// SetParent links a user to the parent account that may reset their PIN,
+// or unlinks them if parentID is empty.
+func (s *Service) SetParent(admin *domain.User, id, parentID string) (*domain.User, error) {
+	target, err := s.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]string{"from": target.ParentID, "to": parentID}
+	if parentID != "" {
+		parent, err := s.lookup(parentID)
+		if errors.Is(err, ErrUserNotFound) || parentID == id {
+			return nil, ErrInvalidParent
+		}
+		if err != nil {
+			return nil, err
+		}
+		details["to_username"] = parent.Username
+	}
+	entry, err := audit(admin, ActionSetParent, target, details)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetParent(id, parentID, entry); err != nil {
+		return nil, err
+	}
+	target.ParentID = parentID
+	return target, nil
+}
+
+// DeleteUser removes a user along with all their sessions, scores and
+// matches.
+func (s *Service) DeleteUser(admin *domain.User, id string) error {
+	if id == admin.ID {
+		return ErrSelf
+	}
+	target, err := s.lookup(id)
+	if err != nil {
+		return err
+	}
+	entry, err := audit(admin, ActionDelete, target, nil)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.Delete(id, entry)
+}
+
+// AuditLog returns recent admin actions, only those about targetID if set.
+func (s *Service) AuditLog(targetID string, limit int) ([]*domain.AuditEntry, error) {
+	return s.auditRepo.List(targetID, limit)
+}
+
+// Bootstrap makes the named users admins, so a fresh install has someone
+// who can reach the admin API. It only does anything while there is no
+// admin yet: once there is one, admins are managed through the API and a
+// name left in the configuration can't be used to take over whoever
+// registers it later. Names that don't exist yet, and accounts without a
+// PIN, are skipped.
+func (s *Service) Bootstrap(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	hasAdmin, err := s.userRepo.HasAdmin()
+	if err != nil {
+		return err
+	}
+	if hasAdmin {
+		log.Info().Msg("Admin Service: An admin already exists, ignoring configured admins")
+		return nil
+	}
+	for _, name := range usernames {
+		user, err := s.userRepo.GetByUsername(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn().Str("username", name).Msg("Admin Service: Configured admin does not exist yet")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if user.Guest || user.PinHash == "" {
+			log.Warn().Str("username", name).Msg("Admin Service: Configured admin has no PIN, not promoting")
+			continue
+		}
+		entry, err := audit(systemAdmin, ActionSetRole, user, map[string]string{"from": user.Role, "to": domain.RoleAdmin})
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.SetRole(user.ID, domain.RoleAdmin, entry); err != nil {
+			return err
+		}
+		log.Info().Str("username", name).Msg("Admin Service: Promoted configured admin")
+	}
+	return nil
+}
+
+func (s *Service) lookup(id string) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// audit describes an admin action for the audit trail. The repos write it
+// in the transaction that makes the change.
+func audit(admin *domain.User, action string, target *domain.User, details any) (*domain.AuditEntry, error) {
+	entry := &domain.AuditEntry{
+		AdminID:        admin.ID,
+		AdminUsername:  admin.Username,
+		Action:         action,
+		TargetID:       target.ID,
+		TargetUsername: target.Username,
+	}
+	if details != nil {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return nil, err
+		}
+		entry.Details = encoded
+	}
+	log.Info().Str("admin_id", admin.ID).Str("action", action).Str("target_id", target.ID).Msg("Admin Service: Admin action")
+	return entry, nil
+}
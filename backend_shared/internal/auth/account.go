@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// ExportAccount returns everything stored about the user.
+func (s *AuthService) ExportAccount(userID string) (*domain.UserExport, error) {
+	log.Info().Str("user_id", userID).Msg("Exporting account data")
+	return s.userRepo.Export(userID)
+}
+
+// DeleteAccount removes the user and all their data for good. The PIN is
+// asked for again so a leaked access token can't erase an account, and
+// wrong PINs are throttled like logins. Guests have no PIN and can drop
+// their account straight away.
+func (s *AuthService) DeleteAccount(user *domain.User, pin string, client Client) error {
+	if !user.Guest {
+		if err := s.guard.Attempt(user.Username, client.IP); err != nil {
+			return err
+		}
+		if !s.checkPIN(user, pin) {
+			if err := s.guard.Failure(user.Username, client.IP); err != nil {
+				return err
+			}
+			return ErrInvalidPIN
+		}
+		if err := s.guard.Success(user.Username, client.IP); err != nil {
+			return err
+		}
+	}
+
+	if err := s.userRepo.Delete(user.ID, nil); err != nil {
+		return err
+	}
+	log.Info().Str("user_id", user.ID).Msg("Account deleted by its owner")
+	return nil
+}
@@ -1,41 +1,108 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ramanasai/local-game-play/internal/domain"
 	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// RefreshTokenTTL is how long a login lasts without being used. Every
+// refresh pushes it out again.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// lastSeenResolution limits how often a session's last-seen time is
+// written, so busy clients don't cause a write per request.
+const lastSeenResolution = time.Minute
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidPIN          = errors.New("invalid pin")
+	ErrPINTooShort         = errors.New("pin must be at least 4 digits")
+	ErrAccountSuspended    = errors.New("account is suspended")
+)
+
+// Client identifies the device a request came from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// ClientFromRequest describes the device behind r. RemoteAddr has already
+// been replaced with the forwarded address by the RealIP middleware, but
+// only for requests that came through a trusted proxy.
+func ClientFromRequest(r *http.Request) Client {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return Client{IP: ip, UserAgent: r.UserAgent()}
+}
+
 type AuthService struct {
 	userRepo    *repos.UserRepo
-	sessionRepo *repos.SessionRepo // Kept for legacy session cleanup if needed, but not primary
+	sessionRepo *repos.SessionRepo
+	pinResets   *repos.PinResetRepo
+	pins        *PINHashing
+	guard       *LoginGuard
+	keys        *Keyring
+	names       *usernames.Policy
 }
 
-func NewAuthService(userRepo *repos.UserRepo, sessionRepo *repos.SessionRepo) *AuthService {
+func NewAuthService(userRepo *repos.UserRepo, sessionRepo *repos.SessionRepo, pinResets *repos.PinResetRepo, pins *PINHashing, guard *LoginGuard, keys *Keyring, names *usernames.Policy) *AuthService {
 	return &AuthService{
 		userRepo:    userRepo,
 		sessionRepo: sessionRepo,
+		pinResets:   pinResets,
+		pins:        pins,
+		guard:       guard,
+		keys:        keys,
+		names:       names,
 	}
 }
 
 // LoginResponse contains what the handler needs to send back
 type LoginResponse struct {
-	User   *domain.User
-	Token  string
-	Status string // "OK", "PIN_REQUIRED", "SET_PIN_REQUIRED"
+	User         *domain.User
+	Token        string
+	RefreshToken string
+	Status       string // "OK", "PIN_REQUIRED", "SET_PIN_REQUIRED"
+}
+
+// TokenPair is a fresh access token and the refresh token that replaces
+// the one just used.
+type TokenPair struct {
+	Token        string
+	RefreshToken string
 }
 
-func (s *AuthService) Login(username string, pin string, hint string) (*LoginResponse, error) {
+func (s *AuthService) Login(username string, pin string, hint string, client Client) (*LoginResponse, error) {
 	log.Debug().Str("username", username).Msg("Attempting login")
 
 	user, err := s.userRepo.GetByUsername(username)
 	if err != nil {
-		// User not found -> Create new user.
-		log.Info().Str("username", username).Msg("Creating new user")
-		user, err = s.userRepo.Create(username)
+		// User not found -> Create new user, if the name is allowed.
+		name, err := s.names.Check(username)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("username", name).Msg("Creating new user")
+		user, err = s.userRepo.Create(name)
 		if err != nil {
 			log.Error().Err(err).Str("username", username).Msg("Failed to create user")
 			return nil, err
@@ -51,6 +118,13 @@ func (s *AuthService) Login(username string, pin string, hint string) (*LoginRes
 	}
 
 	// User exists
+	if user.Guest {
+		return nil, ErrGuestAccount
+	}
+	if user.SuspendedAt != nil {
+		log.Warn().Str("username", username).Msg("Login refused for suspended user")
+		return nil, ErrAccountSuspended
+	}
 	if user.PinHash == "" {
 		// Legacy user without PIN.
 		// If PIN is provided now, SET IT.
@@ -63,7 +137,7 @@ func (s *AuthService) Login(username string, pin string, hint string) (*LoginRes
 			user.PinHash = "set" // simplified
 		} else {
 			log.Info().Str("username", username).Msg("User requires PIN setup")
-			return &LoginResponse{User: user, Status: "SET_PIN_REQUIRED"}, nil
+			return &LoginResponse{User: withoutHint(user), Status: "SET_PIN_REQUIRED"}, nil
 		}
 	}
 
@@ -71,7 +145,7 @@ func (s *AuthService) Login(username string, pin string, hint string) (*LoginRes
 	// Verify it
 	if pin == "" {
 		log.Info().Str("username", username).Msg("User missing PIN in request")
-		return &LoginResponse{User: user, Status: "PIN_REQUIRED"}, nil
+		return &LoginResponse{User: withoutHint(user), Status: "PIN_REQUIRED"}, nil
 	}
 
 	// If we just set it, we could skip re-verify, but verify adds safety.
@@ -86,44 +160,252 @@ func (s *AuthService) Login(username string, pin string, hint string) (*LoginRes
 		user = u
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(pin))
-	if err != nil {
+	if err := s.guard.Attempt(username, client.IP); err != nil {
+		log.Warn().Err(err).Str("username", username).Str("ip", client.IP).Msg("PIN attempt refused")
+		return nil, err
+	}
+
+	if !s.checkPIN(user, pin) {
 		log.Warn().Str("username", username).Msg("Invalid PIN attempt")
-		return nil, errors.New("invalid pin")
+		if err := s.guard.Failure(username, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPIN
+	}
+	if err := s.guard.Success(username, client.IP); err != nil {
+		return nil, err
 	}
 
-	// Valid PIN -> Generate JWT
-	token, err := GenerateToken(user)
+	// Valid PIN -> start a session
+	tokens, err := s.startSession(user, client)
 	if err != nil {
 		log.Error().Err(err).Str("username", username).Msg("Token generation failed")
 		return nil, err
 	}
 
 	log.Info().Str("username", username).Msg("Login successful")
-	return &LoginResponse{User: user, Token: token, Status: "OK"}, nil
+	return &LoginResponse{User: user, Token: tokens.Token, RefreshToken: tokens.RefreshToken, Status: "OK"}, nil
 }
 
-func (s *AuthService) UpdatePIN(userID string, pin string, hint string) error {
+// withoutHint copies user for a caller who hasn't proven who they are yet.
+// A hint is a clue to the PIN, so it is only shown after signing in.
+func withoutHint(user *domain.User) *domain.User {
+	u := *user
+	u.Hint = ""
+	return &u
+}
+
+// startSession records a new session for user and issues its first tokens.
+// A refresh token is "<session id>.<secret>"; only the secret's hash is
+// stored.
+func (s *AuthService) startSession(user *domain.User, client Client) (*TokenPair, error) {
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.sessionRepo.Create(user.ID, hashSecret(secret), time.Now().Add(RefreshTokenTTL), client.IP, client.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.keys.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{Token: token, RefreshToken: session.ID + "." + secret}, nil
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. Presenting a refresh token that has already been traded in means
+// it leaked, so the whole session is revoked and both holders are logged
+// out.
+func (s *AuthService) Refresh(refreshToken string, client Client) (*TokenPair, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	oldHash := hashSecret(secret)
+	if subtle.ConstantTimeCompare([]byte(oldHash), []byte(session.Token)) != 1 {
+		return nil, s.revokeReused(session)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, ErrAccountSuspended
+	}
+	next, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.Rotate(session.ID, oldHash, hashSecret(next), time.Now().Add(RefreshTokenTTL)); err != nil {
+		if errors.Is(err, repos.ErrTokenMismatch) {
+			// Another request rotated this same token first.
+			return nil, s.revokeReused(session)
+		}
+		return nil, err
+	}
+	s.Touch(session, client)
+
+	token, err := s.keys.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{Token: token, RefreshToken: session.ID + "." + next}, nil
+}
+
+func (s *AuthService) revokeReused(session *domain.Session) error {
+	log.Warn().Str("session_id", session.ID).Str("user_id", session.UserID).Msg("Refresh token reuse detected, revoking session")
+	if err := s.sessionRepo.Revoke(session.ID, "reuse"); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// Logout revokes a session, invalidating its refresh token and every access
+// token issued from it.
+func (s *AuthService) Logout(sessionID string) error {
+	log.Info().Str("session_id", sessionID).Msg("Logging out session")
+	return s.sessionRepo.Revoke(sessionID, "logout")
+}
+
+func newRefreshSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validatePIN(pin string) error {
 	if len(pin) < 4 {
-		return errors.New("pin must be at least 4 digits")
+		return ErrPINTooShort
+	}
+	return nil
+}
+
+func (s *AuthService) UpdatePIN(userID string, pin string, hint string) error {
+	if err := validatePIN(pin); err != nil {
+		return err
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	hash, err := s.pins.Hash(pin)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to hash PIN")
 		return err
 	}
 
 	log.Info().Str("user_id", userID).Msg("Updating/Setting PIN")
-	return s.userRepo.UpdatePIN(userID, string(hash), hint)
+	return s.userRepo.UpdatePIN(userID, hash, hint)
+}
+
+// checkPIN reports whether pin is the user's. A correct PIN stored with an
+// older algorithm or older cost parameters is rehashed on the spot; if
+// that fails the old hash simply stays.
+func (s *AuthService) checkPIN(user *domain.User, pin string) bool {
+	ok, needsRehash, err := s.pins.Verify(user.PinHash, pin)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to verify PIN hash")
+		return false
+	}
+	if ok && needsRehash {
+		if hash, err := s.pins.Hash(pin); err != nil {
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to rehash PIN")
+		} else if err := s.userRepo.UpdatePINHash(user.ID, hash); err == nil {
+			log.Info().Str("user_id", user.ID).Msg("PIN rehashed with current parameters")
+			user.PinHash = hash
+		}
+	}
+	return ok
 }
 
-func (s *AuthService) GetUserFromToken(tokenString string) (*domain.User, error) {
+// GetUserFromToken validates an access token and returns its user and
+// session. Tokens from revoked sessions are refused even before they
+// expire.
+func (s *AuthService) GetUserFromToken(tokenString string) (*domain.User, *domain.Session, error) {
 	// Validate JWT
-	claims, err := ValidateToken(tokenString)
+	claims, err := s.keys.ValidateToken(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, nil, errors.New("token has no session")
 	}
 
-	return s.userRepo.GetByID(claims.UserID)
+	session, err := s.sessionRepo.GetByID(claims.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, nil, ErrSessionRevoked
+	}
+
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.SuspendedAt != nil {
+		return nil, nil, ErrAccountSuspended
+	}
+	return user, session, nil
+}
+
+// Touch notes that session is in use from client. Failures are logged
+// rather than returned; a stale last-seen time shouldn't fail a request.
+func (s *AuthService) Touch(session *domain.Session, client Client) {
+	fresh := session.LastSeenAt != nil && time.Since(*session.LastSeenAt) < lastSeenResolution
+	if fresh && session.IP == client.IP && session.UserAgent == client.UserAgent {
+		return
+	}
+	if err := s.sessionRepo.Touch(session.ID, client.IP, client.UserAgent); err != nil {
+		log.Warn().Err(err).Str("session_id", session.ID).Msg("Failed to update session last-seen")
+	}
+}
+
+// ListSessions returns the user's live sessions, most recently used first.
+func (s *AuthService) ListSessions(userID string) ([]*domain.Session, error) {
+	return s.sessionRepo.ListActiveByUser(userID)
+}
+
+// RevokeSession ends one of the user's sessions, e.g. a lost device.
+func (s *AuthService) RevokeSession(userID, sessionID string) error {
+	err := s.sessionRepo.RevokeForUser(sessionID, userID, "revoked")
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err == nil {
+		log.Info().Str("user_id", userID).Str("session_id", sessionID).Msg("Session revoked by user")
+	}
+	return err
+}
+
+// RevokeOtherSessions ends every session of the user except currentID and
+// returns how many were ended.
+func (s *AuthService) RevokeOtherSessions(userID, currentID string) (int, error) {
+	n, err := s.sessionRepo.RevokeOthers(userID, currentID, "revoked")
+	if err == nil {
+		log.Info().Str("user_id", userID).Int("revoked", n).Msg("Other sessions revoked by user")
+	}
+	return n, err
 }
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/db/dbtest"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+)
+
+// newTestService returns a service on a fresh database with one user.
+func newTestService(t *testing.T) (*AuthService, *domain.User) {
+	t.Helper()
+	database := dbtest.Open(t)
+	keys, err := LoadKeyring(filepath.Join(t.TempDir(), "keys.json"), "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &AuthService{userRepo: repos.NewUserRepo(database), sessionRepo: repos.NewSessionRepo(database), keys: keys}
+	user, err := s.userRepo.Create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, user
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s, user := newTestService(t)
+	first, err := s.startSession(user, Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(first.RefreshToken, Client{})
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Each step runs against the state the ones before it left.
+	steps := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"replayed token", first.RefreshToken, ErrRefreshTokenReused},
+		{"current token after reuse", second.RefreshToken, ErrSessionRevoked},
+		{"replayed token again", first.RefreshToken, ErrSessionRevoked},
+	}
+	for _, step := range steps {
+		if _, err := s.Refresh(step.token, Client{}); !errors.Is(err, step.want) {
+			t.Fatalf("%s: got %v, want %v", step.name, err, step.want)
+		}
+	}
+	if _, _, err := s.GetUserFromToken(second.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token of revoked session: got %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	s, user := newTestService(t)
+	pair, err := s.startSession(user, Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, _, _ := strings.Cut(pair.RefreshToken, ".")
+
+	for _, token := range []string{"", "nodot", ".secret", sessionID + ".", "unknown-session.secret"} {
+		if _, err := s.Refresh(token, Client{}); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh(%q) = %v, want %v", token, err, ErrInvalidRefreshToken)
+		}
+	}
+	// None of those touched the real session.
+	if _, err := s.Refresh(pair.RefreshToken, Client{}); err != nil {
+		t.Errorf("valid token after invalid ones: %v", err)
+	}
+}
+
+func TestRefreshAfterLogout(t *testing.T) {
+	s, user := newTestService(t)
+	pair, err := s.startSession(user, Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, _, _ := strings.Cut(pair.RefreshToken, ".")
+	if err := s.Logout(sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refresh(pair.RefreshToken, Client{}); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refresh after logout: got %v, want %v", err, ErrSessionRevoked)
+	}
+}
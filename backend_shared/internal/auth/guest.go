@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/usernames"
+	"github.com/rs/zerolog/log"
+)
+
+// GuestUsernamePrefix starts every generated guest name. The username
+// policy reserves it, so a guest name never collides with a real account.
+const GuestUsernamePrefix = "guest-"
+
+var (
+	ErrGuestAccount = errors.New("guest accounts have no PIN; claim the account first")
+	ErrNotGuest     = errors.New("only guest accounts can be claimed")
+)
+
+// StartGuest creates an anonymous account and signs it in. Guests can play
+// everything, but their scores don't appear on leaderboards.
+func (s *AuthService) StartGuest(client Client) (*LoginResponse, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.CreateGuest(GuestUsernamePrefix + hex.EncodeToString(b))
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("user_id", user.ID).Str("ip", client.IP).Msg("Guest session started")
+	return &LoginResponse{User: user, Token: tokens.Token, RefreshToken: tokens.RefreshToken, Status: "OK"}, nil
+}
+
+// ClaimGuest keeps a guest's history. If username belongs to an existing
+// account, pin must be its PIN and the guest's scores and matches move
+// into it; otherwise the guest becomes that new account with pin. Either
+// way the caller gets a session for the resulting account.
+func (s *AuthService) ClaimGuest(guest *domain.User, username, pin, hint string, client Client) (*LoginResponse, error) {
+	if !guest.Guest {
+		return nil, ErrNotGuest
+	}
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+
+	target, err := s.userRepo.GetByUsername(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.convertGuest(guest, username, pin, hint, client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Guest {
+		return nil, usernames.ErrReserved
+	}
+	if target.SuspendedAt != nil {
+		return nil, ErrAccountSuspended
+	}
+	if err := s.guard.Attempt(username, client.IP); err != nil {
+		return nil, err
+	}
+	if target.PinHash == "" || !s.checkPIN(target, pin) {
+		if err := s.guard.Failure(username, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPIN
+	}
+	if err := s.guard.Success(username, client.IP); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.MergeGuest(guest.ID, target.ID); err != nil {
+		return nil, err
+	}
+	tokens, err := s.startSession(target, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("guest_id", guest.ID).Str("user_id", target.ID).Msg("Guest history merged into existing account")
+	return &LoginResponse{User: target, Token: tokens.Token, RefreshToken: tokens.RefreshToken, Status: "OK"}, nil
+}
+
+// convertGuest turns the guest into a new account in place. The guest's
+// sessions are revoked and replaced with one fresh session, whose token
+// carries the new username.
+func (s *AuthService) convertGuest(guest *domain.User, username, pin, hint string, client Client) (*LoginResponse, error) {
+	name, err := s.names.Check(username)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := s.pins.Hash(pin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.ClaimGuest(guest.ID, name, hash, hint); err != nil {
+		return nil, err
+	}
+	if _, err := s.sessionRepo.RevokeAll(guest.ID, "claimed"); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(guest.ID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("user_id", user.ID).Str("username", name).Msg("Guest claimed as new account")
+	return &LoginResponse{User: user, Token: tokens.Token, RefreshToken: tokens.RefreshToken, Status: "OK"}, nil
+}
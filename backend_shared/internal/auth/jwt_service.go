@@ -2,7 +2,6 @@ package auth
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,48 +9,55 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// AccessTokenTTL is kept short because access tokens are only checked
+// against their session, not stored; the refresh token renews them.
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func getSecret() []byte {
-	return []byte(os.Getenv("JWT_SECRET"))
-}
-
-func GenerateToken(user *domain.User) (string, error) {
-	jwtSecret := getSecret()
-	if len(jwtSecret) == 0 {
-		log.Error().Msg("JWT_SECRET not set in environment")
-		return "", errors.New("JWT_SECRET not set")
+// GenerateToken issues an access token for user tied to sessionID, so
+// revoking the session also rejects the token. It is signed with the
+// active key and names it in the kid header.
+func (k *Keyring) GenerateToken(user *domain.User, sessionID string) (string, error) {
+	key := k.Signing()
+	if key == nil {
+		log.Error().Msg("Keyring has no active signing key")
+		return "", errors.New("no active signing key")
 	}
 
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 30)), // 30 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Secret)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
-	jwtSecret := getSecret()
-	if len(jwtSecret) == 0 {
-		return nil, errors.New("JWT_SECRET not set")
-	}
-
+// ValidateToken verifies a token against the key its kid header names.
+func (k *Keyring) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			log.Warn().Str("alg", token.Method.Alg()).Msg("Unexpected signing method")
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.Verifying(kid)
+		if !ok {
+			return nil, errors.New("unknown or expired signing key")
+		}
+		return key.Secret, nil
 	})
 
 	if err != nil {
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MinSecretLength is the shortest JWT_SECRET accepted, in bytes. HS256
+// keys shorter than the hash output only weaken it.
+const MinSecretLength = 32
+
+// weakSecrets are values known to ship in examples and compose files.
+var weakSecrets = []string{"secret", "changeme", "password", "jwt_secret", "your_secure_secret", "supersecret"}
+
+var ErrWeakSecret = errors.New("JWT_SECRET is too weak")
+
+// SigningKey is one HS256 key. A retired key no longer signs but still
+// verifies tokens until the longest-lived of them has expired.
+type SigningKey struct {
+	ID        string    `json:"kid"`
+	Secret    []byte    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+	RetiredAt time.Time `json:"retired_at,omitzero"`
+}
+
+func (k *SigningKey) verifies(now time.Time) bool {
+	return k.RetiredAt.IsZero() || now.Before(k.RetiredAt.Add(AccessTokenTTL))
+}
+
+// Keyring holds the signing keys, persisted as JSON in the data directory.
+// Exactly one key is active at a time.
+type Keyring struct {
+	path          string
+	rotationEvery time.Duration
+	pinned        bool // the active key comes from JWT_SECRET and is never rotated
+
+	mu   sync.RWMutex
+	keys []*SigningKey // active key first
+}
+
+// CheckSecret rejects secrets that are short or known defaults.
+func CheckSecret(secret string) error {
+	for _, weak := range weakSecrets {
+		if strings.EqualFold(secret, weak) {
+			return fmt.Errorf("%w: %q is a published default", ErrWeakSecret, secret)
+		}
+	}
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("%w: it must be at least %d bytes, got %d", ErrWeakSecret, MinSecretLength, len(secret))
+	}
+	return nil
+}
+
+// LoadKeyring reads the keyring at path, creating it if needed. If secret
+// is set it becomes the active key, and whatever key was active before
+// keeps verifying until its tokens expire, so changing JWT_SECRET doesn't
+// log everyone out. Without a secret a random key is generated on first
+// boot and rotated every rotationEvery (never if zero).
+func LoadKeyring(path, secret string, rotationEvery time.Duration) (*Keyring, error) {
+	k := &Keyring{path: path, rotationEvery: rotationEvery, pinned: secret != ""}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &k.keys); err != nil {
+			return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read keyring %s: %w", path, err)
+	}
+
+	now := time.Now().UTC()
+	changed := k.prune(now)
+
+	if secret != "" {
+		if err := CheckSecret(secret); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256([]byte(secret))
+		configured := &SigningKey{ID: "env-" + hex.EncodeToString(sum[:4]), Secret: []byte(secret), CreatedAt: now}
+		if active := k.active(); active == nil || active.ID != configured.ID {
+			k.promote(configured, now)
+			changed = true
+			log.Info().Str("kid", configured.ID).Msg("Keyring: Using JWT_SECRET as the signing key")
+		}
+	} else if k.active() == nil {
+		if err := k.generate(now); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := k.save(); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// RotateIfDue replaces the active key with a fresh one once it is older
+// than the rotation interval. Keys from JWT_SECRET are left alone.
+func (k *Keyring) RotateIfDue() error {
+	if k.pinned || k.rotationEvery <= 0 {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now().UTC()
+	changed := k.prune(now)
+	if active := k.active(); active == nil || now.Sub(active.CreatedAt) >= k.rotationEvery {
+		if err := k.generate(now); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return k.save()
+}
+
+// Signing returns the active key.
+func (k *Keyring) Signing() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active()
+}
+
+// Verifying returns the key with the given ID if it may still verify.
+func (k *Keyring) Verifying(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.ID == kid && key.verifies(now) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (k *Keyring) active() *SigningKey {
+	if len(k.keys) == 0 || !k.keys[0].RetiredAt.IsZero() {
+		return nil
+	}
+	return k.keys[0]
+}
+
+func (k *Keyring) generate(now time.Time) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	key := &SigningKey{ID: hex.EncodeToString(id), Secret: secret, CreatedAt: now}
+	k.promote(key, now)
+	log.Info().Str("kid", key.ID).Msg("Keyring: Generated new signing key")
+	return nil
+}
+
+// promote makes key the active one and retires the previous active key.
+// An earlier copy of the same key, retired when JWT_SECRET was switched
+// away from it and back, is replaced.
+func (k *Keyring) promote(key *SigningKey, now time.Time) {
+	if active := k.active(); active != nil {
+		active.RetiredAt = now
+	}
+	keys := []*SigningKey{key}
+	for _, old := range k.keys {
+		if old.ID != key.ID {
+			keys = append(keys, old)
+		}
+	}
+	k.keys = keys
+}
+
+// prune drops retired keys that can no longer verify anything.
+func (k *Keyring) prune(now time.Time) bool {
+	kept := k.keys[:0]
+	for _, key := range k.keys {
+		if key.verifies(now) {
+			kept = append(kept, key)
+		}
+	}
+	changed := len(kept) != len(k.keys)
+	k.keys = kept
+	return changed
+}
+
+// save writes the keyring atomically, readable only by the server.
+func (k *Keyring) save() error {
+	data, err := json.MarshalIndent(k.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return fmt.Errorf("failed to create keyring directory: %w", err)
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
+	"github.com/rs/zerolog/log"
+)
+
+// Scopes failed PIN attempts are counted under.
+const (
+	ScopeUser = "user"
+	ScopeIP   = "ip"
+)
+
+const (
+	// failureWindow is how long a failed attempt counts against a key.
+	failureWindow = time.Hour
+	// lockoutWindow is how long past lockouts make the next one longer.
+	lockoutWindow = 24 * time.Hour
+)
+
+// throttlePolicy says when failed attempts start costing time. After
+// FreeAttempts failures each further attempt must wait BaseDelay, doubling
+// per failure; at LockAfter failures the key is locked out for LockFor,
+// doubling per recent lockout up to MaxLock.
+type throttlePolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	LockAfter    int
+	LockFor      time.Duration
+	MaxLock      time.Duration
+}
+
+// A 4-digit PIN has 10,000 values, so a username gets a handful of
+// attempts an hour. An IP is allowed more because households and offices
+// share one.
+var policies = map[string]throttlePolicy{
+	ScopeUser: {FreeAttempts: 3, BaseDelay: time.Second, LockAfter: 10, LockFor: 15 * time.Minute, MaxLock: 24 * time.Hour},
+	ScopeIP:   {FreeAttempts: 10, BaseDelay: time.Second, LockAfter: 30, LockFor: 15 * time.Minute, MaxLock: 24 * time.Hour},
+}
+
+// LockedOutError is returned while a username or IP may not attempt a PIN.
+type LockedOutError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds rounds up so clients never retry a moment too early.
+func (e *LockedOutError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// LoginGuard tracks failed PIN attempts per username and per IP and decides
+// when the next attempt may be made. Usernames are counted by their key, so
+// changing the case doesn't buy more attempts. The IP is only as reliable
+// as the proxies trusted to report it (TRUSTED_PROXIES); the per-username
+// limit holds whatever address a client claims.
+type LoginGuard struct {
+	repo *repos.LoginAttemptRepo
+}
+
+func NewLoginGuard(repo *repos.LoginAttemptRepo) *LoginGuard {
+	return &LoginGuard{repo: repo}
+}
+
+// Attempt counts a PIN attempt by username from ip, before the PIN is
+// checked, so guesses sent in parallel are throttled like ones sent one
+// after another. It returns a *LockedOutError, counting nothing, if either
+// must wait first. Every counted attempt must be followed by Failure or
+// Success.
+func (g *LoginGuard) Attempt(username, ip string) error {
+	now := time.Now()
+	keys := guardKeys(username, ip)
+	for i, k := range keys {
+		policy := policies[k.scope]
+		_, err := g.repo.RecordAttempt(k.scope, k.key, now, failureWindow, lockoutWindow, policy.FreeAttempts, policy.BaseDelay)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		// Refused: the keys counted so far don't get an attempt either.
+		for _, counted := range keys[:i] {
+			if err := g.repo.Uncount(counted.scope, counted.key); err != nil {
+				return err
+			}
+		}
+		f, err := g.repo.Get(k.scope, k.key)
+		if err != nil {
+			return err
+		}
+		return &LockedOutError{Scope: k.scope, RetryAfter: max(policy.wait(f, now), time.Second)}
+	}
+	return nil
+}
+
+// Failure records that the attempt was a wrong PIN, locking the username
+// or IP out once it has failed too often.
+func (g *LoginGuard) Failure(username, ip string) error {
+	now := time.Now()
+	for _, k := range guardKeys(username, ip) {
+		f, err := g.repo.Get(k.scope, k.key)
+		if err != nil {
+			return err
+		}
+		policy := policies[k.scope]
+		if f.Failures < policy.LockAfter {
+			continue
+		}
+
+		until := now.Add(policy.lockDuration(f.Lockouts))
+		if err := g.repo.Lock(k.scope, k.key, f.Failures, until); err != nil {
+			return err
+		}
+		log.Warn().Str("scope", k.scope).Str("key", k.key).Int("failures", f.Failures).Time("locked_until", until).Msg("Login locked out")
+	}
+	return nil
+}
+
+// Success records that the attempt was the right PIN. It clears the
+// username's failures; the IP only gets this attempt back, so an attacker
+// can't reset its count by logging in to an account of their own.
+func (g *LoginGuard) Success(username, ip string) error {
+	if err := g.repo.Reset(ScopeUser, usernames.Key(username)); err != nil {
+		return err
+	}
+	return g.repo.Uncount(ScopeIP, ip)
+}
+
+type guardKey struct{ scope, key string }
+
+func guardKeys(username, ip string) []guardKey {
+	return []guardKey{{ScopeUser, usernames.Key(username)}, {ScopeIP, ip}}
+}
+
+// wait is how long f's key must wait before its next attempt.
+func (p throttlePolicy) wait(f *domain.LoginFailures, now time.Time) time.Duration {
+	wait := f.LockedUntil.Sub(now)
+	if f.Failures > p.FreeAttempts && now.Sub(f.LastFailureAt) < failureWindow {
+		next := f.LastFailureAt.Add(p.delay(f.Failures))
+		wait = max(wait, next.Sub(now))
+	}
+	return wait
+}
+
+func (p throttlePolicy) delay(failures int) time.Duration {
+	return p.BaseDelay << min(failures-p.FreeAttempts-1, 16)
+}
+
+func (p throttlePolicy) lockDuration(previousLockouts int) time.Duration {
+	return min(p.LockFor<<min(previousLockouts, 16), p.MaxLock)
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/db/dbtest"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+)
+
+func TestLoginGuardThrottlesUsername(t *testing.T) {
+	g := NewLoginGuard(repos.NewLoginAttemptRepo(dbtest.Open(t)))
+	free := policies[ScopeUser].FreeAttempts
+	for i := 0; i <= free; i++ {
+		if err := g.Attempt("Bob", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if err := g.Failure("Bob", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The username is throttled whatever its case and wherever it is
+	// tried from; the IP still has free attempts for other names.
+	var locked *LockedOutError
+	if err := g.Attempt("bob", "10.0.0.2"); !errors.As(err, &locked) || locked.Scope != ScopeUser {
+		t.Fatalf("attempt past the free ones: got %v, want the username throttled", err)
+	}
+	if err := g.Attempt("carol", "10.0.0.1"); err != nil {
+		t.Fatalf("other username from the same IP: %v", err)
+	}
+	if err := g.Success("carol", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	p := policies[ScopeUser]
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name string
+		f    domain.LoginFailures
+		want time.Duration
+	}{
+		{"no failures", domain.LoginFailures{}, 0},
+		{"free attempts left", domain.LoginFailures{Failures: 3, LastFailureAt: now}, 0},
+		{"first delay", domain.LoginFailures{Failures: 4, LastFailureAt: now}, time.Second},
+		{"doubled delay", domain.LoginFailures{Failures: 6, LastFailureAt: now}, 4 * time.Second},
+		{"delay partly waited", domain.LoginFailures{Failures: 6, LastFailureAt: now.Add(-time.Second)}, 3 * time.Second},
+		{"failures out of the window", domain.LoginFailures{Failures: 9, LastFailureAt: now.Add(-failureWindow)}, 0},
+		{"locked out", domain.LoginFailures{LockedUntil: now.Add(time.Minute)}, time.Minute},
+		{"lock longer than delay", domain.LoginFailures{Failures: 4, LastFailureAt: now, LockedUntil: now.Add(time.Minute)}, time.Minute},
+	}
+	for _, tt := range tests {
+		// Anything not positive means the attempt may be made now.
+		if got := max(p.wait(&tt.f, now), 0); got != tt.want {
+			t.Errorf("%s: wait = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLockDuration(t *testing.T) {
+	p := policies[ScopeUser]
+	tests := []struct {
+		previous int
+		want     time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{1, 30 * time.Minute},
+		{3, 2 * time.Hour},
+		{6, 16 * time.Hour},
+		{7, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := p.lockDuration(tt.previous); got != tt.want {
+			t.Errorf("lockDuration(%d) = %v, want %v", tt.previous, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrParentNotFound = errors.New("parent account not found")
+	ErrNotYourChild   = errors.New("user is not linked to your account")
+	ErrInvalidParent  = errors.New("an account can't be its own parent")
+)
+
+// LinkParent lets a signed-in user name a parent account, which can then
+// issue PIN reset codes for them. The user's PIN is asked for again, since
+// a parent can take over the account, and wrong PINs are throttled like
+// logins.
+func (s *AuthService) LinkParent(user *domain.User, pin, parentUsername string, client Client) (*domain.User, error) {
+	if user.Guest {
+		return nil, ErrGuestAccount
+	}
+	if err := s.guard.Attempt(user.Username, client.IP); err != nil {
+		return nil, err
+	}
+	if !s.checkPIN(user, pin) {
+		if err := s.guard.Failure(user.Username, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPIN
+	}
+	if err := s.guard.Success(user.Username, client.IP); err != nil {
+		return nil, err
+	}
+
+	parent, err := s.userRepo.GetByUsername(parentUsername)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.Guest) {
+		return nil, ErrParentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if parent.ID == user.ID {
+		return nil, ErrInvalidParent
+	}
+	if err := s.userRepo.SetParent(user.ID, parent.ID, nil); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID).Str("parent_id", parent.ID).Msg("Parent account linked")
+	return parent, nil
+}
+
+// UnlinkParent removes the user's parent account, if any.
+func (s *AuthService) UnlinkParent(userID string) error {
+	log.Info().Str("user_id", userID).Msg("Parent account unlinked")
+	return s.userRepo.SetParent(userID, "", nil)
+}
+
+// ListChildren returns the accounts linked to parentID. Their hints are
+// left out; those are for the child alone.
+func (s *AuthService) ListChildren(parentID string) ([]*domain.User, error) {
+	children, err := s.userRepo.ListChildren(parentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		child.Hint = ""
+	}
+	return children, nil
+}
+
+// IssueChildResetCode creates a PIN reset code for one of parentID's
+// children.
+func (s *AuthService) IssueChildResetCode(parentID, childID string) (string, time.Time, error) {
+	child, err := s.userRepo.GetByID(childID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && child.ParentID != parentID) {
+		return "", time.Time{}, ErrNotYourChild
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return s.IssueResetCode(child.ID, parentID, nil)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unrecognised PIN hash format")
+
+// Hasher hashes PINs into a self-describing string that carries the
+// algorithm and its parameters, so old hashes keep verifying after the
+// configuration changes.
+type Hasher interface {
+	// Hash encodes pin with the hasher's current parameters.
+	Hash(pin string) (string, error)
+	// Recognizes reports whether encoded was produced by this algorithm.
+	Recognizes(encoded string) bool
+	// Verify checks pin against an encoded hash this hasher recognizes.
+	Verify(encoded, pin string) (bool, error)
+	// Current reports whether encoded uses the current parameters.
+	Current(encoded string) bool
+}
+
+// PINHashing hashes new PINs with the preferred hasher and verifies any
+// format it knows, flagging hashes that should be redone.
+type PINHashing struct {
+	preferred Hasher
+	known     []Hasher
+}
+
+// NewPINHashing hashes with preferred and verifies with it or any of
+// legacy.
+func NewPINHashing(preferred Hasher, legacy ...Hasher) *PINHashing {
+	return &PINHashing{preferred: preferred, known: append([]Hasher{preferred}, legacy...)}
+}
+
+// PINHashingFor builds the hashing setup for a configured algorithm,
+// "argon2id" or "bcrypt". Hashes in the other format keep verifying and
+// are upgraded on the next login.
+func PINHashingFor(algorithm string, params Argon2Params, bcryptCost int) (*PINHashing, error) {
+	argon, err := NewArgon2idHasher(params)
+	if err != nil {
+		return nil, err
+	}
+	bc, err := NewBcryptHasher(bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	switch algorithm {
+	case "argon2id":
+		return NewPINHashing(argon, bc), nil
+	case "bcrypt":
+		return NewPINHashing(bc, argon), nil
+	}
+	return nil, fmt.Errorf("unknown PIN hash algorithm %q", algorithm)
+}
+
+func (p *PINHashing) Hash(pin string) (string, error) {
+	return p.preferred.Hash(pin)
+}
+
+// Verify checks pin against encoded. needsRehash is true when the PIN was
+// right but encoded uses another algorithm or outdated parameters.
+func (p *PINHashing) Verify(encoded, pin string) (ok, needsRehash bool, err error) {
+	for _, h := range p.known {
+		if !h.Recognizes(encoded) {
+			continue
+		}
+		ok, err := h.Verify(encoded, pin)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, h != p.preferred || !h.Current(encoded), nil
+	}
+	return false, false, ErrUnknownHashFormat
+}
+
+// Argon2Params are the Argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for Argon2id.
+var DefaultArgon2Params = Argon2Params{Memory: 19 * 1024, Time: 2, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+// Argon2idHasher encodes hashes in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) (*Argon2idHasher, error) {
+	if params.Memory < 8*uint32(params.Threads) || params.Time < 1 || params.Threads < 1 || params.KeyLen < 16 || params.SaltLen < 8 {
+		return nil, fmt.Errorf("invalid argon2id parameters %+v", params)
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+func (h *Argon2idHasher) Hash(pin string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pin), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.params.Memory, h.params.Time, h.params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) Verify(encoded, pin string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(pin), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) Current(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	return err == nil && params == h.params
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("bad argon2 parameters %q: %w", parts[3], err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("bad argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("bad argon2 hash: %w", err)
+	}
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// BcryptHasher is the original PIN hash. Its encoding already carries the
+// cost.
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+func (h *BcryptHasher) Hash(pin string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), h.cost)
+	return string(hash), err
+}
+
+func (h *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) Verify(encoded, pin string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(pin))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *BcryptHasher) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == h.cost
+}
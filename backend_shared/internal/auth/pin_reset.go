@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// PinResetCodeTTL is how long a reset code can be redeemed. It is meant to
+// be read out to the user and used straight away.
+const PinResetCodeTTL = 30 * time.Minute
+
+// resetCodeAlphabet leaves out characters that are easily confused when
+// read aloud or copied by hand (0/O, 1/I/L).
+const resetCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+const resetCodeLength = 8
+
+var ErrInvalidResetCode = errors.New("invalid or expired reset code")
+
+// IssueResetCode creates a single-use code that lets userID choose a new
+// PIN, replacing any code issued before. issuedBy is who asked for it.
+// audit, if set, is written along with the code, with its expiry as the
+// details.
+func (s *AuthService) IssueResetCode(userID, issuedBy string, audit *domain.AuditEntry) (string, time.Time, error) {
+	b := make([]byte, resetCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	for i := range b {
+		b[i] = resetCodeAlphabet[int(b[i])%len(resetCodeAlphabet)]
+	}
+	code := string(b)
+
+	expiresAt := time.Now().Add(PinResetCodeTTL)
+	if audit != nil {
+		details, err := json.Marshal(map[string]any{"expires_at": expiresAt.UTC()})
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		audit.Details = details
+	}
+	if err := s.pinResets.Create(userID, hashSecret(code), issuedBy, expiresAt, audit); err != nil {
+		return "", time.Time{}, err
+	}
+	log.Info().Str("user_id", userID).Str("issued_by", issuedBy).Msg("PIN reset code issued")
+	return code, expiresAt, nil
+}
+
+// RedeemResetCode sets a new PIN for username if code is their current
+// reset code. Every existing session is revoked, so anyone holding the old
+// PIN's tokens is logged out, and a fresh session is started. Wrong codes
+// count as failed attempts like wrong PINs do.
+func (s *AuthService) RedeemResetCode(username, code, pin string, client Client) (*LoginResponse, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+	if err := s.guard.Attempt(username, client.IP); err != nil {
+		log.Warn().Err(err).Str("username", username).Str("ip", client.IP).Msg("PIN reset attempt refused")
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err == nil {
+		if user.SuspendedAt != nil {
+			return nil, ErrAccountSuspended
+		}
+		code = strings.ToUpper(strings.TrimSpace(code))
+		err = s.pinResets.Use(user.ID, hashSecret(code))
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warn().Str("username", username).Msg("Invalid PIN reset code")
+		if err := s.guard.Failure(username, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidResetCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.UpdatePIN(user.ID, pin, user.Hint); err != nil {
+		return nil, err
+	}
+	n, err := s.sessionRepo.RevokeAll(user.ID, "pin_reset")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.Success(username, client.IP); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("user_id", user.ID).Int("revoked_sessions", n).Msg("PIN reset with code")
+	return &LoginResponse{User: user, Token: tokens.Token, RefreshToken: tokens.RefreshToken, Status: "OK"}, nil
+}
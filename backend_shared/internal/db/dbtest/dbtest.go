@@ -0,0 +1,33 @@
+// Package dbtest gives tests a migrated database of their own.
+package dbtest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/ramanasai/local-game-play/internal/db"
+	"github.com/ramanasai/local-game-play/migrations"
+)
+
+// Open returns a database in a temporary directory with every migration
+// applied. It is closed when the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	database, err := db.Connect(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	goose.SetBaseFS(migrations.Embed)
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(database, "."); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return database
+}
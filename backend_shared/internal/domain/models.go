@@ -1,57 +1,329 @@
 package domain
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// Roles a user can have. Admins may manage other users.
+const (
+	RolePlayer = "player"
+	RoleAdmin  = "admin"
+)
+
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	PinHash   string    `json:"-"` // Never return in JSON
-	Hint      string    `json:"hint,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            string     `json:"id"`
+	Username      string     `json:"username"`
+	PinHash       string     `json:"-"`              // Never return in JSON
+	Hint          string     `json:"hint,omitempty"` // Only ever sent to the user themselves or an admin
+	Role          string     `json:"role"`
+	ParentID      string     `json:"parent_id,omitempty"`
+	Guest         bool       `json:"guest,omitempty"` // Plays without a PIN; scores stay off leaderboards until claimed
+	SuspendedAt   *time.Time `json:"suspended_at,omitempty"`
+	SuspendReason string     `json:"suspend_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
 }
 
-type Session struct {
-	ID        string    `json:"id"`
-	Token     string    `json:"token"`
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// UserExport is everything stored about one user, as handed to them.
+type UserExport struct {
+	ExportedAt         time.Time                            `json:"exported_at"`
+	Profile            *User                                `json:"profile"`
+	Sessions           []*Session                           `json:"sessions"`
+	Results            []*GameResult                        `json:"game_results"`
+	Standings          []*Standing                          `json:"season_standings"`
+	Unlocks            []*Unlock                            `json:"achievements"`
+	MemorySessions     []*SessionExport[*MemorySession]     `json:"memory_sessions"`
+	Game2048Sessions   []*SessionExport[*Game2048Session]   `json:"game2048_sessions"`
+	BlockBlastSessions []*SessionExport[*BlockBlastSession] `json:"blockblast_sessions"`
+	TicTacToeSessions  []*SessionExport[*TicTacToeSession]  `json:"tictactoe_sessions"`
+	PvPMatches         []*SessionExport[*PvPSession]        `json:"pvp_matches"`
+	PinResetCodes      []*PinResetCode                      `json:"pin_reset_codes"`
+	LoginFailures      []*LoginFailures                     `json:"login_failures"`
+	Lockouts           []*LockoutEvent                      `json:"lockouts"`
+	AuditEntries       []*AuditEntry                        `json:"admin_actions"` // Admin actions taken on the user
+}
+
+// SessionExport is a game session along with its log of play, which the
+// game APIs otherwise keep to themselves.
+type SessionExport[T any] struct {
+	Session T   `json:"session"`
+	Log     any `json:"log"`
 }
 
-type Score struct {
+// PinResetCode is a one-time code issued to reset a user's PIN. The code
+// itself is only ever stored hashed.
+type PinResetCode struct {
+	ID        string     `json:"id"`
+	IssuedBy  string     `json:"issued_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LockoutEvent records one lockout imposed after repeated failed PINs.
+type LockoutEvent struct {
 	ID          string    `json:"id"`
-	UserID      string    `json:"user_id"`
-	Moves       int       `json:"moves"`
-	TimeSeconds int       `json:"time_seconds"`
+	Scope       string    `json:"scope"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
 	CreatedAt   time.Time `json:"created_at"`
-	// Additional field for creating/showing leaderboard often includes username
-	Username string `json:"username,omitempty"`
 }
 
-type Score2048 struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Score     int       `json:"score"`
-	CreatedAt time.Time `json:"created_at"`
-	Username  string    `json:"username,omitempty"`
+// AuditEntry records one action an admin took.
+type AuditEntry struct {
+	ID             string          `json:"id"`
+	AdminID        string          `json:"admin_id"`
+	AdminUsername  string          `json:"admin_username"`
+	Action         string          `json:"action"`
+	TargetID       string          `json:"target_id,omitempty"`
+	TargetUsername string          `json:"target_username,omitempty"`
+	Details        json.RawMessage `json:"details"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Session is one login: a family of rotating refresh tokens. Token is the
+// hash of the current refresh token, never the token itself.
+type Session struct {
+	ID           string     `json:"id"`
+	Token        string     `json:"-"`
+	UserID       string     `json:"user_id"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `json:"ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RotatedAt    *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"` // logout, reuse, revoked
+}
+
+// LoginFailures counts recent failed PIN attempts for one username or IP.
+type LoginFailures struct {
+	Scope         string    `json:"scope"` // user, ip
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	Lockouts      int       `json:"lockouts"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
+
+// Orders results can be ranked in.
+const (
+	HigherFirst = "desc"
+	LowerFirst  = "asc"
+)
+
+// Ways a leaderboard combines one player's results.
+const (
+	AggregateBest  = "best"  // Each result is ranked on its own
+	AggregateTotal = "total" // Results' scores are summed per player
+)
+
+// Ranking says how a game's leaderboard orders results: by Score, then by
+// TieBreak if the game sets it, then earliest first.
+type Ranking struct {
+	Order     string `json:"order"`
+	TieBreak  string `json:"tie_break,omitempty"`
+	Aggregate string `json:"aggregate"`
+}
+
+// GameResult is one finished game of any kind, as kept in the shared
+// results store. Details holds the game's own fields, see games.Field.
+type GameResult struct {
+	ID         string          `json:"id"`
+	Game       string          `json:"game"`
+	Variant    string          `json:"variant,omitempty"` // e.g. the Tic-Tac-Toe ruleset; boards are per variant
+	UserID     string          `json:"user_id"`
+	Username   string          `json:"username,omitempty"`
+	SessionID  string          `json:"session_id,omitempty"` // The verified session or PvP match it came from
+	OpponentID string          `json:"opponent_id,omitempty"`
+	Score      int             `json:"score"`
+	TieBreak   int             `json:"tie_break,omitempty"`
+	Ranked     bool            `json:"ranked"` // False for unverified or flagged results, which stay off leaderboards
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// LeaderboardEntry is one player's line on a leaderboard. For AggregateBest
+// games it is the player's best result; for AggregateTotal, Score is the
+// player's sum. Players tied on Score and TieBreak share a Rank.
+type LeaderboardEntry struct {
+	Rank      int             `json:"rank"`
+	UserID    string          `json:"user_id"`
+	Username  string          `json:"username"`
+	Score     int             `json:"score"`
+	TieBreak  int             `json:"tie_break,omitempty"`
+	ResultID  string          `json:"result_id,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt *time.Time      `json:"created_at,omitempty"`
+}
+
+// LeaderboardPage is one page of a leaderboard. NextCursor fetches the
+// page after it and is empty on the last page.
+type LeaderboardPage struct {
+	Window     string             `json:"window"`
+	Season     *Season            `json:"season,omitempty"` // Set for the season window
+	Entries    []LeaderboardEntry `json:"entries"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// PlayerRank is where one player stands on a leaderboard. Percentile is the
+// share of ranked players at or below their rank, so the leader is at 100.
+type PlayerRank struct {
+	Window     string             `json:"window"`
+	Season     *Season            `json:"season,omitempty"`
+	Rank       int                `json:"rank"`
+	Players    int                `json:"players"`
+	Percentile float64            `json:"percentile"`
+	Entry      LeaderboardEntry   `json:"entry"`
+	Above      []LeaderboardEntry `json:"above"` // In board order, ending just above the player
+	Below      []LeaderboardEntry `json:"below"` // In board order, starting just below the player
+}
+
+// Season statuses, derived from the current time.
+const (
+	SeasonUpcoming = "upcoming"
+	SeasonActive   = "active"
+	SeasonEnded    = "ended"
+)
+
+// Season is a named stretch of time the leaderboards run for. Once it has
+// ended its final standings are archived and ArchivedAt is set.
+type Season struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     time.Time  `json:"ends_at"` // Exclusive
+	Status     string     `json:"status"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// Standing is one player's archived final place on a season's board.
+type Standing struct {
+	SeasonID string `json:"season_id"`
+	Game     string `json:"game"`
+	Variant  string `json:"variant,omitempty"`
+	LeaderboardEntry
+}
+
+// SeasonBoard is the archived final board of one game and variant.
+type SeasonBoard struct {
+	Game    string             `json:"game"`
+	Variant string             `json:"variant,omitempty"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// AchievementRule says which results unlock an achievement: Count of a
+// player's results that are for Game (any game if empty) and meet every
+// condition in Where. With EveryGameInADay it is instead unlocked by
+// results of every game on one UTC day. IDs are stored with unlocks, so a
+// rule keeps its ID for good; changing what it asks for needs a new one.
+type AchievementRule struct {
+	ID              string      `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Game            string      `json:"game,omitempty"`
+	Where           []Condition `json:"-"`
+	Count           int         `json:"-"` // 1 if zero
+	RankedOnly      bool        `json:"-"` // Leave out flagged runs and unranked matches
+	EveryGameInADay bool        `json:"-"`
+}
+
+// Condition compares one field of a result with Value. Field is score,
+// tie_break or one of the game's result fields; Op is =, !=, <, <=, > or
+// >=.
+type Condition struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// Achievement is a rule as shown to one player, with when they unlocked
+// it if they have.
+type Achievement struct {
+	AchievementRule
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// Unlock is one achievement a player has.
+type Unlock struct {
+	AchievementID string    `json:"achievement_id"`
+	UnlockedAt    time.Time `json:"unlocked_at"`
+}
+
+type MemorySession struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Seed       uint32     `json:"-"` // Never revealed; it would expose the layout
+	Pairs      int        `json:"pairs"`
+	DailyDate  string     `json:"daily_date,omitempty"` // Set for a daily challenge
+	Status     string     `json:"status"`               // active, finished
+	Flips      string     `json:"-"`                    // JSON-encoded flip log with server timestamps
+	FlipCount  int        `json:"flip_count"`
+	Suspicious bool       `json:"suspicious"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type Game2048Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Seed       uint32     `json:"seed"`
+	DailyDate  string     `json:"daily_date,omitempty"` // Set for a daily challenge
+	Status     string     `json:"status"`               // active, finished
+	Moves      string     `json:"-"`
+	Score      int        `json:"score"`
+	MaxTile    int        `json:"max_tile"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type BlockBlastSession struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Seed           uint32     `json:"-"`                    // Never revealed; it would expose upcoming trays
+	DailyDate      string     `json:"daily_date,omitempty"` // Set for a daily challenge
+	Status         string     `json:"status"`               // active, finished
+	Placements     string     `json:"-"`                    // JSON-encoded placement log
+	PlacementCount int        `json:"placement_count"`
+	Score          int        `json:"score"`
+	CreatedAt      time.Time  `json:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
 }
 
-type ScoreBlockBlast struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Score     int       `json:"score"`
-	CreatedAt time.Time `json:"created_at"`
-	Username  string    `json:"username,omitempty"`
+type TicTacToeSession struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Difficulty string     `json:"difficulty"`
+	Ruleset    string     `json:"ruleset"` // e.g. 3x3-k3-p3, see tictactoe.Rules
+	Status     string     `json:"status"`  // active, finished
+	Moves      string     `json:"-"`       // JSON-encoded list of cell indices, X first
+	MoveCount  int        `json:"move_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
 
-type Match struct {
-	ID         string    `json:"id"`
-	UserID     string    `json:"user_id"`
-	Difficulty string    `json:"difficulty"`
-	Result     string    `json:"result"`
-	Moves      int       `json:"moves"`
-	CreatedAt  time.Time `json:"created_at"`
+// PvPSession is a human-vs-human Tic-Tac-Toe match. The creator plays X;
+// OUserID stays empty until someone joins.
+type PvPSession struct {
+	ID            string     `json:"id"`
+	XUserID       string     `json:"x_user_id"`
+	XUsername     string     `json:"x_username"`
+	OUserID       string     `json:"o_user_id,omitempty"`
+	OUsername     string     `json:"o_username,omitempty"`
+	Ruleset       string     `json:"ruleset"`
+	Status        string     `json:"status"` // waiting, active, finished
+	Moves         string     `json:"-"`      // JSON-encoded list of cell indices, X first
+	MoveCount     int        `json:"move_count"`
+	Winner        string     `json:"winner,omitempty"`     // X or O, empty for a draw
+	EndReason     string     `json:"end_reason,omitempty"` // line, max_plies, timeout, resign
+	TurnStartedAt *time.Time `json:"turn_started_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
 }
 
 type StatsSummary struct {
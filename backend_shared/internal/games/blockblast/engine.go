@@ -0,0 +1,246 @@
+package blockblast
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+const (
+	Size     = 8
+	TraySize = 3
+
+	// LinePoints is awarded per cleared line, multiplied by the number of
+	// lines cleared at once and by the current combo.
+	LinePoints = 10
+	// ComboGrace is how many placements may go by without a clear before the
+	// combo counter resets.
+	ComboGrace = 3
+)
+
+var ErrIllegalPlacement = errors.New("illegal placement")
+
+type Cell struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+type Piece struct {
+	ID    string `json:"id"`
+	Cells []Cell `json:"cells"`
+}
+
+// Pieces is the catalogue trays are dealt from. The order is part of the
+// deal, so new shapes must only ever be appended.
+var Pieces = []Piece{
+	piece("dot", "X"),
+	piece("h2", "XX"),
+	piece("v2", "X", "X"),
+	piece("h3", "XXX"),
+	piece("v3", "X", "X", "X"),
+	piece("h4", "XXXX"),
+	piece("v4", "X", "X", "X", "X"),
+	piece("h5", "XXXXX"),
+	piece("v5", "X", "X", "X", "X", "X"),
+	piece("sq2", "XX", "XX"),
+	piece("sq3", "XXX", "XXX", "XXX"),
+	piece("rect23", "XXX", "XXX"),
+	piece("rect32", "XX", "XX", "XX"),
+	piece("corner_a", "XX", "X."),
+	piece("corner_b", "XX", ".X"),
+	piece("corner_c", "X.", "XX"),
+	piece("corner_d", ".X", "XX"),
+	piece("l_a", "X.", "X.", "XX"),
+	piece("l_b", "XXX", "X.."),
+	piece("l_c", "XX", ".X", ".X"),
+	piece("l_d", "..X", "XXX"),
+	piece("j_a", ".X", ".X", "XX"),
+	piece("j_b", "X..", "XXX"),
+	piece("j_c", "XX", "X.", "X."),
+	piece("j_d", "XXX", "..X"),
+	piece("t_a", "XXX", ".X."),
+	piece("t_b", ".X", "XX", ".X"),
+	piece("t_c", ".X.", "XXX"),
+	piece("t_d", "X.", "XX", "X."),
+	piece("s_h", ".XX", "XX."),
+	piece("s_v", "X.", "XX", ".X"),
+	piece("z_h", "XX.", ".XX"),
+	piece("z_v", ".X", "XX", "X."),
+	piece("big_corner_a", "XXX", "X..", "X.."),
+	piece("big_corner_b", "XXX", "..X", "..X"),
+	piece("big_corner_c", "X..", "X..", "XXX"),
+	piece("big_corner_d", "..X", "..X", "XXX"),
+}
+
+func piece(id string, rows ...string) Piece {
+	p := Piece{ID: id}
+	for r, row := range rows {
+		for c, ch := range row {
+			if ch == 'X' {
+				p.Cells = append(p.Cells, Cell{Row: r, Col: c})
+			}
+		}
+	}
+	return p
+}
+
+// Placement puts the piece in tray slot Slot with its top-left corner at
+// (Row, Col).
+type Placement struct {
+	Slot int `json:"slot"`
+	Row  int `json:"row"`
+	Col  int `json:"col"`
+}
+
+// Game is a deterministic Block Blast game. Trays are dealt from a
+// Mulberry32 stream: each of the three slots gets Pieces[Intn(len(Pieces))],
+// and a new tray is dealt only once all three pieces have been placed.
+type Game struct {
+	Grid       [Size][Size]bool `json:"grid"`
+	Tray       [TraySize]*Piece `json:"tray"` // nil once placed
+	Score      int              `json:"score"`
+	Combo      int              `json:"combo"`
+	Placements int              `json:"placements"`
+	GameOver   bool             `json:"game_over"`
+
+	sinceClear int
+	rand       *rng.Mulberry32
+}
+
+// PlaceResult describes what a single placement did.
+type PlaceResult struct {
+	Points       int `json:"points"`
+	LinesCleared int `json:"lines_cleared"`
+}
+
+func NewGame(seed uint32) *Game {
+	g := &Game{rand: rng.New(seed)}
+	g.deal()
+	g.GameOver = !g.anyFits()
+	return g
+}
+
+// Place validates and applies p. An illegal placement returns an error
+// wrapping ErrIllegalPlacement and leaves the game unchanged.
+func (g *Game) Place(p Placement) (PlaceResult, error) {
+	if g.GameOver {
+		return PlaceResult{}, fmt.Errorf("%w: game is over", ErrIllegalPlacement)
+	}
+	if p.Slot < 0 || p.Slot >= TraySize || g.Tray[p.Slot] == nil {
+		return PlaceResult{}, fmt.Errorf("%w: tray slot %d is empty", ErrIllegalPlacement, p.Slot)
+	}
+	pc := g.Tray[p.Slot]
+	if !g.fits(pc, p.Row, p.Col) {
+		return PlaceResult{}, fmt.Errorf("%w: %s does not fit at (%d,%d)", ErrIllegalPlacement, pc.ID, p.Row, p.Col)
+	}
+
+	for _, c := range pc.Cells {
+		g.Grid[p.Row+c.Row][p.Col+c.Col] = true
+	}
+	g.Tray[p.Slot] = nil
+	g.Placements++
+
+	res := PlaceResult{Points: len(pc.Cells)}
+	res.LinesCleared = g.clearLines()
+	if res.LinesCleared > 0 {
+		g.Combo++
+		g.sinceClear = 0
+		res.Points += LinePoints * res.LinesCleared * res.LinesCleared * g.Combo
+	} else {
+		g.sinceClear++
+		if g.sinceClear >= ComboGrace {
+			g.Combo = 0
+		}
+	}
+	g.Score += res.Points
+
+	if g.trayEmpty() {
+		g.deal()
+	}
+	g.GameOver = !g.anyFits()
+	return res, nil
+}
+
+// clearLines removes every full row and column at once and returns how
+// many were cleared.
+func (g *Game) clearLines() int {
+	var rows, cols []int
+	for i := 0; i < Size; i++ {
+		rowFull, colFull := true, true
+		for j := 0; j < Size; j++ {
+			rowFull = rowFull && g.Grid[i][j]
+			colFull = colFull && g.Grid[j][i]
+		}
+		if rowFull {
+			rows = append(rows, i)
+		}
+		if colFull {
+			cols = append(cols, i)
+		}
+	}
+	for _, r := range rows {
+		for c := 0; c < Size; c++ {
+			g.Grid[r][c] = false
+		}
+	}
+	for _, c := range cols {
+		for r := 0; r < Size; r++ {
+			g.Grid[r][c] = false
+		}
+	}
+	return len(rows) + len(cols)
+}
+
+func (g *Game) fits(p *Piece, row, col int) bool {
+	for _, c := range p.Cells {
+		r, cc := row+c.Row, col+c.Col
+		if r < 0 || r >= Size || cc < 0 || cc >= Size || g.Grid[r][cc] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) anyFits() bool {
+	for _, p := range g.Tray {
+		if p == nil {
+			continue
+		}
+		for r := 0; r < Size; r++ {
+			for c := 0; c < Size; c++ {
+				if g.fits(p, r, c) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (g *Game) trayEmpty() bool {
+	for _, p := range g.Tray {
+		if p != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) deal() {
+	for i := range g.Tray {
+		g.Tray[i] = &Pieces[g.rand.Intn(len(Pieces))]
+	}
+}
+
+// Replay plays placements from a fresh game seeded with seed, stopping at
+// the first illegal one.
+func Replay(seed uint32, placements []Placement) (*Game, error) {
+	g := NewGame(seed)
+	for i, p := range placements {
+		if _, err := g.Place(p); err != nil {
+			return nil, fmt.Errorf("placement %d: %w", i+1, err)
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,292 @@
+package blockblast
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+func pieceByID(t *testing.T, id string) *Piece {
+	t.Helper()
+	for i := range Pieces {
+		if Pieces[i].ID == id {
+			return &Pieces[i]
+		}
+	}
+	t.Fatalf("no piece %q", id)
+	return nil
+}
+
+// newTestGame returns a game with the given pieces in its tray and the
+// listed cells filled.
+func newTestGame(t *testing.T, tray []string, filled ...Cell) *Game {
+	t.Helper()
+	g := &Game{rand: rng.New(1)}
+	for i, id := range tray {
+		g.Tray[i] = pieceByID(t, id)
+	}
+	for _, c := range filled {
+		g.Grid[c.Row][c.Col] = true
+	}
+	return g
+}
+
+func row(r int, skip int) []Cell {
+	var cells []Cell
+	for c := 0; c < Size; c++ {
+		if c != skip {
+			cells = append(cells, Cell{r, c})
+		}
+	}
+	return cells
+}
+
+func col(c int, skip int) []Cell {
+	var cells []Cell
+	for r := 0; r < Size; r++ {
+		if r != skip {
+			cells = append(cells, Cell{r, c})
+		}
+	}
+	return cells
+}
+
+func TestPlaceClearsLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		piece  string
+		filled []Cell
+		at     Placement
+		lines  int
+		points int
+		left   int // filled cells afterwards
+	}{
+		{
+			name:   "no clear scores the piece's cells",
+			piece:  "sq2",
+			at:     Placement{Row: 3, Col: 3},
+			points: 4,
+			left:   4,
+		},
+		{
+			name:   "one row",
+			piece:  "dot",
+			filled: row(2, 5),
+			at:     Placement{Row: 2, Col: 5},
+			lines:  1,
+			points: 1 + LinePoints,
+			left:   0,
+		},
+		{
+			name:   "one column",
+			piece:  "v2",
+			filled: col(6, -1)[2:],
+			at:     Placement{Row: 0, Col: 6},
+			lines:  1,
+			points: 2 + LinePoints,
+			left:   0,
+		},
+		{
+			name:   "row and column through the same cell",
+			piece:  "dot",
+			filled: append(row(0, 7), col(7, 0)...),
+			at:     Placement{Row: 0, Col: 7},
+			lines:  2,
+			points: 1 + LinePoints*2*2,
+			left:   0,
+		},
+		{
+			name:   "two rows at once",
+			piece:  "v2",
+			filled: append(row(4, 0), row(5, 0)...),
+			at:     Placement{Row: 4, Col: 0},
+			lines:  2,
+			points: 2 + LinePoints*2*2,
+			left:   0,
+		},
+		{
+			name:   "cells outside the cleared line stay",
+			piece:  "v2",
+			filled: row(7, 3),
+			at:     Placement{Row: 6, Col: 3},
+			lines:  1,
+			points: 2 + LinePoints,
+			left:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, []string{tt.piece, "dot", "dot"}, tt.filled...)
+			res, err := g.Place(tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.LinesCleared != tt.lines || res.Points != tt.points {
+				t.Errorf("got %d lines for %d points, want %d for %d", res.LinesCleared, res.Points, tt.lines, tt.points)
+			}
+			if g.Score != tt.points {
+				t.Errorf("score %d, want %d", g.Score, tt.points)
+			}
+			left := 0
+			for r := 0; r < Size; r++ {
+				for c := 0; c < Size; c++ {
+					if g.Grid[r][c] {
+						left++
+					}
+				}
+			}
+			if left != tt.left {
+				t.Errorf("%d cells filled afterwards, want %d", left, tt.left)
+			}
+		})
+	}
+}
+
+func TestCombo(t *testing.T) {
+	// Rows 0-2 are full but for column 0; each dot there clears a row.
+	var filled []Cell
+	for r := 0; r < 3; r++ {
+		filled = append(filled, row(r, 0)...)
+	}
+	g := newTestGame(t, []string{"dot", "dot", "dot"}, filled...)
+
+	for i, want := range []int{1 + LinePoints, 1 + LinePoints*2, 1 + LinePoints*3} {
+		g.Tray[0] = pieceByID(t, "dot")
+		res, err := g.Place(Placement{Slot: 0, Row: i, Col: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Points != want || g.Combo != i+1 {
+			t.Fatalf("clear %d: %d points at combo %d, want %d at combo %d", i+1, res.Points, g.Combo, want, i+1)
+		}
+	}
+
+	// The combo survives ComboGrace-1 placements without a clear and
+	// resets on the next.
+	for i := 0; i < ComboGrace; i++ {
+		g.Tray[0] = pieceByID(t, "dot")
+		if _, err := g.Place(Placement{Slot: 0, Row: 7, Col: i * 2}); err != nil {
+			t.Fatal(err)
+		}
+		want := 3
+		if i == ComboGrace-1 {
+			want = 0
+		}
+		if g.Combo != want {
+			t.Fatalf("after %d placements without a clear combo is %d, want %d", i+1, g.Combo, want)
+		}
+	}
+}
+
+func TestPlaceRejectsIllegal(t *testing.T) {
+	tests := []struct {
+		name string
+		at   Placement
+	}{
+		{"slot out of range", Placement{Slot: 3}},
+		{"negative slot", Placement{Slot: -1}},
+		{"empty slot", Placement{Slot: 2}},
+		{"off the right edge", Placement{Slot: 0, Row: 0, Col: Size - 2}},
+		{"off the bottom edge", Placement{Slot: 0, Row: Size - 1, Col: 0}},
+		{"negative position", Placement{Slot: 0, Row: -1, Col: 0}},
+		{"overlaps a filled cell", Placement{Slot: 0, Row: 3, Col: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, []string{"sq3", "dot"}, Cell{4, 4})
+			before := *g
+			if _, err := g.Place(tt.at); !errors.Is(err, ErrIllegalPlacement) {
+				t.Fatalf("got %v, want ErrIllegalPlacement", err)
+			}
+			if g.Grid != before.Grid || g.Tray != before.Tray || g.Score != 0 || g.Placements != 0 {
+				t.Error("illegal placement changed the game")
+			}
+		})
+	}
+
+	g := newTestGame(t, []string{"dot"})
+	g.GameOver = true
+	if _, err := g.Place(Placement{}); !errors.Is(err, ErrIllegalPlacement) {
+		t.Errorf("placing after game over: got %v, want ErrIllegalPlacement", err)
+	}
+}
+
+func TestTrayDealtWhenEmpty(t *testing.T) {
+	g := NewGame(99)
+	first := g.Tray
+	for slot := 0; slot < TraySize; slot++ {
+		for i := slot; i < TraySize; i++ {
+			if g.Tray[i] != first[i] {
+				t.Fatalf("slot %d changed after %d placements", i, slot)
+			}
+		}
+		placeAnywhere(t, g, slot)
+	}
+	for i, p := range g.Tray {
+		if p == nil {
+			t.Fatalf("slot %d empty after the tray was used up", i)
+		}
+	}
+}
+
+// placeAnywhere places the piece in slot at the first position it fits.
+func placeAnywhere(t *testing.T, g *Game, slot int) Placement {
+	t.Helper()
+	for r := 0; r < Size; r++ {
+		for c := 0; c < Size; c++ {
+			if g.fits(g.Tray[slot], r, c) {
+				p := Placement{Slot: slot, Row: r, Col: c}
+				if _, err := g.Place(p); err != nil {
+					t.Fatal(err)
+				}
+				return p
+			}
+		}
+	}
+	t.Fatalf("%s fits nowhere", g.Tray[slot].ID)
+	return Placement{}
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	const seed = 4242
+	live := NewGame(seed)
+	var placements []Placement
+	for !live.GameOver && len(placements) < 300 {
+		for slot, p := range live.Tray {
+			if p != nil && fitsAnywhere(live, p) {
+				placements = append(placements, placeAnywhere(t, live, slot))
+				break
+			}
+		}
+	}
+
+	if len(placements) < 10 {
+		t.Fatalf("game over after only %d placements", len(placements))
+	}
+
+	for i := 0; i < 2; i++ {
+		g, err := Replay(seed, placements)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Grid != live.Grid || g.Tray != live.Tray || g.Score != live.Score || g.GameOver != live.GameOver {
+			t.Fatalf("replay %d ended with score %d, live game with %d", i, g.Score, live.Score)
+		}
+	}
+
+	if _, err := Replay(seed, append(placements[:1:1], placements[0])); !errors.Is(err, ErrIllegalPlacement) {
+		t.Errorf("replaying a used slot: got %v, want ErrIllegalPlacement", err)
+	}
+}
+
+func fitsAnywhere(g *Game, p *Piece) bool {
+	for r := 0; r < Size; r++ {
+		for c := 0; c < Size; c++ {
+			if g.fits(p, r, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package blockblast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+)
+
+// ResultDetails are the fields of a Block Blast result.
+type ResultDetails struct {
+	Placements int `json:"placements"`
+}
+
+func (s *Service) ID() string   { return "blockblast" }
+func (s *Service) Name() string { return "Block Blast" }
+
+func (s *Service) Schema() []games.Field {
+	return []games.Field{
+		{Name: "placements", Description: "Pieces placed"},
+	}
+}
+
+func (s *Service) Ranking() domain.Ranking {
+	return domain.Ranking{Order: domain.HigherFirst, Aggregate: domain.AggregateBest}
+}
+
+func (s *Service) Variant(raw string) (string, error) {
+	return games.DailyVariants(raw)
+}
+
+func (s *Service) Validate(result *domain.GameResult) error {
+	var d ResultDetails
+	if err := json.Unmarshal(result.Details, &d); err != nil {
+		return err
+	}
+	if d.Placements < 0 {
+		return fmt.Errorf("negative placement count %d", d.Placements)
+	}
+	if result.Score < 0 {
+		return fmt.Errorf("negative score %d", result.Score)
+	}
+	return nil
+}
+
+// newResult describes a finished session as a result.
+func (s *Service) newResult(session *domain.BlockBlastSession) (*domain.GameResult, error) {
+	details, err := json.Marshal(ResultDetails{Placements: session.PlacementCount})
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.GameResult{
+		Game:      s.ID(),
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Variant:   daily.Variant(session.DailyDate),
+		Score:     session.Score,
+		Ranked:    true,
+		Details:   details,
+	}
+	return result, games.Check(s, result)
+}
@@ -1,24 +1,191 @@
 package blockblast
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+	"github.com/ramanasai/local-game-play/internal/games/rng"
 	"github.com/ramanasai/local-game-play/internal/repos"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionClosed   = errors.New("session already finished")
+	ErrSessionBusy     = errors.New("session was updated by another request")
+)
+
 type Service struct {
-	repo *repos.BlockBlastRepo
+	repo   *repos.BlockBlastRepo
+	seeder *daily.Seeder
+	events *games.Events
+}
+
+func NewService(repo *repos.BlockBlastRepo, seeder *daily.Seeder, events *games.Events) *Service {
+	return &Service{repo: repo, seeder: seeder, events: events}
+}
+
+// GameState is what the client sees of a session: the board and tray, never
+// the seed.
+type GameState struct {
+	SessionID string       `json:"session_id"`
+	Status    string       `json:"status"`
+	DailyDate string       `json:"daily_date,omitempty"`
+	Game      *Game        `json:"game"`
+	Last      *PlaceResult `json:"last,omitempty"`
 }
 
-func NewService(repo *repos.BlockBlastRepo) *Service {
-	return &Service{repo: repo}
+func (s *Service) StartSession(userID string) (*GameState, error) {
+	seed, err := rng.NewSeed()
+	if err != nil {
+		log.Error().Err(err).Msg("BlockBlast Service: Failed to generate seed")
+		return nil, err
+	}
+
+	session, err := s.repo.CreateSession(userID, seed, "")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Msg("BlockBlast Service: Started session")
+	return &GameState{SessionID: session.ID, Status: session.Status, DailyDate: session.DailyDate, Game: NewGame(seed)}, nil
+}
+
+// StartDaily starts the user's one attempt at today's challenge, dealing
+// everyone the same trays.
+func (s *Service) StartDaily(userID string) (*GameState, error) {
+	date := daily.Date(time.Now())
+	seed := s.seeder.Seed(s.ID(), date)
+
+	session, err := s.repo.CreateSession(userID, seed, date)
+	if err != nil {
+		if errors.Is(err, repos.ErrDailyPlayed) {
+			return nil, daily.ErrPlayed
+		}
+		return nil, err
+	}
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Str("date", date).Msg("BlockBlast Service: Started daily challenge")
+	return &GameState{SessionID: session.ID, Status: session.Status, DailyDate: session.DailyDate, Game: NewGame(seed)}, nil
 }
 
-func (s *Service) SubmitScore(userID string, score int) error {
-	log.Debug().Str("user_id", userID).Int("score", score).Msg("BlockBlast Service: Submitting score")
-	return s.repo.SaveScore(userID, score)
+func (s *Service) HasPlayedDaily(userID, date string) (bool, error) {
+	return s.repo.HasDailySession(userID, date)
 }
 
-func (s *Service) GetLeaderboard(limit int) ([]domain.ScoreBlockBlast, error) {
-	return s.repo.GetLeaderboard(limit)
+func (s *Service) GetState(userID, sessionID string) (*GameState, error) {
+	session, game, _, err := s.load(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &GameState{SessionID: session.ID, Status: session.Status, DailyDate: session.DailyDate, Game: game}, nil
+}
+
+// Place validates a single placement against the server-side game. When it
+// leaves no piece that fits, the session is finished and scored right away.
+func (s *Service) Place(userID, sessionID string, p Placement) (*GameState, error) {
+	session, game, placements, err := s.load(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "active" {
+		return nil, ErrSessionClosed
+	}
+
+	res, err := game.Place(p)
+	if err != nil {
+		log.Warn().Err(err).Str("session_id", sessionID).Msg("BlockBlast Service: Rejected placement")
+		return nil, err
+	}
+
+	prevCount := session.PlacementCount
+	encoded, err := json.Marshal(append(placements, p))
+	if err != nil {
+		return nil, err
+	}
+	session.Placements = string(encoded)
+	session.PlacementCount = game.Placements
+	session.Score = game.Score
+
+	var result *domain.GameResult
+	if game.GameOver {
+		if result, err = s.newResult(session); err == nil {
+			err = s.repo.FinishSession(session, prevCount, result)
+		}
+		session.Status = "finished"
+		log.Debug().Str("user_id", userID).Int("score", session.Score).Msg("BlockBlast Service: Game over, score recorded")
+	} else {
+		err = s.repo.UpdateSession(session, prevCount)
+	}
+	if err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrSessionBusy
+		}
+		return nil, err
+	}
+	if result != nil {
+		s.events.Publish(result)
+	}
+
+	return &GameState{SessionID: session.ID, Status: session.Status, DailyDate: session.DailyDate, Game: game, Last: &res}, nil
+}
+
+// SubmitScore ends a session early (the player gives up) and records the
+// score computed so far.
+func (s *Service) SubmitScore(userID, sessionID string) (*domain.BlockBlastSession, error) {
+	session, game, _, err := s.load(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "active" {
+		return nil, ErrSessionClosed
+	}
+
+	session.Score = game.Score
+	log.Debug().Str("user_id", userID).Int("score", session.Score).Msg("BlockBlast Service: Submitting score")
+	result, err := s.newResult(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.FinishSession(session, session.PlacementCount, result); err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrSessionBusy
+		}
+		return nil, err
+	}
+	session.Status = "finished"
+	s.events.Publish(result)
+	return session, nil
+}
+
+// load fetches a session owned by userID and rebuilds its game by replaying
+// the stored placement log.
+func (s *Service) load(userID, sessionID string) (*domain.BlockBlastSession, *Game, []Placement, error) {
+	session, err := s.repo.GetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil, ErrSessionNotFound
+		}
+		return nil, nil, nil, err
+	}
+	if session.UserID != userID {
+		return nil, nil, nil, ErrSessionNotFound
+	}
+
+	var placements []Placement
+	if err := json.Unmarshal([]byte(session.Placements), &placements); err != nil {
+		log.Error().Err(err).Str("session_id", sessionID).Msg("BlockBlast Service: Corrupt placement log")
+		return nil, nil, nil, err
+	}
+	game, err := Replay(session.Seed, placements)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", sessionID).Msg("BlockBlast Service: Stored placement log no longer replays")
+		return nil, nil, nil, err
+	}
+	return session, game, placements, nil
 }
@@ -0,0 +1,125 @@
+package games
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+)
+
+// DailyGame is a Game with a daily challenge. Its results from a challenge
+// carry the challenge's variant, see daily.Variant, so each day has a board
+// of its own next to the game's main one.
+type DailyGame interface {
+	Game
+	// HasPlayedDaily tells whether the user used their attempt at the
+	// challenge of date.
+	HasPlayedDaily(userID, date string) (bool, error)
+}
+
+// DailyVariants implements Game.Variant for games whose only boards besides
+// the main one are their daily challenges'.
+func DailyVariants(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	if _, ok := daily.ParseVariant(raw); !ok {
+		return "", fmt.Errorf("%w: %q is not a daily challenge", ErrInvalidVariant, raw)
+	}
+	return raw, nil
+}
+
+// DailyBoard is one page of a day's challenge board.
+type DailyBoard struct {
+	Date string `json:"date"`
+	*domain.LeaderboardPage
+}
+
+// DailyChallenge is how a user fares at one game's challenges.
+type DailyChallenge struct {
+	Game string `json:"game"`
+	// Played is set once today's attempt is used, finished or not.
+	Played bool `json:"played"`
+	// Result is today's line on the board, once finished and ranked.
+	Result        *domain.PlayerRank `json:"result,omitempty"`
+	CurrentStreak int                `json:"current_streak"`
+	BestStreak    int                `json:"best_streak"`
+}
+
+// DailySummary is a user's view of today's challenges.
+type DailySummary struct {
+	Date  string           `json:"date"`
+	Games []DailyChallenge `json:"games"`
+}
+
+// dailyGame returns gameID if it has a daily challenge.
+func (s *Service) dailyGame(gameID string) (DailyGame, error) {
+	g, err := s.registry.Get(gameID)
+	if err != nil {
+		return nil, err
+	}
+	dg, ok := g.(DailyGame)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q has no daily challenge", ErrUnknownGame, gameID)
+	}
+	return dg, nil
+}
+
+// DailyLeaderboard returns a page of gameID's challenge board of date,
+// today's if empty.
+func (s *Service) DailyLeaderboard(gameID, date, cursor string, limit int) (*DailyBoard, error) {
+	g, err := s.dailyGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		date = daily.Date(time.Now())
+	}
+	page, err := s.Leaderboard(g.ID(), daily.Variant(date), WindowAll, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &DailyBoard{Date: date, LeaderboardPage: page}, nil
+}
+
+// DailySummary returns where userID stands in today's challenge of every
+// game that has one, with their streaks: runs of consecutive days they
+// finished the challenge on.
+func (s *Service) DailySummary(userID string) (*DailySummary, error) {
+	today := daily.Date(time.Now())
+	summary := &DailySummary{Date: today, Games: []DailyChallenge{}}
+	for _, g := range s.registry.All() {
+		dg, ok := g.(DailyGame)
+		if !ok {
+			continue
+		}
+		played, err := dg.HasPlayedDaily(userID, today)
+		if err != nil {
+			return nil, err
+		}
+		challenge := DailyChallenge{Game: g.ID(), Played: played}
+
+		if played {
+			challenge.Result, err = s.PlayerRank(g.ID(), daily.Variant(today), WindowAll, userID, 0)
+			if err != nil && !errors.Is(err, ErrNotRanked) {
+				return nil, err
+			}
+		}
+
+		variants, err := s.resultRepo.UserVariants(g.ID(), userID, daily.VariantPrefix)
+		if err != nil {
+			return nil, err
+		}
+		dates := make([]string, 0, len(variants))
+		for _, v := range variants {
+			if date, ok := daily.ParseVariant(v); ok {
+				dates = append(dates, date)
+			}
+		}
+		challenge.CurrentStreak, challenge.BestStreak = daily.Streaks(dates, today)
+		summary.Games = append(summary.Games, challenge)
+	}
+	return summary, nil
+}
@@ -0,0 +1,138 @@
+// Package daily is the daily challenge: one puzzle per game per day that
+// everyone plays from the same seed. Seeds are derived from the date and a
+// server secret, so nobody can work out tomorrow's puzzle in advance.
+package daily
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrPlayed is returned when a user starts a challenge they already had
+// their one attempt at.
+var ErrPlayed = errors.New("today's challenge was already attempted")
+
+// VariantPrefix starts the variant results of a challenge are stored
+// under, so each day has a separate board.
+const VariantPrefix = "daily:"
+
+// Date is the challenge date at t. Days start at midnight UTC.
+func Date(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// Variant is the result variant of the challenge on date; for no date it's
+// the main board's.
+func Variant(date string) string {
+	if date == "" {
+		return ""
+	}
+	return VariantPrefix + date
+}
+
+// ParseVariant returns the date of a challenge variant, or false if
+// variant isn't one.
+func ParseVariant(variant string) (string, bool) {
+	date, ok := strings.CutPrefix(variant, VariantPrefix)
+	if !ok {
+		return "", false
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return "", false
+	}
+	return date, true
+}
+
+// Seeder derives challenge seeds.
+type Seeder struct {
+	secret []byte
+}
+
+func NewSeeder(secret []byte) *Seeder {
+	return &Seeder{secret: secret}
+}
+
+// Seed is the seed of game's challenge on date: the first four bytes of
+// HMAC-SHA256(secret, "game:date").
+func (s *Seeder) Seed(game, date string) uint32 {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(game + ":" + date))
+	return binary.LittleEndian.Uint32(mac.Sum(nil))
+}
+
+// LoadSecret returns configured if set. Otherwise it reads the secret kept
+// at path, generating it on first boot; it must stay the same across
+// restarts or today's challenge would change under the players.
+func LoadSecret(path, configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse daily secret %s: %w", path, err)
+		}
+		return secret, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read daily secret %s: %w", path, err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create daily secret directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write daily secret: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to write daily secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Streaks returns the current and longest runs of consecutive days among
+// dates, which must be distinct and newest first. The current run still
+// counts if it ended yesterday, as today's challenge may be yet to come.
+func Streaks(dates []string, today string) (current, best int) {
+	var days []time.Time
+	for _, raw := range dates {
+		if day, err := time.Parse(time.DateOnly, raw); err == nil {
+			days = append(days, day)
+		}
+	}
+
+	run := 0
+	for i, day := range days {
+		if i > 0 && day.Equal(days[i-1].AddDate(0, 0, -1)) {
+			run++
+		} else {
+			run = 1
+		}
+		best = max(best, run)
+		if run == i+1 {
+			current = run
+		}
+	}
+
+	end, err := time.Parse(time.DateOnly, today)
+	if err != nil || len(days) == 0 || days[0].Before(end.AddDate(0, 0, -1)) {
+		current = 0
+	}
+	return current, best
+}
@@ -0,0 +1,54 @@
+package daily
+
+import "testing"
+
+func TestStreaks(t *testing.T) {
+	const today = "2026-03-10"
+	tests := []struct {
+		name          string
+		dates         []string
+		current, best int
+	}{
+		{"none", nil, 0, 0},
+		{"today", []string{"2026-03-10"}, 1, 1},
+		{"yesterday still counts", []string{"2026-03-09"}, 1, 1},
+		{"two days ago is broken", []string{"2026-03-08"}, 0, 1},
+		{"run up to today", []string{"2026-03-10", "2026-03-09", "2026-03-08"}, 3, 3},
+		{"run up to yesterday", []string{"2026-03-09", "2026-03-08"}, 2, 2},
+		{"gap then longer run", []string{"2026-03-10", "2026-03-09", "2026-03-05", "2026-03-04", "2026-03-03"}, 2, 3},
+		{"broken current run", []string{"2026-03-07", "2026-03-06", "2026-03-05", "2026-03-01"}, 0, 3},
+		{"across a month", []string{"2026-03-01", "2026-02-28", "2026-02-27"}, 0, 3},
+		{"across a year", []string{"2026-01-01", "2025-12-31"}, 0, 2},
+		{"leap day", []string{"2024-03-01", "2024-02-29", "2024-02-28"}, 0, 3},
+		{"unparseable dates are skipped", []string{"2026-03-10", "garbage", "2026-03-09"}, 2, 2},
+	}
+	for _, tt := range tests {
+		current, best := Streaks(tt.dates, today)
+		if current != tt.current || best != tt.best {
+			t.Errorf("%s: Streaks = %d, %d; want %d, %d", tt.name, current, best, tt.current, tt.best)
+		}
+	}
+	if current, best := Streaks([]string{"2026-03-10"}, "not a date"); current != 0 || best != 1 {
+		t.Errorf("bad today: Streaks = %d, %d; want 0, 1", current, best)
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	tests := []struct {
+		variant string
+		date    string
+		ok      bool
+	}{
+		{Variant("2026-03-10"), "2026-03-10", true},
+		{"daily:2026-02-30", "", false},
+		{"daily:", "", false},
+		{"", "", false},
+		{"8", "", false},
+	}
+	for _, tt := range tests {
+		date, ok := ParseVariant(tt.variant)
+		if date != tt.date || ok != tt.ok {
+			t.Errorf("ParseVariant(%q) = %q, %v; want %q, %v", tt.variant, date, ok, tt.date, tt.ok)
+		}
+	}
+}
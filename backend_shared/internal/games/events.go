@@ -0,0 +1,42 @@
+package games
+
+import (
+	"sync"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+)
+
+// Events tells subscribers about results as the game services record
+// them: scores from the single-player games and both sides of finished
+// matches. A nil *Events drops everything.
+type Events struct {
+	mu          sync.RWMutex
+	subscribers []func(*domain.GameResult)
+}
+
+func NewEvents() *Events {
+	return &Events{}
+}
+
+// Subscribe calls fn with every result recorded from now on.
+func (e *Events) Subscribe(fn func(*domain.GameResult)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subscribers = append(e.subscribers, fn)
+}
+
+// Publish hands results, already stored, to each subscriber in turn. It
+// runs in the caller's goroutine, so subscribers should be quick and
+// handle their own errors.
+func (e *Events) Publish(results ...*domain.GameResult) {
+	if e == nil {
+		return
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, fn := range e.subscribers {
+		for _, result := range results {
+			fn(result)
+		}
+	}
+}
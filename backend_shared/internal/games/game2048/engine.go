@@ -0,0 +1,214 @@
+package game2048
+
+import (
+	"fmt"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+const Size = 4
+
+type Direction string
+
+const (
+	Up    Direction = "up"
+	Down  Direction = "down"
+	Left  Direction = "left"
+	Right Direction = "right"
+)
+
+func ParseDirection(s string) (Direction, error) {
+	switch d := Direction(s); d {
+	case Up, Down, Left, Right:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown direction %q", s)
+}
+
+// Board is indexed [row][col]; 0 means an empty cell.
+type Board [Size][Size]int
+
+// Game is a deterministic 2048 game. Tile spawns come from a Mulberry32
+// stream seeded by the server, so replaying the same moves from the same
+// seed always yields the same board and score.
+//
+// Spawn rule (must match the client): collect the empty cells in row-major
+// order, pick one with Intn(len(empty)), then draw Float64() and place a 2
+// if it is < 0.9, otherwise a 4.
+type Game struct {
+	Board Board `json:"board"`
+	Score int   `json:"score"`
+	Moves int   `json:"moves"`
+
+	rand *rng.Mulberry32
+}
+
+// NewGame starts a game from seed with the two opening tiles placed.
+func NewGame(seed uint32) *Game {
+	g := &Game{rand: rng.New(seed)}
+	g.spawn()
+	g.spawn()
+	return g
+}
+
+// Move slides the board in the given direction. It returns false, and leaves
+// the game untouched, if the move would not change the board.
+func (g *Game) Move(d Direction) bool {
+	next := g.Board
+	gained := 0
+
+	for i := 0; i < Size; i++ {
+		var line [Size]int
+		for j := 0; j < Size; j++ {
+			r, c := cellFor(d, i, j)
+			line[j] = next[r][c]
+		}
+		merged, points := slide(line)
+		gained += points
+		for j := 0; j < Size; j++ {
+			r, c := cellFor(d, i, j)
+			next[r][c] = merged[j]
+		}
+	}
+
+	if next == g.Board {
+		return false
+	}
+
+	g.Board = next
+	g.Score += gained
+	g.Moves++
+	g.spawn()
+	return true
+}
+
+// CanMove reports whether any direction would change the board.
+func (g *Game) CanMove() bool {
+	for r := 0; r < Size; r++ {
+		for c := 0; c < Size; c++ {
+			v := g.Board[r][c]
+			if v == 0 {
+				return true
+			}
+			if c+1 < Size && g.Board[r][c+1] == v {
+				return true
+			}
+			if r+1 < Size && g.Board[r+1][c] == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaxTile returns the highest tile on the board.
+func (g *Game) MaxTile() int {
+	max := 0
+	for r := 0; r < Size; r++ {
+		for c := 0; c < Size; c++ {
+			if g.Board[r][c] > max {
+				max = g.Board[r][c]
+			}
+		}
+	}
+	return max
+}
+
+func (g *Game) spawn() {
+	var empty [][2]int
+	for r := 0; r < Size; r++ {
+		for c := 0; c < Size; c++ {
+			if g.Board[r][c] == 0 {
+				empty = append(empty, [2]int{r, c})
+			}
+		}
+	}
+	if len(empty) == 0 {
+		return
+	}
+	cell := empty[g.rand.Intn(len(empty))]
+	value := 2
+	if g.rand.Float64() >= 0.9 {
+		value = 4
+	}
+	g.Board[cell[0]][cell[1]] = value
+}
+
+// cellFor maps the j-th cell of line i (j = 0 being the edge tiles slide
+// towards) onto board coordinates for direction d.
+func cellFor(d Direction, i, j int) (int, int) {
+	switch d {
+	case Up:
+		return j, i
+	case Down:
+		return Size - 1 - j, i
+	case Left:
+		return i, j
+	default: // Right
+		return i, Size - 1 - j
+	}
+}
+
+// slide compacts a line towards index 0, merging equal neighbours once.
+func slide(line [Size]int) ([Size]int, int) {
+	var out [Size]int
+	points := 0
+	n := 0
+	canMerge := false
+	for _, v := range line {
+		if v == 0 {
+			continue
+		}
+		if canMerge && out[n-1] == v {
+			out[n-1] *= 2
+			points += out[n-1]
+			canMerge = false
+			continue
+		}
+		out[n] = v
+		n++
+		canMerge = true
+	}
+	return out, points
+}
+
+// Replay plays moves from a fresh game seeded with seed. Every move must
+// change the board; the first one that doesn't is reported as an error.
+func Replay(seed uint32, moves []Direction) (*Game, error) {
+	g := NewGame(seed)
+	for i, m := range moves {
+		if !g.Move(m) {
+			return nil, fmt.Errorf("move %d (%s) does not change the board", i+1, m)
+		}
+	}
+	return g, nil
+}
+
+// EncodeMoves stores a move list compactly as one letter per move (U/D/L/R).
+func EncodeMoves(moves []Direction) string {
+	b := make([]byte, len(moves))
+	for i, m := range moves {
+		b[i] = byte(m[0] - 'a' + 'A')
+	}
+	return string(b)
+}
+
+// DecodeMoves is the inverse of EncodeMoves.
+func DecodeMoves(s string) ([]Direction, error) {
+	moves := make([]Direction, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case 'U':
+			moves[i] = Up
+		case 'D':
+			moves[i] = Down
+		case 'L':
+			moves[i] = Left
+		case 'R':
+			moves[i] = Right
+		default:
+			return nil, fmt.Errorf("invalid encoded move %q at %d", s[i], i)
+		}
+	}
+	return moves, nil
+}
@@ -0,0 +1,207 @@
+package game2048
+
+import (
+	"testing"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+func TestSlide(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   [Size]int
+		want   [Size]int
+		points int
+	}{
+		{"empty", [Size]int{}, [Size]int{}, 0},
+		{"compacts", [Size]int{0, 2, 0, 4}, [Size]int{2, 4, 0, 0}, 0},
+		{"merges a pair", [Size]int{2, 2, 0, 0}, [Size]int{4, 0, 0, 0}, 4},
+		{"merges across gaps", [Size]int{2, 0, 0, 2}, [Size]int{4, 0, 0, 0}, 4},
+		{"merges two pairs", [Size]int{2, 2, 2, 2}, [Size]int{4, 4, 0, 0}, 8},
+		{"merged tile doesn't merge again", [Size]int{2, 2, 4, 0}, [Size]int{4, 4, 0, 0}, 4},
+		{"leading tiles merge first", [Size]int{4, 4, 4, 0}, [Size]int{8, 4, 0, 0}, 8},
+		{"no pairs", [Size]int{2, 4, 8, 16}, [Size]int{2, 4, 8, 16}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, points := slide(tt.line)
+			if got != tt.want || points != tt.points {
+				t.Errorf("slide(%v) = %v, %d; want %v, %d", tt.line, got, points, tt.want, tt.points)
+			}
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	start := Board{
+		{2, 2, 0, 4},
+		{0, 0, 0, 0},
+		{4, 0, 4, 8},
+		{0, 0, 0, 8},
+	}
+	tests := []struct {
+		dir   Direction
+		want  Board
+		score int
+	}{
+		{Left, Board{{4, 4, 0, 0}, {0, 0, 0, 0}, {8, 8, 0, 0}, {8, 0, 0, 0}}, 12},
+		{Right, Board{{0, 0, 4, 4}, {0, 0, 0, 0}, {0, 0, 8, 8}, {0, 0, 0, 8}}, 12},
+		{Up, Board{{2, 2, 4, 4}, {4, 0, 0, 16}, {0, 0, 0, 0}, {0, 0, 0, 0}}, 16},
+		{Down, Board{{0, 0, 0, 0}, {0, 0, 0, 0}, {2, 0, 0, 4}, {4, 2, 4, 16}}, 16},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dir), func(t *testing.T) {
+			g := &Game{Board: start, rand: rng.New(1)}
+			if !g.Move(tt.dir) {
+				t.Fatal("move was refused")
+			}
+			if g.Score != tt.score || g.Moves != 1 {
+				t.Errorf("score %d after %d moves, want %d after 1", g.Score, g.Moves, tt.score)
+			}
+			// Apart from the tile spawned afterwards, the board must be
+			// exactly the slid one.
+			spawned := 0
+			for r := 0; r < Size; r++ {
+				for c := 0; c < Size; c++ {
+					got, want := g.Board[r][c], tt.want[r][c]
+					if got == want {
+						continue
+					}
+					if want != 0 || (got != 2 && got != 4) {
+						t.Fatalf("board %v, want %v plus one new tile", g.Board, tt.want)
+					}
+					spawned++
+				}
+			}
+			if spawned != 1 {
+				t.Errorf("%d tiles spawned, want 1", spawned)
+			}
+		})
+	}
+}
+
+func TestMoveWithoutChange(t *testing.T) {
+	board := Board{
+		{2, 4, 0, 0},
+		{8, 0, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+	g := &Game{Board: board, rand: rng.New(1)}
+	if g.Move(Left) || g.Move(Up) {
+		t.Fatal("a move that changes nothing was accepted")
+	}
+	if g.Board != board || g.Moves != 0 || g.Score != 0 {
+		t.Errorf("refused move changed the game: %+v", g)
+	}
+}
+
+func TestCanMove(t *testing.T) {
+	full := Board{
+		{2, 4, 2, 4},
+		{4, 2, 4, 2},
+		{2, 4, 2, 4},
+		{4, 2, 4, 2},
+	}
+	if (&Game{Board: full}).CanMove() {
+		t.Error("CanMove on a stuck board")
+	}
+	full[3][3] = 4
+	if !(&Game{Board: full}).CanMove() {
+		t.Error("CanMove false with a vertical pair left")
+	}
+}
+
+// TestSpawnRule pins the spawn rule the client reproduces: pick among the
+// empty cells in row-major order, then a 2 below 0.9, otherwise a 4.
+func TestSpawnRule(t *testing.T) {
+	for seed := uint32(0); seed < 50; seed++ {
+		r := rng.New(seed)
+		var want Board
+		for n := 0; n < 2; n++ {
+			var empty [][2]int
+			for row := 0; row < Size; row++ {
+				for col := 0; col < Size; col++ {
+					if want[row][col] == 0 {
+						empty = append(empty, [2]int{row, col})
+					}
+				}
+			}
+			cell := empty[int(r.Float64()*float64(len(empty)))]
+			want[cell[0]][cell[1]] = 2
+			if r.Float64() >= 0.9 {
+				want[cell[0]][cell[1]] = 4
+			}
+		}
+		if got := NewGame(seed).Board; got != want {
+			t.Fatalf("seed %d: opening board %v, want %v", seed, got, want)
+		}
+	}
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	const seed = 12345
+	live := NewGame(seed)
+	var moves []Direction
+	dirs := []Direction{Left, Down, Right, Up}
+	for i := 0; live.CanMove() && len(moves) < 500; i++ {
+		if d := dirs[i%len(dirs)]; live.Move(d) {
+			moves = append(moves, d)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		g, err := Replay(seed, moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Board != live.Board || g.Score != live.Score || g.Moves != len(moves) {
+			t.Fatalf("replay %d ended at %v (score %d), live game at %v (score %d)", i, g.Board, g.Score, live.Board, live.Score)
+		}
+	}
+
+	other, err := Replay(seed+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Board == NewGame(seed).Board {
+		t.Error("different seeds opened with the same board")
+	}
+}
+
+func TestReplayRejectsMoveWithoutChange(t *testing.T) {
+	for seed := uint32(0); seed < 1000; seed++ {
+		for _, d := range []Direction{Up, Down, Left, Right} {
+			if g := NewGame(seed); !g.Move(d) {
+				if _, err := Replay(seed, []Direction{d}); err == nil {
+					t.Fatalf("seed %d: replay accepted %s, which changes nothing", seed, d)
+				}
+				return
+			}
+		}
+	}
+	t.Fatal("no opening board with a move that changes nothing")
+}
+
+func TestMoveEncoding(t *testing.T) {
+	moves := []Direction{Up, Down, Left, Right, Left}
+	encoded := EncodeMoves(moves)
+	if encoded != "UDLRL" {
+		t.Fatalf("EncodeMoves = %q, want UDLRL", encoded)
+	}
+	decoded, err := DecodeMoves(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range moves {
+		if decoded[i] != moves[i] {
+			t.Fatalf("DecodeMoves(%q) = %v, want %v", encoded, decoded, moves)
+		}
+	}
+	if _, err := DecodeMoves("UXD"); err == nil {
+		t.Error("DecodeMoves accepted an unknown letter")
+	}
+	if _, err := ParseDirection("north"); err == nil {
+		t.Error("ParseDirection accepted an unknown direction")
+	}
+}
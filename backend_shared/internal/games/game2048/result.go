@@ -0,0 +1,64 @@
+package game2048
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+)
+
+// ResultDetails are the fields of a 2048 result.
+type ResultDetails struct {
+	MaxTile int `json:"max_tile"`
+}
+
+func (s *Service) ID() string   { return "2048" }
+func (s *Service) Name() string { return "2048" }
+
+func (s *Service) Schema() []games.Field {
+	return []games.Field{
+		{Name: "max_tile", Description: "Largest tile on the final board"},
+	}
+}
+
+func (s *Service) Ranking() domain.Ranking {
+	return domain.Ranking{Order: domain.HigherFirst, Aggregate: domain.AggregateBest}
+}
+
+func (s *Service) Variant(raw string) (string, error) {
+	return games.DailyVariants(raw)
+}
+
+func (s *Service) Validate(result *domain.GameResult) error {
+	var d ResultDetails
+	if err := json.Unmarshal(result.Details, &d); err != nil {
+		return err
+	}
+	if d.MaxTile < 2 || d.MaxTile&(d.MaxTile-1) != 0 {
+		return fmt.Errorf("max tile %d is not a power of two", d.MaxTile)
+	}
+	if result.Score < 0 {
+		return fmt.Errorf("negative score %d", result.Score)
+	}
+	return nil
+}
+
+// newResult describes a verified session as a result.
+func (s *Service) newResult(session *domain.Game2048Session) (*domain.GameResult, error) {
+	details, err := json.Marshal(ResultDetails{MaxTile: session.MaxTile})
+	if err != nil {
+		return nil, err
+	}
+	result := &domain.GameResult{
+		Game:      s.ID(),
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Variant:   daily.Variant(session.DailyDate),
+		Score:     session.Score,
+		Ranked:    true,
+		Details:   details,
+	}
+	return result, games.Check(s, result)
+}
@@ -1,35 +1,130 @@
 package game2048
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+	"github.com/ramanasai/local-game-play/internal/games/rng"
 	"github.com/ramanasai/local-game-play/internal/repos"
 	"github.com/rs/zerolog/log"
 )
 
+// MaxMoves bounds how long a submitted move log may be. A 2048 game ends well
+// before this, so anything longer is not a real game.
+const MaxMoves = 100000
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionClosed   = errors.New("session already finished")
+	ErrInvalidMoves    = errors.New("invalid move log")
+)
+
 type Service struct {
-	repo *repos.Game2048Repo
+	repo   *repos.Game2048Repo
+	seeder *daily.Seeder
+	events *games.Events
+}
+
+func NewService(repo *repos.Game2048Repo, seeder *daily.Seeder, events *games.Events) *Service {
+	return &Service{repo: repo, seeder: seeder, events: events}
 }
 
-func NewService(repo *repos.Game2048Repo) *Service {
-	return &Service{repo: repo}
+// StartSession creates a session with a server-chosen seed and returns it
+// together with the opening board the client should display.
+func (s *Service) StartSession(userID string) (*domain.Game2048Session, *Game, error) {
+	seed, err := rng.NewSeed()
+	if err != nil {
+		log.Error().Err(err).Msg("2048 Service: Failed to generate seed")
+		return nil, nil, err
+	}
+
+	session, err := s.repo.CreateSession(userID, seed, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Msg("2048 Service: Started session")
+	return session, NewGame(seed), nil
 }
 
-func (s *Service) SubmitScore(userID string, score int) error {
-	// Simple validation
-	if score < 0 {
-		log.Warn().Int("score", score).Msg("2048 Service: Ignoring negative score")
-		return nil // Just ignore negative scores
+// StartDaily starts the user's one attempt at today's challenge: the same
+// tile spawns for everyone, played and scored like any other session.
+func (s *Service) StartDaily(userID string) (*domain.Game2048Session, *Game, error) {
+	date := daily.Date(time.Now())
+	seed := s.seeder.Seed(s.ID(), date)
+
+	session, err := s.repo.CreateSession(userID, seed, date)
+	if err != nil {
+		if errors.Is(err, repos.ErrDailyPlayed) {
+			return nil, nil, daily.ErrPlayed
+		}
+		return nil, nil, err
 	}
-	log.Debug().Str("user_id", userID).Int("score", score).Msg("2048 Service: Submitting score")
-	return s.repo.SaveScore(userID, score)
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Str("date", date).Msg("2048 Service: Started daily challenge")
+	return session, NewGame(seed), nil
 }
 
-func (s *Service) GetLeaderboard(limit int) ([]domain.Score2048, error) {
-	if limit <= 0 {
-		limit = 10
+func (s *Service) HasPlayedDaily(userID, date string) (bool, error) {
+	return s.repo.HasDailySession(userID, date)
+}
+
+// SubmitScore replays the move log against the session seed and records the
+// score the replay produces. The client never reports a score itself.
+func (s *Service) SubmitScore(userID, sessionID string, moves []string) (*domain.Game2048Session, error) {
+	session, err := s.repo.GetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	if session.Status != "active" {
+		return nil, ErrSessionClosed
+	}
+
+	if len(moves) > MaxMoves {
+		return nil, fmt.Errorf("%w: more than %d moves", ErrInvalidMoves, MaxMoves)
+	}
+	dirs := make([]Direction, len(moves))
+	for i, m := range moves {
+		d, err := ParseDirection(m)
+		if err != nil {
+			return nil, fmt.Errorf("%w: move %d: %v", ErrInvalidMoves, i+1, err)
+		}
+		dirs[i] = d
+	}
+
+	game, err := Replay(session.Seed, dirs)
+	if err != nil {
+		log.Warn().Err(err).Str("session_id", sessionID).Msg("2048 Service: Replay rejected")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMoves, err)
+	}
+
+	session.Moves = EncodeMoves(dirs)
+	session.Score = game.Score
+	session.MaxTile = game.MaxTile()
+
+	log.Debug().Str("user_id", userID).Int("score", session.Score).Msg("2048 Service: Submitting verified score")
+	result, err := s.newResult(session)
+	if err != nil {
+		return nil, err
 	}
-	if limit > 50 {
-		limit = 50
+	if err := s.repo.FinishSession(session, result); err != nil {
+		if errors.Is(err, repos.ErrSessionNotActive) {
+			return nil, ErrSessionClosed
+		}
+		return nil, err
 	}
-	return s.repo.GetLeaderboard(limit)
+	session.Status = "finished"
+	s.events.Publish(result)
+	return session, nil
 }
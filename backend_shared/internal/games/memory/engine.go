@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+// SuspiciousLuckBits is the threshold above which a finished run is flagged.
+// Every match whose second card had never been seen is a guess with odds of
+// 1 in (unseen cards left); the run's luck is the sum of -log2 of those odds.
+// 20 bits is roughly a one-in-a-million run, e.g. a perfect 16-card game.
+const SuspiciousLuckBits = 20.0
+
+var ErrIllegalFlip = errors.New("illegal flip")
+
+// AllowedPairs are the board sizes the client offers (4x3 up to 8x5).
+var AllowedPairs = map[int]bool{6: true, 8: true, 12: true, 18: true, 20: true}
+
+// DefaultPairs is the board the client starts on (4x4).
+const DefaultPairs = 8
+
+// NewDeck lays out pairs*2 cards from seed. Faces are pair IDs 0..pairs-1;
+// the layout is a Fisher-Yates shuffle of [0,0,1,1,...] drawing j with
+// Intn(i+1) for i from the end, so the client could reproduce it.
+func NewDeck(seed uint32, pairs int) []int {
+	deck := make([]int, pairs*2)
+	for i := range deck {
+		deck[i] = i / 2
+	}
+	r := rng.New(seed)
+	for i := len(deck) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		deck[i], deck[j] = deck[j], deck[i]
+	}
+	return deck
+}
+
+// Flip is a single card turn as recorded by the server.
+type Flip struct {
+	Index int       `json:"i"`
+	At    time.Time `json:"t"`
+}
+
+// FlipResult tells the client what a flip revealed.
+type FlipResult struct {
+	Index     int  `json:"index"`
+	Value     int  `json:"value"`
+	Matched   bool `json:"matched"`
+	Moves     int  `json:"moves"`
+	Matches   int  `json:"matches"`
+	Completed bool `json:"completed"`
+}
+
+// Game replays a deck and its flips, enforcing the rules: a flip must hit a
+// face-down, unmatched card. A mismatched pair stays face up until the next
+// flip turns it back down.
+type Game struct {
+	Deck    []int
+	Flips   []Flip
+	Matched []bool
+	Matches int
+	Luck    float64
+
+	open []int
+	seen []bool
+}
+
+func NewGame(deck []int) *Game {
+	return &Game{
+		Deck:    deck,
+		Matched: make([]bool, len(deck)),
+		seen:    make([]bool, len(deck)),
+	}
+}
+
+func (g *Game) Completed() bool {
+	return g.Matches*2 == len(g.Deck)
+}
+
+func (g *Game) Flip(f Flip) (FlipResult, error) {
+	if g.Completed() {
+		return FlipResult{}, fmt.Errorf("%w: game already completed", ErrIllegalFlip)
+	}
+	if f.Index < 0 || f.Index >= len(g.Deck) {
+		return FlipResult{}, fmt.Errorf("%w: card %d does not exist", ErrIllegalFlip, f.Index)
+	}
+	if g.Matched[f.Index] {
+		return FlipResult{}, fmt.Errorf("%w: card %d is already matched", ErrIllegalFlip, f.Index)
+	}
+	if len(g.open) == 2 {
+		g.open = g.open[:0]
+	}
+	if len(g.open) == 1 && g.open[0] == f.Index {
+		return FlipResult{}, fmt.Errorf("%w: card %d is already face up", ErrIllegalFlip, f.Index)
+	}
+
+	res := FlipResult{Index: f.Index, Value: g.Deck[f.Index]}
+	if len(g.open) == 1 {
+		first := g.open[0]
+		if g.Deck[first] == g.Deck[f.Index] {
+			if !g.seen[f.Index] {
+				g.Luck += math.Log2(float64(g.unseenExcept(first)))
+			}
+			g.Matched[first] = true
+			g.Matched[f.Index] = true
+			g.Matches++
+			res.Matched = true
+			g.open = g.open[:0]
+		} else {
+			g.open = append(g.open, f.Index)
+		}
+	} else {
+		g.open = append(g.open, f.Index)
+	}
+
+	g.seen[f.Index] = true
+	g.Flips = append(g.Flips, f)
+	res.Moves = len(g.Flips)
+	res.Matches = g.Matches
+	res.Completed = g.Completed()
+	return res, nil
+}
+
+// unseenExcept counts unmatched cards never turned over, other than skip.
+func (g *Game) unseenExcept(skip int) int {
+	n := 0
+	for i := range g.Deck {
+		if i != skip && !g.Matched[i] && !g.seen[i] {
+			n++
+		}
+	}
+	return n
+}
+
+// Suspicious reports whether the run was too lucky to be plausible.
+func (g *Game) Suspicious() bool {
+	return g.Luck > SuspiciousLuckBits
+}
+
+// Elapsed is the time from start until the last recorded flip.
+func (g *Game) Elapsed(start time.Time) time.Duration {
+	if len(g.Flips) == 0 {
+		return 0
+	}
+	return g.Flips[len(g.Flips)-1].At.Sub(start)
+}
+
+// Replay rebuilds a game from its deck and stored flips.
+func Replay(deck []int, flips []Flip) (*Game, error) {
+	g := NewGame(deck)
+	for i, f := range flips {
+		if _, err := g.Flip(f); err != nil {
+			return nil, fmt.Errorf("flip %d: %w", i+1, err)
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/games/rng"
+)
+
+func TestNewDeck(t *testing.T) {
+	for pairs := range AllowedPairs {
+		deck := NewDeck(7, pairs)
+		if len(deck) != pairs*2 {
+			t.Fatalf("%d pairs: %d cards", pairs, len(deck))
+		}
+		counts := make([]int, pairs)
+		for _, v := range deck {
+			counts[v]++
+		}
+		for v, n := range counts {
+			if n != 2 {
+				t.Fatalf("%d pairs: face %d appears %d times", pairs, v, n)
+			}
+		}
+		if !slices.Equal(deck, NewDeck(7, pairs)) {
+			t.Fatalf("%d pairs: same seed dealt different decks", pairs)
+		}
+	}
+	if slices.Equal(NewDeck(1, 8), NewDeck(2, 8)) {
+		t.Error("different seeds dealt the same deck")
+	}
+}
+
+// TestNewDeckShuffle pins the shuffle the client could reproduce.
+func TestNewDeckShuffle(t *testing.T) {
+	want := []int{0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
+	r := rng.New(99)
+	for i := len(want) - 1; i > 0; i-- {
+		j := int(r.Float64() * float64(i+1))
+		want[i], want[j] = want[j], want[i]
+	}
+	if got := NewDeck(99, 6); !slices.Equal(got, want) {
+		t.Errorf("NewDeck(99, 6) = %v, want %v", got, want)
+	}
+}
+
+// pairsOf returns the positions of each face in deck.
+func pairsOf(deck []int) [][2]int {
+	pos := make([][2]int, len(deck)/2)
+	seen := make([]bool, len(deck)/2)
+	for i, v := range deck {
+		if seen[v] {
+			pos[v][1] = i
+		} else {
+			pos[v][0] = i
+			seen[v] = true
+		}
+	}
+	return pos
+}
+
+func flipAll(t *testing.T, g *Game, indexes ...int) []FlipResult {
+	t.Helper()
+	var results []FlipResult
+	for _, i := range indexes {
+		res, err := g.Flip(Flip{Index: i})
+		if err != nil {
+			t.Fatalf("flip %d: %v", i, err)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func TestFlipRules(t *testing.T) {
+	deck := []int{0, 1, 0, 1}
+
+	g := NewGame(deck)
+	res := flipAll(t, g, 0, 1)
+	if res[1].Matched || g.Matches != 0 {
+		t.Fatal("different faces matched")
+	}
+	// The mismatched pair is turned back down by the next flip, so 0 can
+	// be flipped again.
+	res = flipAll(t, g, 0, 2)
+	if !res[1].Matched || res[1].Matches != 1 || res[1].Moves != 4 {
+		t.Fatalf("matching flip gave %+v", res[1])
+	}
+	res = flipAll(t, g, 3, 1)
+	if !res[1].Completed {
+		t.Fatal("game not completed with every pair matched")
+	}
+
+	tests := []struct {
+		name  string
+		setup []int
+		flip  int
+	}{
+		{"card out of range", nil, 4},
+		{"negative card", nil, -1},
+		{"same card twice", []int{1}, 1},
+		{"matched card", []int{0, 2}, 0},
+		{"matched card as second flip", []int{0, 2, 1}, 2},
+		{"completed game", []int{0, 2, 1, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame(deck)
+			flipAll(t, g, tt.setup...)
+			if _, err := g.Flip(Flip{Index: tt.flip}); !errors.Is(err, ErrIllegalFlip) {
+				t.Fatalf("got %v, want ErrIllegalFlip", err)
+			}
+			if len(g.Flips) != len(tt.setup) {
+				t.Error("illegal flip was recorded")
+			}
+		})
+	}
+}
+
+func TestSuspicious(t *testing.T) {
+	tests := []struct {
+		name       string
+		pairs      int
+		perfect    bool
+		suspicious bool
+	}{
+		// Matching every pair without seeing it first: 15*13*...*1 to 1
+		// on 16 cards, over the threshold.
+		{"perfect 16-card run", 8, true, true},
+		{"perfect 40-card run", 20, true, true},
+		// 11*9*...*1 to 1 is lucky but happens.
+		{"perfect 12-card run", 6, true, false},
+		{"looks at every card first", 20, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deck := NewDeck(5, tt.pairs)
+			g := NewGame(deck)
+			if !tt.perfect {
+				// Turn over two cards at a time until every card has been
+				// seen, matching nothing on purpose where possible.
+				for i := 0; i+1 < len(deck); i += 2 {
+					if deck[i] != deck[i+1] {
+						flipAll(t, g, i, i+1)
+					}
+				}
+			}
+			for _, p := range pairsOf(deck) {
+				if !g.Matched[p[0]] {
+					flipAll(t, g, p[0], p[1])
+				}
+			}
+			if !g.Completed() {
+				t.Fatal("game not completed")
+			}
+			if g.Suspicious() != tt.suspicious {
+				t.Errorf("Suspicious() = %v with %.1f bits of luck, want %v", g.Suspicious(), g.Luck, tt.suspicious)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	deck := NewDeck(11, 6)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var flips []Flip
+	for n, p := range pairsOf(deck) {
+		flips = append(flips,
+			Flip{Index: p[0], At: start.Add(time.Duration(2*n+1) * time.Second)},
+			Flip{Index: p[1], At: start.Add(time.Duration(2*n+2) * time.Second)})
+	}
+
+	for i := 0; i < 2; i++ {
+		g, err := Replay(deck, flips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !g.Completed() || g.Matches != 6 || len(g.Flips) != 12 {
+			t.Fatalf("replay %d: %d matches in %d flips", i, g.Matches, len(g.Flips))
+		}
+		if got := g.Elapsed(start); got != 12*time.Second {
+			t.Errorf("Elapsed = %v, want 12s", got)
+		}
+	}
+
+	if _, err := Replay(deck, append(flips[:2:2], flips[0])); !errors.Is(err, ErrIllegalFlip) {
+		t.Errorf("replaying a matched card: got %v, want ErrIllegalFlip", err)
+	}
+}
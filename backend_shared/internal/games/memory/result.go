@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+)
+
+// ResultDetails are the fields of a Memory result.
+type ResultDetails struct {
+	Moves       int `json:"moves"`
+	TimeSeconds int `json:"time_seconds"`
+	Pairs       int `json:"pairs"`
+}
+
+func (s *Service) ID() string   { return "memory" }
+func (s *Service) Name() string { return "Memory" }
+
+func (s *Service) Schema() []games.Field {
+	return []games.Field{
+		{Name: "moves", Description: "Cards flipped"},
+		{Name: "time_seconds", Description: "Seconds from start to the last match"},
+		{Name: "pairs", Description: "Pairs on the board"},
+	}
+}
+
+// Ranking puts the fewest moves first, then the fastest.
+func (s *Service) Ranking() domain.Ranking {
+	return domain.Ranking{Order: domain.LowerFirst, TieBreak: domain.LowerFirst, Aggregate: domain.AggregateBest}
+}
+
+// Variant is the number of pairs, as moves and times only compare on the
+// same board; the empty variant is the 8-pair board. Daily challenges have
+// a board of their own.
+func (s *Service) Variant(raw string) (string, error) {
+	if raw == "" {
+		return boardVariant(DefaultPairs), nil
+	}
+	if _, ok := daily.ParseVariant(raw); ok {
+		return raw, nil
+	}
+	pairs, err := strconv.Atoi(raw)
+	if err != nil || !AllowedPairs[pairs] {
+		return "", fmt.Errorf("%w: %q is not a board size", games.ErrInvalidVariant, raw)
+	}
+	return boardVariant(pairs), nil
+}
+
+func boardVariant(pairs int) string {
+	return strconv.Itoa(pairs)
+}
+
+func (s *Service) Validate(result *domain.GameResult) error {
+	var d ResultDetails
+	if err := json.Unmarshal(result.Details, &d); err != nil {
+		return err
+	}
+	if !AllowedPairs[d.Pairs] {
+		return fmt.Errorf("unsupported board of %d pairs", d.Pairs)
+	}
+	if d.Moves < 2*d.Pairs || d.Moves != result.Score {
+		return fmt.Errorf("%d moves cannot clear %d pairs", d.Moves, d.Pairs)
+	}
+	if d.TimeSeconds < 0 || d.TimeSeconds != result.TieBreak {
+		return fmt.Errorf("invalid time %d", d.TimeSeconds)
+	}
+	if _, ok := daily.ParseVariant(result.Variant); ok {
+		if d.Pairs != DailyPairs {
+			return fmt.Errorf("daily challenge of %d pairs", d.Pairs)
+		}
+	} else if result.Variant != boardVariant(d.Pairs) {
+		return fmt.Errorf("%d pairs on the %q board", d.Pairs, result.Variant)
+	}
+	return nil
+}
+
+// newResult describes a completed session as a result. Flagged runs are
+// kept but not ranked.
+func (s *Service) newResult(session *domain.MemorySession, run *RunResult) (*domain.GameResult, error) {
+	details, err := json.Marshal(ResultDetails{Moves: run.Moves, TimeSeconds: run.TimeSeconds, Pairs: session.Pairs})
+	if err != nil {
+		return nil, err
+	}
+	variant := boardVariant(session.Pairs)
+	if session.DailyDate != "" {
+		variant = daily.Variant(session.DailyDate)
+	}
+	result := &domain.GameResult{
+		Game:      s.ID(),
+		UserID:    session.UserID,
+		SessionID: session.ID,
+		Variant:   variant,
+		Score:     run.Moves,
+		TieBreak:  run.TimeSeconds,
+		Ranked:    !run.Suspicious,
+		Details:   details,
+	}
+	return result, games.Check(s, result)
+}
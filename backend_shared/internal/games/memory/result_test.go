@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+)
+
+func TestVariant(t *testing.T) {
+	tests := []struct {
+		raw, want string
+		ok        bool
+	}{
+		{"", "8", true},
+		{"8", "8", true},
+		{"6", "6", true},
+		{"20", "20", true},
+		{"08", "8", true},
+		{"daily:2026-03-10", "daily:2026-03-10", true},
+		{"7", "", false},
+		{"0", "", false},
+		{"-8", "", false},
+		{"eight", "", false},
+		{"daily:someday", "", false},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		got, err := s.Variant(tt.raw)
+		if tt.ok != (err == nil) || got != tt.want {
+			t.Errorf("Variant(%q) = %q, %v; want %q, ok %v", tt.raw, got, err, tt.want, tt.ok)
+		}
+		if err != nil && !errors.Is(err, games.ErrInvalidVariant) {
+			t.Errorf("Variant(%q) error %v doesn't wrap ErrInvalidVariant", tt.raw, err)
+		}
+	}
+}
+
+func TestValidateBoardSize(t *testing.T) {
+	tests := []struct {
+		variant string
+		pairs   int
+		ok      bool
+	}{
+		{"8", 8, true},
+		{"12", 12, true},
+		{"8", 12, false},
+		{"", 8, false},
+		{"daily:2026-03-10", DailyPairs, true},
+		{"daily:2026-03-10", 8, false},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		details, _ := json.Marshal(ResultDetails{Moves: 2 * tt.pairs, TimeSeconds: 30, Pairs: tt.pairs})
+		result := &domain.GameResult{Game: "memory", Variant: tt.variant, Score: 2 * tt.pairs, TieBreak: 30, Details: details}
+		if err := s.Validate(result); tt.ok != (err == nil) {
+			t.Errorf("%d pairs on %q: Validate = %v, want ok %v", tt.pairs, tt.variant, err, tt.ok)
+		}
+	}
+}
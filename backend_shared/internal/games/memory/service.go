@@ -1,25 +1,225 @@
 package memory
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+	"github.com/ramanasai/local-game-play/internal/games/rng"
 	"github.com/ramanasai/local-game-play/internal/repos"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	ErrInvalidPairs    = errors.New("unsupported board size")
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionClosed   = errors.New("session already finished")
+	ErrSessionBusy     = errors.New("session was updated by another request")
+)
+
+// DailyPairs is the board size of the daily challenge.
+const DailyPairs = 12
+
 type Service struct {
 	scoreRepo *repos.ScoreRepo
+	seeder    *daily.Seeder
+	events    *games.Events
+}
+
+func NewService(scoreRepo *repos.ScoreRepo, seeder *daily.Seeder, events *games.Events) *Service {
+	return &Service{scoreRepo: scoreRepo, seeder: seeder, events: events}
+}
+
+// SessionState is the client's view of a session: only cards that are
+// matched or currently face up have their value revealed.
+type SessionState struct {
+	SessionID string      `json:"session_id"`
+	Status    string      `json:"status"`
+	DailyDate string      `json:"daily_date,omitempty"`
+	Cards     int         `json:"cards"`
+	Revealed  map[int]int `json:"revealed"`
+	Moves     int         `json:"moves"`
+	Matches   int         `json:"matches"`
+}
+
+// RunResult is the score the server derived for a completed board.
+type RunResult struct {
+	Moves       int  `json:"moves"`
+	TimeSeconds int  `json:"time_seconds"`
+	Suspicious  bool `json:"suspicious"`
+}
+
+// FlipResponse is returned for every flip; Score is set once the last pair
+// has been matched and the result recorded.
+type FlipResponse struct {
+	FlipResult
+	Score *RunResult `json:"score,omitempty"`
+}
+
+func (s *Service) StartSession(userID string, pairs int) (*SessionState, error) {
+	if !AllowedPairs[pairs] {
+		return nil, fmt.Errorf("%w: %d pairs", ErrInvalidPairs, pairs)
+	}
+
+	seed, err := rng.NewSeed()
+	if err != nil {
+		log.Error().Err(err).Msg("Memory Service: Failed to generate seed")
+		return nil, err
+	}
+
+	session, err := s.scoreRepo.CreateSession(userID, seed, pairs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Int("pairs", pairs).Msg("Memory Service: Started session")
+	return &SessionState{
+		SessionID: session.ID,
+		Status:    session.Status,
+		Cards:     pairs * 2,
+		Revealed:  map[int]int{},
+	}, nil
+}
+
+// StartDaily starts the user's one attempt at today's challenge: a board of
+// DailyPairs laid out the same for everyone.
+func (s *Service) StartDaily(userID string) (*SessionState, error) {
+	date := daily.Date(time.Now())
+	seed := s.seeder.Seed(s.ID(), date)
+
+	session, err := s.scoreRepo.CreateSession(userID, seed, DailyPairs, date)
+	if err != nil {
+		if errors.Is(err, repos.ErrDailyPlayed) {
+			return nil, daily.ErrPlayed
+		}
+		return nil, err
+	}
+
+	log.Debug().Str("user_id", userID).Str("session_id", session.ID).Str("date", date).Msg("Memory Service: Started daily challenge")
+	return &SessionState{
+		SessionID: session.ID,
+		Status:    session.Status,
+		DailyDate: session.DailyDate,
+		Cards:     DailyPairs * 2,
+		Revealed:  map[int]int{},
+	}, nil
 }
 
-func NewService(scoreRepo *repos.ScoreRepo) *Service {
-	return &Service{scoreRepo: scoreRepo}
+func (s *Service) HasPlayedDaily(userID, date string) (bool, error) {
+	return s.scoreRepo.HasDailySession(userID, date)
 }
 
-func (s *Service) SubmitScore(userID string, moves, timeSeconds int) error {
-	log.Debug().Str("user_id", userID).Int("moves", moves).Int("time", timeSeconds).Msg("Memory Service: Submitting score")
-	return s.scoreRepo.Create(userID, moves, timeSeconds)
+func (s *Service) GetState(userID, sessionID string) (*SessionState, error) {
+	session, game, err := s.load(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	revealed := map[int]int{}
+	for i, matched := range game.Matched {
+		if matched {
+			revealed[i] = game.Deck[i]
+		}
+	}
+	for _, i := range game.open {
+		revealed[i] = game.Deck[i]
+	}
+
+	return &SessionState{
+		SessionID: session.ID,
+		Status:    session.Status,
+		DailyDate: session.DailyDate,
+		Cards:     len(game.Deck),
+		Revealed:  revealed,
+		Moves:     len(game.Flips),
+		Matches:   game.Matches,
+	}, nil
 }
 
-func (s *Service) GetLeaderboard(limit int) ([]domain.Score, error) {
-	log.Debug().Int("limit", limit).Msg("Memory Service: Fetching leaderboard")
-	return s.scoreRepo.GetLeaderboard(limit)
+// Flip reveals one card, timestamped by the server. Finishing the board
+// records the score with moves and time derived from the flip log.
+func (s *Service) Flip(userID, sessionID string, index int) (*FlipResponse, error) {
+	session, game, err := s.load(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "active" {
+		return nil, ErrSessionClosed
+	}
+
+	res, err := game.Flip(Flip{Index: index, At: time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	prevCount := session.FlipCount
+	encoded, err := json.Marshal(game.Flips)
+	if err != nil {
+		return nil, err
+	}
+	session.Flips = string(encoded)
+	session.FlipCount = len(game.Flips)
+
+	resp := &FlipResponse{FlipResult: res}
+	var result *domain.GameResult
+	if res.Completed {
+		session.Suspicious = game.Suspicious()
+		score := &RunResult{
+			Moves:       len(game.Flips),
+			TimeSeconds: int(game.Elapsed(session.CreatedAt).Seconds()),
+			Suspicious:  session.Suspicious,
+		}
+		if session.Suspicious {
+			log.Warn().Str("user_id", userID).Str("session_id", sessionID).Float64("luck_bits", game.Luck).Msg("Memory Service: Implausibly lucky run flagged")
+		}
+		log.Debug().Str("user_id", userID).Int("moves", score.Moves).Int("time", score.TimeSeconds).Msg("Memory Service: Submitting score")
+		if result, err = s.newResult(session, score); err == nil {
+			err = s.scoreRepo.FinishSession(session, prevCount, result)
+		}
+		resp.Score = score
+	} else {
+		err = s.scoreRepo.UpdateSessionFlips(session, prevCount)
+	}
+	if err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrSessionBusy
+		}
+		return nil, err
+	}
+	if result != nil {
+		s.events.Publish(result)
+	}
+
+	return resp, nil
+}
+
+// load fetches a session owned by userID and replays its flips.
+func (s *Service) load(userID, sessionID string) (*domain.MemorySession, *Game, error) {
+	session, err := s.scoreRepo.GetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrSessionNotFound
+		}
+		return nil, nil, err
+	}
+	if session.UserID != userID {
+		return nil, nil, ErrSessionNotFound
+	}
+
+	var flips []Flip
+	if err := json.Unmarshal([]byte(session.Flips), &flips); err != nil {
+		log.Error().Err(err).Str("session_id", sessionID).Msg("Memory Service: Corrupt flip log")
+		return nil, nil, err
+	}
+	game, err := Replay(NewDeck(session.Seed, session.Pairs), flips)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", sessionID).Msg("Memory Service: Stored flip log no longer replays")
+		return nil, nil, err
+	}
+	return session, game, nil
 }
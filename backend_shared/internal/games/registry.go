@@ -0,0 +1,110 @@
+// Package games holds what every game has in common: the Game interface a
+// game implements to be listed, ranked and have its results checked, and
+// the registry the HTTP layer looks games up in. Each game lives in its own
+// subpackage.
+package games
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+)
+
+var (
+	ErrUnknownGame    = errors.New("unknown game")
+	ErrInvalidVariant = errors.New("invalid variant")
+	ErrInvalidResult  = errors.New("invalid game result")
+)
+
+// Field documents one entry of a game's result details.
+type Field struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Game is implemented by each game's service. Adding a game means
+// implementing it and registering the service in main; results, boards and
+// the generic endpoints come with it.
+type Game interface {
+	// ID is the stable key results are stored under and URLs use.
+	ID() string
+	Name() string
+	// Schema lists the fields every result's details must have.
+	Schema() []Field
+	Ranking() domain.Ranking
+	// Variant returns the canonical form of a leaderboard variant, or an
+	// error wrapping ErrInvalidVariant. Games without variants accept only
+	// the empty string.
+	Variant(raw string) (string, error)
+	// Validate rejects results the game could not have produced.
+	Validate(result *domain.GameResult) error
+}
+
+// Check validates result against g before it is stored: it must be for g,
+// carry every field of g's schema and pass g's own validation. Errors wrap
+// ErrInvalidResult.
+func Check(g Game, result *domain.GameResult) error {
+	if result.Game != g.ID() {
+		return fmt.Errorf("%w: result for %q given to %q", ErrInvalidResult, result.Game, g.ID())
+	}
+	var details map[string]json.RawMessage
+	if err := json.Unmarshal(result.Details, &details); err != nil {
+		return fmt.Errorf("%w: details: %v", ErrInvalidResult, err)
+	}
+	for _, f := range g.Schema() {
+		if _, ok := details[f.Name]; !ok {
+			return fmt.Errorf("%w: details lack %q", ErrInvalidResult, f.Name)
+		}
+	}
+	if err := g.Validate(result); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidResult, err)
+	}
+	return nil
+}
+
+// Registry is the set of games the server offers.
+type Registry struct {
+	games map[string]Game
+}
+
+// NewRegistry registers games. IDs must be unique; a duplicate is a wiring
+// mistake and panics.
+func NewRegistry(games ...Game) *Registry {
+	r := &Registry{games: make(map[string]Game, len(games))}
+	for _, g := range games {
+		if _, dup := r.games[g.ID()]; dup {
+			panic(fmt.Sprintf("games: %q registered twice", g.ID()))
+		}
+		r.games[g.ID()] = g
+	}
+	return r
+}
+
+func (r *Registry) Get(id string) (Game, error) {
+	g, ok := r.games[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownGame, id)
+	}
+	return g, nil
+}
+
+// All returns the registered games ordered by ID.
+func (r *Registry) All() []Game {
+	all := make([]Game, 0, len(r.games))
+	for _, g := range r.games {
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID() < all[j].ID() })
+	return all
+}
+
+// NoVariants implements Game.Variant for games that have a single board.
+func NoVariants(raw string) (string, error) {
+	if raw != "" {
+		return "", fmt.Errorf("%w: game has no variants", ErrInvalidVariant)
+	}
+	return "", nil
+}
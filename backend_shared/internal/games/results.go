@@ -0,0 +1,145 @@
+package games
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNotRanked is returned for a player with no line on a board.
+var ErrNotRanked = errors.New("no ranked result on this leaderboard")
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+// Service answers the questions that are the same for every game: what
+// games there are, who leads each board and what a player has played.
+type Service struct {
+	registry   *Registry
+	resultRepo *repos.ResultRepo
+	seasonRepo *repos.SeasonRepo
+}
+
+func NewService(registry *Registry, resultRepo *repos.ResultRepo, seasonRepo *repos.SeasonRepo) *Service {
+	return &Service{registry: registry, resultRepo: resultRepo, seasonRepo: seasonRepo}
+}
+
+func (s *Service) Games() []Game {
+	return s.registry.All()
+}
+
+// MaxNeighbors bounds how many lines PlayerRank shows either side.
+const MaxNeighbors = 10
+
+// Leaderboard returns a page of gameID's board for variant over window,
+// continuing from cursor if set. Each player appears once.
+func (s *Service) Leaderboard(gameID, variant, window, cursor string, limit int) (*domain.LeaderboardPage, error) {
+	b, err := s.board(gameID, variant, window)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("game", gameID).Str("variant", b.query.Variant).Str("window", b.window).Int("limit", limit).Msg("Games Service: Fetching leaderboard")
+	entries, next, err := s.resultRepo.Leaderboard(b.query, after, clampLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LeaderboardPage{Window: b.window, Season: b.season, Entries: entries, NextCursor: encodeCursor(next)}, nil
+}
+
+// PlayerRank returns where userID stands on gameID's board, or
+// ErrNotRanked if they have no ranked result in window.
+func (s *Service) PlayerRank(gameID, variant, window, userID string, neighbors int) (*domain.PlayerRank, error) {
+	b, err := s.board(gameID, variant, window)
+	if err != nil {
+		return nil, err
+	}
+	neighbors = min(max(neighbors, 0), MaxNeighbors)
+	rank, err := s.resultRepo.PlayerRank(b.query, userID, neighbors)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotRanked
+		}
+		return nil, err
+	}
+	rank.Window, rank.Season = b.window, b.season
+	return rank, nil
+}
+
+// board is a resolved leaderboard request.
+type board struct {
+	query  repos.BoardQuery
+	window string
+	season *domain.Season
+}
+
+// board resolves which board a request is for. Without a window it is the
+// running season's board, or the all-time one between seasons.
+func (s *Service) board(gameID, variant, window string) (*board, error) {
+	g, err := s.registry.Get(gameID)
+	if err != nil {
+		return nil, err
+	}
+	variant, err = g.Variant(variant)
+	if err != nil {
+		return nil, err
+	}
+	// A challenge's board is its own day's, whatever season that's in.
+	if _, ok := daily.ParseVariant(variant); ok && window == "" {
+		window = WindowAll
+	}
+	b := &board{
+		query:  repos.BoardQuery{Game: g.ID(), Variant: variant, Ranking: g.Ranking()},
+		window: window,
+	}
+
+	now := time.Now()
+	if window == "" || window == WindowSeason {
+		season, err := s.currentSeason(now)
+		if err != nil {
+			return nil, err
+		}
+		if season != nil {
+			b.window, b.season = WindowSeason, season
+			b.query.Since, b.query.Until = season.StartsAt, season.EndsAt
+			return b, nil
+		}
+		if window == WindowSeason {
+			return nil, ErrNoActiveSeason
+		}
+		b.window = WindowAll
+	}
+	if b.query.Since, err = windowStart(b.window, now); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UserResults returns userID's most recent results for gameID.
+func (s *Service) UserResults(gameID, userID string, limit int) ([]*domain.GameResult, error) {
+	g, err := s.registry.Get(gameID)
+	if err != nil {
+		return nil, err
+	}
+	return s.resultRepo.ListByUser(g.ID(), userID, clampLimit(limit))
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
@@ -0,0 +1,59 @@
+package rng
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// Mulberry32 is a small 32-bit PRNG. It is used instead of math/rand so the
+// exact same sequence can be reproduced by the browser client from a seed.
+//
+// Reference implementation (JavaScript):
+//
+//	function mulberry32(a) {
+//	  return function() {
+//	    a |= 0; a = a + 0x6D2B79F5 | 0;
+//	    let t = Math.imul(a ^ a >>> 15, 1 | a);
+//	    t = t + Math.imul(t ^ t >>> 7, 61 | t) ^ t;
+//	    return ((t ^ t >>> 14) >>> 0) / 4294967296;
+//	  }
+//	}
+type Mulberry32 struct {
+	state uint32
+}
+
+func New(seed uint32) *Mulberry32 {
+	return &Mulberry32{state: seed}
+}
+
+// Uint32 returns the next raw 32-bit value in the sequence.
+func (m *Mulberry32) Uint32() uint32 {
+	m.state += 0x6D2B79F5
+	t := m.state
+	t = (t ^ (t >> 15)) * (t | 1)
+	t ^= t + (t^(t>>7))*(t|61)
+	return t ^ (t >> 14)
+}
+
+// Float64 returns a value in [0, 1), matching the JavaScript reference.
+func (m *Mulberry32) Float64() float64 {
+	return float64(m.Uint32()) / 4294967296.0
+}
+
+// Intn returns a value in [0, n) computed as floor(Float64() * n), which is
+// how the client picks from a list.
+func (m *Mulberry32) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return int(m.Float64() * float64(n))
+}
+
+// NewSeed returns a cryptographically random seed for a new game session.
+func NewSeed() (uint32, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
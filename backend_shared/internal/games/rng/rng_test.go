@@ -0,0 +1,26 @@
+package rng
+
+import "testing"
+
+// The values the JavaScript reference in the Mulberry32 doc comment gives
+// for seed 42, scaled back to integers.
+func TestMulberry32MatchesReference(t *testing.T) {
+	r := New(42)
+	for i, want := range []uint32{2581720956, 1925393290, 3661312704, 2876485805} {
+		if got := r.Uint32(); got != want {
+			t.Fatalf("value %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestIntn(t *testing.T) {
+	r := New(7)
+	for i := 0; i < 1000; i++ {
+		if n := r.Intn(5); n < 0 || n >= 5 {
+			t.Fatalf("Intn(5) = %d", n)
+		}
+	}
+	if n := r.Intn(0); n != 0 {
+		t.Errorf("Intn(0) = %d, want 0", n)
+	}
+}
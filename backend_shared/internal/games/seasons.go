@@ -0,0 +1,226 @@
+package games
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/rs/zerolog/log"
+)
+
+// SeasonArchiveInterval is how often ended seasons are looked for.
+const SeasonArchiveInterval = time.Minute
+
+var (
+	ErrSeasonNotFound    = errors.New("season not found")
+	ErrSeasonNotArchived = errors.New("season has not ended yet")
+	ErrNoActiveSeason    = errors.New("no season is running")
+	ErrSeasonOverlap     = errors.New("seasons overlap")
+)
+
+// SeasonStandings are the archived final boards of one season.
+type SeasonStandings struct {
+	Season *domain.Season       `json:"season"`
+	Boards []domain.SeasonBoard `json:"boards"`
+}
+
+// SyncSeasons makes the stored seasons match the configured ones, matched
+// by name. Archived seasons are final: they are kept when dropped from the
+// configuration and their dates can no longer change. Seasons may not
+// overlap, so that at most one is running at a time.
+func (s *Service) SyncSeasons(configured []domain.Season) error {
+	stored, err := s.seasonRepo.List()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*domain.Season, len(stored))
+	for _, season := range stored {
+		byName[season.Name] = season
+	}
+
+	// What the seasons will be once synced, to check for overlaps first.
+	final := map[string]domain.Season{}
+	for _, season := range stored {
+		if season.ArchivedAt != nil {
+			final[season.Name] = *season
+		}
+	}
+	for _, season := range configured {
+		if old, ok := final[season.Name]; ok {
+			if !old.StartsAt.Equal(season.StartsAt) || !old.EndsAt.Equal(season.EndsAt) {
+				log.Warn().Str("season", season.Name).Msg("Games Service: Season is archived; ignoring its new dates")
+			}
+			continue
+		}
+		final[season.Name] = season
+	}
+	if err := checkOverlaps(final); err != nil {
+		return err
+	}
+
+	for _, season := range configured {
+		old, ok := byName[season.Name]
+		switch {
+		case !ok:
+			if err := s.seasonRepo.Create(&season); err != nil {
+				return err
+			}
+			log.Info().Str("season", season.Name).Time("starts_at", season.StartsAt).Time("ends_at", season.EndsAt).Msg("Games Service: Season added")
+		case old.ArchivedAt == nil && (!old.StartsAt.Equal(season.StartsAt) || !old.EndsAt.Equal(season.EndsAt)):
+			season.ID = old.ID
+			if err := s.seasonRepo.UpdateDates(&season); err != nil {
+				return err
+			}
+			log.Info().Str("season", season.Name).Time("starts_at", season.StartsAt).Time("ends_at", season.EndsAt).Msg("Games Service: Season dates changed")
+		}
+		delete(byName, season.Name)
+	}
+	for _, old := range byName {
+		if old.ArchivedAt != nil {
+			continue
+		}
+		if err := s.seasonRepo.Delete(old.ID); err != nil {
+			return err
+		}
+		log.Info().Str("season", old.Name).Msg("Games Service: Season removed")
+	}
+	return nil
+}
+
+func checkOverlaps(seasons map[string]domain.Season) error {
+	list := make([]domain.Season, 0, len(seasons))
+	for _, season := range seasons {
+		list = append(list, season)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.Before(list[j].StartsAt) })
+	for i := 1; i < len(list); i++ {
+		if list[i].StartsAt.Before(list[i-1].EndsAt) {
+			return fmt.Errorf("%w: %q and %q", ErrSeasonOverlap, list[i-1].Name, list[i].Name)
+		}
+	}
+	return nil
+}
+
+// Seasons lists every season, earliest first.
+func (s *Service) Seasons() ([]*domain.Season, error) {
+	seasons, err := s.seasonRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, season := range seasons {
+		setStatus(season, now)
+	}
+	return seasons, nil
+}
+
+// SeasonStandings returns the top limit lines of each archived board of a
+// season, only gameID's if it is set.
+func (s *Service) SeasonStandings(seasonID, gameID string, limit int) (*SeasonStandings, error) {
+	if gameID != "" {
+		if _, err := s.registry.Get(gameID); err != nil {
+			return nil, err
+		}
+	}
+	season, err := s.seasonRepo.Get(seasonID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSeasonNotFound
+		}
+		return nil, err
+	}
+	setStatus(season, time.Now())
+	if season.ArchivedAt == nil {
+		return nil, ErrSeasonNotArchived
+	}
+	boards, err := s.seasonRepo.Standings(season.ID, gameID, clampLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	return &SeasonStandings{Season: season, Boards: boards}, nil
+}
+
+// StartSeasonArchiver archives seasons that have already ended, then keeps
+// doing so in the background as others end.
+func (s *Service) StartSeasonArchiver() error {
+	if err := s.ArchiveEndedSeasons(time.Now()); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(SeasonArchiveInterval) {
+			if err := s.ArchiveEndedSeasons(time.Now()); err != nil {
+				log.Error().Err(err).Msg("Games Service: Failed to archive seasons")
+			}
+		}
+	}()
+	return nil
+}
+
+// ArchiveEndedSeasons stores the final standings of every board of each
+// season that ended by now and isn't archived yet.
+func (s *Service) ArchiveEndedSeasons(now time.Time) error {
+	seasons, err := s.seasonRepo.List()
+	if err != nil {
+		return err
+	}
+	for _, season := range seasons {
+		if season.ArchivedAt != nil || season.EndsAt.After(now) {
+			continue
+		}
+		var boards []repos.BoardQuery
+		for _, g := range s.registry.All() {
+			variants, err := s.seasonRepo.Variants(g.ID(), season.StartsAt, season.EndsAt)
+			if err != nil {
+				return err
+			}
+			for _, variant := range variants {
+				// A challenge's board is over with its day.
+				if _, ok := daily.ParseVariant(variant); ok {
+					continue
+				}
+				boards = append(boards, repos.BoardQuery{
+					Game: g.ID(), Variant: variant, Ranking: g.Ranking(),
+					Since: season.StartsAt, Until: season.EndsAt,
+				})
+			}
+		}
+		if err := s.seasonRepo.Archive(season.ID, boards); err != nil {
+			if errors.Is(err, repos.ErrSeasonArchived) {
+				continue
+			}
+			return err
+		}
+		log.Info().Str("season", season.Name).Int("boards", len(boards)).Msg("Games Service: Season archived")
+	}
+	return nil
+}
+
+// currentSeason returns the season running at now, or nil.
+func (s *Service) currentSeason(now time.Time) (*domain.Season, error) {
+	seasons, err := s.seasonRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, season := range seasons {
+		if setStatus(season, now); season.Status == domain.SeasonActive {
+			return season, nil
+		}
+	}
+	return nil, nil
+}
+
+func setStatus(season *domain.Season, now time.Time) {
+	switch {
+	case now.Before(season.StartsAt):
+		season.Status = domain.SeasonUpcoming
+	case now.Before(season.EndsAt):
+		season.Status = domain.SeasonActive
+	default:
+		season.Status = domain.SeasonEnded
+	}
+}
@@ -0,0 +1,124 @@
+package tictactoe
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownPosition = errors.New("position cannot arise in play")
+	// ErrNotSolved is returned when analysis is asked for on a ruleset the
+	// solver has no table for.
+	ErrNotSolved = errors.New("analysis is only available for the classic ruleset")
+)
+
+// MoveAnalysis rates one legal move for the player making it. Removes is
+// the mover's piece this move takes off the board, and VanishesNext the one
+// their following move will take, if their queue is full.
+type MoveAnalysis struct {
+	Index        int     `json:"index"`
+	Outcome      Outcome `json:"outcome"`
+	Distance     int     `json:"distance"`
+	Best         bool    `json:"best"`
+	Removes      *int    `json:"removes,omitempty"`
+	VanishesNext *int    `json:"vanishes_next,omitempty"`
+}
+
+type PositionAnalysis struct {
+	Turn       string         `json:"turn"`
+	Evaluation Evaluation     `json:"evaluation"`
+	Moves      []MoveAnalysis `json:"moves"`
+}
+
+// InferTurn works out whose move it is from the queue lengths. X moves
+// first, so X is to move when both sides have the same number of pieces,
+// unless both queues are full, which is ambiguous and resolved in favour of
+// the AI as /play does.
+func InferTurn(rules Rules, xQueue, oQueue []int) string {
+	if len(xQueue) == len(oQueue) && len(xQueue) < rules.MaxPieces {
+		return PlayerHuman
+	}
+	return PlayerAI
+}
+
+// AnalyzePosition evaluates every legal move for the side to move on the
+// Classic board.
+func AnalyzePosition(xQueue, oQueue []int, turn string) (*PositionAnalysis, error) {
+	table := Solution()
+	eval, ok := table.Lookup(xQueue, oQueue, turn)
+	if !ok {
+		return nil, ErrUnknownPosition
+	}
+	moves, _ := table.EvaluateMoves(xQueue, oQueue, turn)
+
+	queue := xQueue
+	if turn == PlayerAI {
+		queue = oQueue
+	}
+
+	analysis := &PositionAnalysis{Turn: turn, Evaluation: eval, Moves: make([]MoveAnalysis, 0, len(moves))}
+	for _, m := range moves {
+		a := MoveAnalysis{
+			Index:    m.Index,
+			Outcome:  m.Outcome,
+			Distance: m.Distance,
+			Best:     !better(eval, m.Evaluation),
+		}
+		if len(queue) >= Classic.MaxPieces {
+			removed := queue[0]
+			a.Removes = &removed
+		}
+		if next := pushQueue(queue, m.Index, Classic.MaxPieces); len(next) >= Classic.MaxPieces {
+			vanishes := next[0]
+			a.VanishesNext = &vanishes
+		}
+		analysis.Moves = append(analysis.Moves, a)
+	}
+	return analysis, nil
+}
+
+// PlyReview grades one move of a finished match against the solved table.
+// A blunder is a move that turns a win into a draw or loss, or a draw into
+// a loss.
+type PlyReview struct {
+	Ply       int        `json:"ply"`
+	Player    string     `json:"player"`
+	Index     int        `json:"index"`
+	Before    Evaluation `json:"before"`
+	Played    Evaluation `json:"played"`
+	BestMoves []int      `json:"best_moves"`
+	Blunder   bool       `json:"blunder"`
+}
+
+// ReviewGame replays a move list from the empty board and reviews each ply.
+// Only Classic matches can be reviewed.
+func ReviewGame(rules Rules, moves []int) ([]PlyReview, error) {
+	if rules != Classic {
+		return nil, ErrNotSolved
+	}
+	g := NewGame(rules)
+	reviews := make([]PlyReview, 0, len(moves))
+	for i, m := range moves {
+		analysis, err := AnalyzePosition(g.XQueue, g.OQueue, g.Turn)
+		if err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+
+		review := PlyReview{Ply: i + 1, Player: g.Turn, Index: m, Before: analysis.Evaluation, BestMoves: []int{}}
+		for _, a := range analysis.Moves {
+			if a.Best {
+				review.BestMoves = append(review.BestMoves, a.Index)
+			}
+			if a.Index == m {
+				review.Played = Evaluation{Outcome: a.Outcome, Distance: a.Distance}
+			}
+		}
+		review.Blunder = review.Played.Outcome < review.Before.Outcome
+
+		if err := g.Play(m); err != nil {
+			return nil, fmt.Errorf("ply %d: %w", i+1, err)
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
@@ -0,0 +1,87 @@
+package tictactoe
+
+import (
+	"math/rand/v2"
+)
+
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// Level controls how well the AI plays.
+type Level struct {
+	// Depth is how many plies the search looks ahead after the AI's move
+	// before falling back to the heuristic. For levels without mistakes it
+	// is the iterative-deepening limit instead.
+	Depth int
+	// MistakeRate is the chance the AI ignores its search and plays one of
+	// the moves it rated lower than the best.
+	MistakeRate float64
+	// Perfect plays from the solved table instead of searching, on the
+	// rulesets that have one.
+	Perfect bool
+}
+
+var levels = map[Difficulty]Level{
+	Easy:   {Depth: 1, MistakeRate: 0.35},
+	Medium: {Depth: 3, MistakeRate: 0.12},
+	Hard:   {Depth: 12, Perfect: true},
+}
+
+func ParseDifficulty(s string) (Difficulty, error) {
+	d := Difficulty(s)
+	if _, ok := levels[d]; !ok {
+		return "", ErrInvalidDifficulty
+	}
+	return d, nil
+}
+
+// ChooseMove picks the AI's (O) move at the given difficulty. Moves that
+// score equally are chosen between at random so the AI doesn't always open
+// the same way. Returns -1 if no moves are available.
+func ChooseMove(rules Rules, board []string, xQueue, oQueue []int, d Difficulty) int {
+	level, ok := levels[d]
+	if !ok {
+		level = levels[Hard]
+	}
+
+	if level.Perfect && rules == Classic {
+		if best, ok := bestSolvedMoves(xQueue, oQueue, PlayerAI); ok {
+			return best[rand.IntN(len(best))]
+		}
+	}
+
+	s := newSearcher(rules, board, xQueue, oQueue, PlayerAI, true)
+	if level.MistakeRate == 0 {
+		return s.bestMove(level.Depth, SearchBudget)
+	}
+
+	scored := s.scoreMoves(level.Depth)
+	if len(scored) == 0 {
+		return -1
+	}
+
+	bestScore := scored[0].score
+	for _, m := range scored {
+		if m.score > bestScore {
+			bestScore = m.score
+		}
+	}
+	var best, worse []int
+	for _, m := range scored {
+		if m.score == bestScore {
+			best = append(best, m.index)
+		} else {
+			worse = append(worse, m.index)
+		}
+	}
+
+	if len(worse) > 0 && rand.Float64() < level.MistakeRate {
+		return worse[rand.IntN(len(worse))]
+	}
+	return best[rand.IntN(len(best))]
+}
@@ -0,0 +1,98 @@
+package tictactoe
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxPlies ends a match as a draw. Pieces vanish, so without a cap two
+// careful players could cycle forever.
+const MaxPlies = 100
+
+var ErrIllegalMove = errors.New("illegal move")
+
+// Game is a server-held match. X moves first: the human against the AI, or
+// the match creator in PvP. O is the AI or the player who joined.
+type Game struct {
+	Rules  Rules    `json:"rules"`
+	Board  []string `json:"board"`
+	XQueue []int    `json:"xQueue"`
+	OQueue []int    `json:"oQueue"`
+	Turn   string   `json:"turn"`
+	Winner string   `json:"winner,omitempty"`
+	Over   bool     `json:"over"`
+	Moves  []int    `json:"moves"`
+}
+
+func NewGame(rules Rules) *Game {
+	return &Game{
+		Rules:  rules,
+		Board:  make([]string, rules.Cells()),
+		XQueue: []int{},
+		OQueue: []int{},
+		Turn:   PlayerHuman,
+		Moves:  []int{},
+	}
+}
+
+// Play places a piece for the player whose turn it is. The cell must be
+// empty before that player's oldest piece is removed, as in the search.
+func (g *Game) Play(index int) error {
+	if g.Over {
+		return fmt.Errorf("%w: match is over", ErrIllegalMove)
+	}
+	if index < 0 || index >= len(g.Board) {
+		return fmt.Errorf("%w: cell %d does not exist", ErrIllegalMove, index)
+	}
+	if g.Board[index] != Empty {
+		return fmt.Errorf("%w: cell %d is taken", ErrIllegalMove, index)
+	}
+
+	queue := &g.XQueue
+	if g.Turn == PlayerAI {
+		queue = &g.OQueue
+	}
+	if len(*queue) >= g.Rules.MaxPieces {
+		g.Board[(*queue)[0]] = Empty
+		*queue = (*queue)[1:]
+	}
+	*queue = append(*queue, index)
+	g.Board[index] = g.Turn
+	g.Moves = append(g.Moves, index)
+
+	if w := g.Rules.Winner(g.Board); w != "" {
+		g.Winner = w
+		g.Over = true
+	} else if len(g.Moves) >= MaxPlies {
+		g.Over = true
+	}
+
+	if g.Turn == PlayerHuman {
+		g.Turn = PlayerAI
+	} else {
+		g.Turn = PlayerHuman
+	}
+	return nil
+}
+
+// Result is the outcome from the human's point of view: win, loss or draw.
+func (g *Game) Result() string {
+	switch g.Winner {
+	case PlayerHuman:
+		return "win"
+	case PlayerAI:
+		return "loss"
+	}
+	return "draw"
+}
+
+// ReplayGame rebuilds a match from its move list.
+func ReplayGame(rules Rules, moves []int) (*Game, error) {
+	g := NewGame(rules)
+	for i, m := range moves {
+		if err := g.Play(m); err != nil {
+			return nil, fmt.Errorf("move %d: %w", i+1, err)
+		}
+	}
+	return g, nil
+}
@@ -1,5 +1,10 @@
 package tictactoe
 
+import (
+	"math/rand/v2"
+	"time"
+)
+
 // Constants for board values
 const (
 	PlayerHuman = "X"
@@ -7,148 +12,407 @@ const (
 	Empty       = ""
 )
 
-// GetBestMove returns the best move for the AI (PlayerAI/O).
+const (
+	winScore = 1_000_000
+	infinity = 2 * winScore
+
+	// SearchBudget caps how long a single iterative-deepening search may run.
+	SearchBudget = 300 * time.Millisecond
+)
+
+// GetBestMove returns the best move for the AI (PlayerAI/O) on the classic
+// board, always taking the first of equally good moves. Positions in the
+// solved table are answered from it; anything else falls back to search.
 // Returns the index (0-8) of the move.
 // Returns -1 if no moves are available.
 func GetBestMove(board []string, xQueue, oQueue []int) int {
-	bestScore := -1000
-	bestMove := -1
-
-	// AI is 'O'. If it has 3 moves, the oldest one (oQueue[0]) will be removed.
-	simBoard := make([]string, len(board))
-	copy(simBoard, board)
-
-	for i := 0; i < len(board); i++ {
-		if board[i] == Empty {
-			// Simulate the move
-
-			// 1. Handle Queue Logic for AI
-			var newOQueue []int
-			removedIndex := -1
-
-			if len(oQueue) >= 3 {
-				removedIndex = oQueue[0]
-				simBoard[removedIndex] = Empty // Oldest piece disappears
-				newOQueue = oQueue[1:]
-			} else {
-				newOQueue = make([]int, len(oQueue))
-				copy(newOQueue, oQueue)
-			}
-			newOQueue = append(newOQueue, i)
+	if moves, ok := bestSolvedMoves(xQueue, oQueue, PlayerAI); ok {
+		return moves[0]
+	}
+	return newSearcher(Classic, board, xQueue, oQueue, PlayerAI, false).bestMove(levels[Hard].Depth, SearchBudget)
+}
 
-			// 2. Place piece
-			simBoard[i] = PlayerAI
+// queue is a player's pieces, oldest first, kept in a ring buffer so a move
+// and its undo are O(1).
+type queue struct {
+	buf  [MaxPiecesCap]int
+	head int
+	n    int
+	cap  int
+}
 
-			// Run Minimax
-			// Decrease depth limit
-			score := minimax(simBoard, xQueue, newOQueue, 0, false)
+func (q *queue) at(i int) int {
+	return q.buf[(q.head+i)%q.cap]
+}
 
-			// Undo the move (backtrack)
-			simBoard[i] = Empty
-			if removedIndex != -1 {
-				simBoard[removedIndex] = PlayerAI // Put it back
-			}
+// push adds cell and returns the cell it pushed out, or -1.
+func (q *queue) push(cell int) int {
+	if q.n == q.cap {
+		removed := q.buf[q.head]
+		q.buf[q.head] = cell
+		q.head = (q.head + 1) % q.cap
+		return removed
+	}
+	q.buf[(q.head+q.n)%q.cap] = cell
+	q.n++
+	return -1
+}
+
+func (q *queue) undo(removed int) {
+	if removed >= 0 {
+		q.head = (q.head - 1 + q.cap) % q.cap
+		q.buf[q.head] = removed
+		return
+	}
+	q.n--
+}
+
+type ttFlag int8
+
+const (
+	ttExact ttFlag = iota
+	ttLower
+	ttUpper
+)
+
+type ttEntry struct {
+	depth int
+	score int
+	flag  ttFlag
+	move  int
+}
+
+// searcher is a negamax alpha-beta search with a transposition table.
+// Players are 1 (X) and 2 (O) internally; scores are from the point of view
+// of the side to move.
+type searcher struct {
+	rules     Rules
+	lines     [][]int
+	cellLines [][]int
+	board     []int8
+	queues    [3]queue
+	toMove    int8
+	zobrist   [3][MaxPiecesCap][]uint64
+
+	tt       map[uint64]ttEntry
+	path     map[uint64]bool
+	deadline time.Time
+	nodes    int
+	aborted  bool
+	shuffle  bool
+}
 
-			if score > bestScore {
-				bestScore = score
-				bestMove = i
+func newSearcher(rules Rules, board []string, xQueue, oQueue []int, toMove string, shuffle bool) *searcher {
+	s := &searcher{
+		rules:   rules,
+		lines:   rules.Lines(),
+		board:   make([]int8, rules.Cells()),
+		tt:      map[uint64]ttEntry{},
+		path:    map[uint64]bool{},
+		shuffle: shuffle,
+	}
+	s.cellLines = make([][]int, rules.Cells())
+	for li, line := range s.lines {
+		for _, c := range line {
+			s.cellLines[c] = append(s.cellLines[c], li)
+		}
+	}
+	for p := 1; p <= 2; p++ {
+		s.queues[p].cap = rules.MaxPieces
+		for age := 0; age < rules.MaxPieces; age++ {
+			s.zobrist[p][age] = make([]uint64, rules.Cells())
+			for c := range s.zobrist[p][age] {
+				s.zobrist[p][age][c] = rand.Uint64()
 			}
 		}
 	}
-	return bestMove
+	for _, c := range xQueue {
+		s.queues[1].push(c)
+		s.board[c] = 1
+	}
+	for _, c := range oQueue {
+		s.queues[2].push(c)
+		s.board[c] = 2
+	}
+	s.toMove = 1
+	if toMove == PlayerAI {
+		s.toMove = 2
+	}
+	return s
 }
 
-func minimax(board []string, xQueue, oQueue []int, depth int, isMaximizing bool) int {
-	winner := checkWinner(board)
-	if winner == PlayerAI {
-		return 10 - depth
+// hash identifies the position including the age order of each queue,
+// since that decides which piece vanishes next.
+func (s *searcher) hash() uint64 {
+	h := uint64(s.toMove)
+	for p := 1; p <= 2; p++ {
+		q := &s.queues[p]
+		for i := 0; i < q.n; i++ {
+			h ^= s.zobrist[p][i][q.at(i)]
+		}
 	}
-	if winner == PlayerHuman {
-		return depth - 10
+	return h
+}
+
+func (s *searcher) play(cell int) int {
+	p := s.toMove
+	removed := s.queues[p].push(cell)
+	if removed >= 0 {
+		s.board[removed] = 0
 	}
+	s.board[cell] = p
+	s.toMove = 3 - p
+	return removed
+}
 
-	if depth > 4 { // Reduced depth for performance
-		return 0
+func (s *searcher) unplay(cell, removed int) {
+	s.toMove = 3 - s.toMove
+	p := s.toMove
+	s.board[cell] = 0
+	s.queues[p].undo(removed)
+	if removed >= 0 {
+		s.board[removed] = p
 	}
+}
 
-	if isMaximizing {
-		bestScore := -1000
-		for i := 0; i < len(board); i++ {
-			if board[i] == Empty {
-				// Simulate AI Move (O)
-				var newOQueue []int
-				removedIndex := -1
-
-				// Copy board for simulation
-				simBoard := make([]string, len(board))
-				copy(simBoard, board)
-
-				if len(oQueue) >= 3 {
-					removedIndex = oQueue[0]
-					simBoard[removedIndex] = Empty
-					newOQueue = oQueue[1:]
-				} else {
-					newOQueue = make([]int, len(oQueue))
-					copy(newOQueue, oQueue)
-				}
-				newOQueue = append(newOQueue, i)
-				simBoard[i] = PlayerAI
+// completes reports whether the piece just placed on cell finished a line.
+func (s *searcher) completes(cell int) bool {
+	p := s.board[cell]
+	for _, li := range s.cellLines[cell] {
+		won := true
+		for _, c := range s.lines[li] {
+			if s.board[c] != p {
+				won = false
+				break
+			}
+		}
+		if won {
+			return true
+		}
+	}
+	return false
+}
 
-				score := minimax(simBoard, xQueue, newOQueue, depth+1, false)
+func (s *searcher) moves() []int {
+	var moves []int
+	for c, v := range s.board {
+		if v == 0 {
+			moves = append(moves, c)
+		}
+	}
+	if s.shuffle {
+		rand.Shuffle(len(moves), func(i, j int) { moves[i], moves[j] = moves[j], moves[i] })
+	}
+	return moves
+}
+
+// evaluate scores an undecided position by open lines: a line holding only
+// one player's pieces is worth 4^n to that player, where n counts the pieces
+// in it that won't vanish on their owner's next move. A fading piece still
+// blocks the opponent's line until it goes.
+func (s *searcher) evaluate() int {
+	fading := [3]int{-1, -1, -1}
+	for p := 1; p <= 2; p++ {
+		if q := &s.queues[p]; q.n == q.cap {
+			fading[p] = q.at(0)
+		}
+	}
 
-				if score > bestScore {
-					bestScore = score
+	score := 0
+	for _, line := range s.lines {
+		var present, strong [3]int
+		for _, c := range line {
+			if v := s.board[c]; v != 0 {
+				present[v]++
+				if c != fading[v] {
+					strong[v]++
 				}
 			}
 		}
-		return bestScore
-	} else {
-		bestScore := 1000
-		for i := 0; i < len(board); i++ {
-			if board[i] == Empty {
-				// Simulate Human Move (X)
-				var newXQueue []int
-				removedIndex := -1
-
-				// Copy board
-				simBoard := make([]string, len(board))
-				copy(simBoard, board)
-
-				if len(xQueue) >= 3 {
-					removedIndex = xQueue[0]
-					simBoard[removedIndex] = Empty
-					newXQueue = xQueue[1:]
-				} else {
-					newXQueue = make([]int, len(xQueue))
-					copy(newXQueue, xQueue)
-				}
-				newXQueue = append(newXQueue, i)
-				simBoard[i] = PlayerHuman
+		switch {
+		case present[1] > 0 && present[2] == 0:
+			score -= 1 << (2 * strong[1])
+		case present[2] > 0 && present[1] == 0:
+			score += 1 << (2 * strong[2])
+		}
+	}
+	if s.toMove == 1 {
+		return -score
+	}
+	return score
+}
 
-				score := minimax(simBoard, newXQueue, oQueue, depth+1, true)
+func (s *searcher) negamax(depth, ply, alpha, beta int) int {
+	s.nodes++
+	if s.nodes&1023 == 0 && !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.aborted = true
+	}
+	if s.aborted {
+		return 0
+	}
 
-				if score < bestScore {
-					bestScore = score
-				}
+	key := s.hash()
+	if s.path[key] {
+		return 0 // Repetition: neither side can force progress along this line
+	}
+	if depth <= 0 {
+		return s.evaluate()
+	}
+
+	origAlpha := alpha
+	ttMove := -1
+	if e, ok := s.tt[key]; ok {
+		ttMove = e.move
+		if e.depth >= depth {
+			score := fromTT(e.score, ply)
+			switch {
+			case e.flag == ttExact:
+				return score
+			case e.flag == ttLower && score > alpha:
+				alpha = score
+			case e.flag == ttUpper && score < beta:
+				beta = score
 			}
+			if alpha >= beta {
+				return score
+			}
+		}
+	}
+
+	moves := s.moves()
+	if ttMove >= 0 {
+		for i, m := range moves {
+			if m == ttMove {
+				moves[0], moves[i] = moves[i], moves[0]
+				break
+			}
+		}
+	}
+
+	s.path[key] = true
+	best, bestMove := -infinity, -1
+	for _, m := range moves {
+		removed := s.play(m)
+		var score int
+		if s.completes(m) {
+			score = winScore - ply
+		} else {
+			score = -s.negamax(depth-1, ply+1, -beta, -alpha)
+		}
+		s.unplay(m, removed)
+
+		if score > best {
+			best, bestMove = score, m
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
 		}
-		return bestScore
 	}
+	delete(s.path, key)
+
+	if !s.aborted {
+		flag := ttExact
+		if best <= origAlpha {
+			flag = ttUpper
+		} else if best >= beta {
+			flag = ttLower
+		}
+		s.tt[key] = ttEntry{depth: depth, score: toTT(best, ply), flag: flag, move: bestMove}
+	}
+	return best
 }
 
-func checkWinner(board []string) string {
-	lines := [][]int{
-		{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, // Rows
-		{0, 3, 6}, {1, 4, 7}, {2, 5, 8}, // Cols
-		{0, 4, 8}, {2, 4, 6}, // Diagonals
+// Win scores are stored relative to the node so they stay valid when the
+// same position is reached at a different ply.
+func toTT(score, ply int) int {
+	if score > winScore/2 {
+		return score + ply
+	}
+	if score < -winScore/2 {
+		return score - ply
 	}
+	return score
+}
 
-	for _, line := range lines {
-		if board[line[0]] != Empty &&
-			board[line[0]] == board[line[1]] &&
-			board[line[1]] == board[line[2]] {
-			return board[line[0]]
+func fromTT(score, ply int) int {
+	if score > winScore/2 {
+		return score - ply
+	}
+	if score < -winScore/2 {
+		return score + ply
+	}
+	return score
+}
+
+type scoredMove struct {
+	index int
+	score int
+}
+
+// scoreMoves searches every legal move to depth with a full window, so each
+// score is exact. Used for the shallow levels that need all of them.
+func (s *searcher) scoreMoves(depth int) []scoredMove {
+	var scored []scoredMove
+	for _, m := range s.moves() {
+		removed := s.play(m)
+		score := winScore
+		if !s.completes(m) {
+			score = -s.negamax(depth, 1, -infinity, infinity)
+		}
+		s.unplay(m, removed)
+		scored = append(scored, scoredMove{index: m, score: score})
+	}
+	return scored
+}
+
+// bestMove runs iterative deepening until maxDepth or the time budget runs
+// out, returning the best move of the last completed iteration.
+func (s *searcher) bestMove(maxDepth int, budget time.Duration) int {
+	moves := s.moves()
+	if len(moves) == 0 {
+		return -1
+	}
+	s.deadline = time.Now().Add(budget)
+
+	best := moves[0]
+	for depth := 1; depth <= maxDepth; depth++ {
+		iterBest, iterScore := -1, -infinity
+		alpha := -infinity
+		for _, m := range moves {
+			removed := s.play(m)
+			score := winScore
+			if !s.completes(m) {
+				score = -s.negamax(depth-1, 1, -infinity, -alpha)
+			}
+			s.unplay(m, removed)
+			if s.aborted {
+				break
+			}
+			if score > iterScore {
+				iterBest, iterScore = m, score
+			}
+			if score > alpha {
+				alpha = score
+			}
+		}
+		if s.aborted {
+			break
+		}
+		best = iterBest
+		if iterScore >= winScore-depth || iterScore <= -winScore+depth {
+			break // Forced result found; deeper search can't change it
+		}
+		// Search the previous best first next time.
+		for i, m := range moves {
+			if m == best {
+				copy(moves[1:i+1], moves[:i])
+				moves[0] = best
+				break
+			}
 		}
 	}
-	return ""
+	return best
 }
@@ -0,0 +1,338 @@
+package tictactoe
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/rs/zerolog/log"
+)
+
+// TurnTimeout is how long a player may take over a move in a PvP match
+// before forfeiting it. The clock keeps running while they are
+// disconnected, so it also bounds how long a dropped player can take to
+// reconnect.
+const TurnTimeout = 60 * time.Second
+
+var (
+	ErrNotYourTurn   = errors.New("it is not your turn")
+	ErrNotAPlayer    = errors.New("you are not playing in this match")
+	ErrMatchFull     = errors.New("match already has two players")
+	ErrMatchNotReady = errors.New("match is waiting for an opponent")
+)
+
+// Reasons a PvP match ended.
+const (
+	EndLine     = "line"
+	EndMaxPlies = "max_plies"
+	EndTimeout  = "timeout"
+	EndResign   = "resign"
+)
+
+type PvPPlayer struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// PvPState is the shared view of a match sent to both players.
+type PvPState struct {
+	MatchID      string     `json:"match_id"`
+	Ruleset      string     `json:"ruleset"`
+	Status       string     `json:"status"`
+	X            PvPPlayer  `json:"x"`
+	O            *PvPPlayer `json:"o,omitempty"`
+	Game         *Game      `json:"game"`
+	TurnDeadline *time.Time `json:"turn_deadline,omitempty"`
+	Winner       string     `json:"winner,omitempty"`
+	EndReason    string     `json:"end_reason,omitempty"`
+}
+
+// Symbol returns the side userID plays, or "" if they are not in the match.
+func (st *PvPState) Symbol(userID string) string {
+	switch {
+	case st.X.UserID == userID:
+		return PlayerHuman
+	case st.O != nil && st.O.UserID == userID:
+		return PlayerAI
+	}
+	return ""
+}
+
+func (s *Service) CreatePvPMatch(userID, ruleset string) (*PvPState, error) {
+	rules, err := ParseRules(ruleset)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.matchRepo.CreatePvPSession(userID, rules.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("match_id", session.ID).Str("user_id", userID).Str("ruleset", session.Ruleset).Msg("TicTacToe Service: PvP match created")
+	return pvpState(session, NewGame(rules)), nil
+}
+
+// GetPvPMatch returns the current state of a match. Anyone signed in may
+// look at it, which is how an invited player finds it before joining. An
+// overdue turn is forfeited here, so the result never depends on a timer
+// having fired.
+func (s *Service) GetPvPMatch(matchID string) (*PvPState, error) {
+	session, game, err := s.loadPvP(matchID)
+	if err != nil {
+		return nil, err
+	}
+	return s.settleOverdue(session, game)
+}
+
+// JoinPvPMatch seats userID as O in a waiting match. Joining a match one
+// already plays in is a no-op, so clients can call it on every reconnect.
+func (s *Service) JoinPvPMatch(userID, matchID string) (*PvPState, error) {
+	session, game, err := s.loadPvP(matchID)
+	if err != nil {
+		return nil, err
+	}
+	if session.XUserID == userID || session.OUserID == userID {
+		return s.settleOverdue(session, game)
+	}
+	if session.Status != "waiting" {
+		return nil, ErrMatchFull
+	}
+
+	if err := s.matchRepo.JoinPvPSession(matchID, userID, time.Now().UTC()); err != nil {
+		if errors.Is(err, repos.ErrSessionNotActive) {
+			return nil, ErrMatchFull
+		}
+		return nil, err
+	}
+
+	log.Info().Str("match_id", matchID).Str("user_id", userID).Msg("TicTacToe Service: PvP match joined")
+	return s.GetPvPMatch(matchID)
+}
+
+// PlayPvPMove applies userID's move if it is their turn and writes the
+// matches rows for both players once the move ends the match.
+func (s *Service) PlayPvPMove(userID, matchID string, index int) (*PvPState, error) {
+	session, game, err := s.loadPvP(matchID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.settleOverdue(session, game)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := state.Symbol(userID)
+	switch {
+	case symbol == "":
+		return nil, ErrNotAPlayer
+	case session.Status == "waiting":
+		return nil, ErrMatchNotReady
+	case session.Status != "active":
+		return nil, ErrMatchClosed
+	case symbol != game.Turn:
+		return nil, ErrNotYourTurn
+	}
+
+	if err := game.Play(index); err != nil {
+		return nil, err
+	}
+
+	prevCount := session.MoveCount
+	encoded, err := json.Marshal(game.Moves)
+	if err != nil {
+		return nil, err
+	}
+	session.Moves = string(encoded)
+	session.MoveCount = len(game.Moves)
+
+	if game.Over {
+		reason := EndLine
+		if game.Winner == "" {
+			reason = EndMaxPlies
+		}
+		err = s.finishPvP(session, prevCount, game.Winner, reason)
+	} else {
+		now := time.Now().UTC()
+		session.TurnStartedAt = &now
+		err = s.matchRepo.UpdatePvPSession(session, prevCount)
+	}
+	if err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrMatchBusy
+		}
+		return nil, err
+	}
+	return pvpState(session, game), nil
+}
+
+// ResignPvPMatch ends an active match as a loss for userID.
+func (s *Service) ResignPvPMatch(userID, matchID string) (*PvPState, error) {
+	session, game, err := s.loadPvP(matchID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.settleOverdue(session, game)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := state.Symbol(userID)
+	switch {
+	case symbol == "":
+		return nil, ErrNotAPlayer
+	case session.Status == "waiting":
+		return nil, ErrMatchNotReady
+	case session.Status != "active":
+		return nil, ErrMatchClosed
+	}
+
+	if err := s.finishPvP(session, session.MoveCount, opponentOf(symbol), EndResign); err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrMatchBusy
+		}
+		return nil, err
+	}
+	return pvpState(session, game), nil
+}
+
+// ActivePvPMatches lists the matches currently being played.
+func (s *Service) ActivePvPMatches() ([]*PvPState, error) {
+	sessions, err := s.matchRepo.ListActivePvPSessions()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*PvPState, 0, len(sessions))
+	for _, session := range sessions {
+		game, err := replayPvP(session)
+		if err != nil {
+			log.Error().Err(err).Str("match_id", session.ID).Msg("TicTacToe Service: Skipping PvP match that no longer replays")
+			continue
+		}
+		states = append(states, pvpState(session, game))
+	}
+	return states, nil
+}
+
+// settleOverdue forfeits the match for the player to move if their turn
+// clock has run out, and returns the resulting state.
+func (s *Service) settleOverdue(session *domain.PvPSession, game *Game) (*PvPState, error) {
+	state := pvpState(session, game)
+	if session.Status != "active" || state.TurnDeadline == nil || time.Now().Before(*state.TurnDeadline) {
+		return state, nil
+	}
+
+	err := s.finishPvP(session, session.MoveCount, opponentOf(game.Turn), EndTimeout)
+	if errors.Is(err, repos.ErrStaleSession) {
+		// Someone moved or settled it first; report what they left.
+		session, game, err = s.loadPvP(session.ID)
+		if err != nil {
+			return nil, err
+		}
+		return pvpState(session, game), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("match_id", session.ID).Str("forfeited_by", game.Turn).Msg("TicTacToe Service: PvP turn timed out")
+	return pvpState(session, game), nil
+}
+
+// finishPvP closes the match with winner ("" for a draw) and records a
+// result for each player.
+func (s *Service) finishPvP(session *domain.PvPSession, prevCount int, winner, reason string) error {
+	results := make([]*domain.GameResult, 0, 2)
+	for _, p := range []struct{ userID, opponentID, symbol string }{
+		{session.XUserID, session.OUserID, PlayerHuman},
+		{session.OUserID, session.XUserID, PlayerAI},
+	} {
+		result := "draw"
+		if winner == p.symbol {
+			result = "win"
+		} else if winner != "" {
+			result = "loss"
+		}
+		r, err := s.newResult(p.userID, p.opponentID, session.ID, session.Ruleset, ResultDetails{
+			Difficulty: difficultyPvP,
+			Result:     result,
+			Moves:      session.MoveCount,
+		})
+		if err != nil {
+			return err
+		}
+		results = append(results, r)
+	}
+
+	session.Winner = winner
+	session.EndReason = reason
+	log.Info().Str("match_id", session.ID).Str("winner", winner).Str("reason", reason).Msg("TicTacToe Service: Saving PvP match")
+	if err := s.matchRepo.FinishPvPSession(session, prevCount, results); err != nil {
+		return err
+	}
+	session.Status = "finished"
+	s.events.Publish(results...)
+	return nil
+}
+
+func (s *Service) loadPvP(matchID string) (*domain.PvPSession, *Game, error) {
+	session, err := s.matchRepo.GetPvPSession(matchID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrMatchNotFound
+		}
+		return nil, nil, err
+	}
+	game, err := replayPvP(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, game, nil
+}
+
+func replayPvP(session *domain.PvPSession) (*Game, error) {
+	rules, err := ParseRules(session.Ruleset)
+	if err != nil {
+		return nil, fmt.Errorf("stored match %s has a bad ruleset: %w", session.ID, err)
+	}
+	var moves []int
+	if err := json.Unmarshal([]byte(session.Moves), &moves); err != nil {
+		log.Error().Err(err).Str("match_id", session.ID).Msg("TicTacToe Service: Corrupt PvP move list")
+		return nil, err
+	}
+	game, err := ReplayGame(rules, moves)
+	if err != nil {
+		return nil, fmt.Errorf("stored match %s no longer replays: %w", session.ID, err)
+	}
+	return game, nil
+}
+
+func pvpState(session *domain.PvPSession, game *Game) *PvPState {
+	state := &PvPState{
+		MatchID:   session.ID,
+		Ruleset:   session.Ruleset,
+		Status:    session.Status,
+		X:         PvPPlayer{UserID: session.XUserID, Username: session.XUsername},
+		Game:      game,
+		Winner:    session.Winner,
+		EndReason: session.EndReason,
+	}
+	if session.OUserID != "" {
+		state.O = &PvPPlayer{UserID: session.OUserID, Username: session.OUsername}
+	}
+	if session.Status == "active" && session.TurnStartedAt != nil {
+		deadline := session.TurnStartedAt.Add(TurnTimeout)
+		state.TurnDeadline = &deadline
+	}
+	return state
+}
+
+func opponentOf(symbol string) string {
+	if symbol == PlayerHuman {
+		return PlayerAI
+	}
+	return PlayerHuman
+}
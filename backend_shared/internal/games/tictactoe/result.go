@@ -0,0 +1,91 @@
+package tictactoe
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+)
+
+// ResultDetails are the fields of a Tic-Tac-Toe result. Difficulty is
+// "pvp" for matches against another player.
+type ResultDetails struct {
+	Difficulty string `json:"difficulty"`
+	Result     string `json:"result"`
+	Moves      int    `json:"moves"`
+}
+
+const difficultyPvP = "pvp"
+
+func (s *Service) ID() string   { return "tictactoe" }
+func (s *Service) Name() string { return "Tic-Tac-Toe" }
+
+func (s *Service) Schema() []games.Field {
+	return []games.Field{
+		{Name: "difficulty", Description: "AI difficulty, or pvp against another player"},
+		{Name: "result", Description: "win, loss or draw"},
+		{Name: "moves", Description: "Moves played by both sides"},
+	}
+}
+
+// Ranking counts wins against other players, which are the only results
+// that score. The AI can be beaten by force, even on hard, so wins against
+// it would rank whoever learned the line.
+func (s *Service) Ranking() domain.Ranking {
+	return domain.Ranking{Order: domain.HigherFirst, Aggregate: domain.AggregateTotal}
+}
+
+// Variant is the ruleset; each has its own board.
+func (s *Service) Variant(raw string) (string, error) {
+	rules, err := ParseRules(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", games.ErrInvalidVariant, err)
+	}
+	return rules.ID(), nil
+}
+
+func (s *Service) Validate(result *domain.GameResult) error {
+	var d ResultDetails
+	if err := json.Unmarshal(result.Details, &d); err != nil {
+		return err
+	}
+	if _, err := ParseDifficulty(d.Difficulty); err != nil && d.Difficulty != difficultyPvP {
+		return err
+	}
+	switch d.Result {
+	case "win", "loss", "draw":
+	default:
+		return fmt.Errorf("unknown result %q", d.Result)
+	}
+	if d.Moves < 0 {
+		return fmt.Errorf("negative move count %d", d.Moves)
+	}
+	if _, err := ParseRules(result.Variant); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newResult describes one player's side of a finished match. Only matches
+// against other players are ranked, and a win there scores 1.
+func (s *Service) newResult(userID, opponentID, sessionID, ruleset string, d ResultDetails) (*domain.GameResult, error) {
+	details, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	pvp := d.Difficulty == difficultyPvP
+	result := &domain.GameResult{
+		Game:       s.ID(),
+		Variant:    ruleset,
+		UserID:     userID,
+		SessionID:  sessionID,
+		OpponentID: opponentID,
+		Ranked:     pvp,
+		Details:    details,
+	}
+	if pvp && d.Result == "win" {
+		result.Score = 1
+	}
+	return result, games.Check(s, result)
+}
@@ -0,0 +1,28 @@
+package tictactoe
+
+import "testing"
+
+func TestResultRanking(t *testing.T) {
+	tests := []struct {
+		difficulty, result string
+		ranked             bool
+		score              int
+	}{
+		{"pvp", "win", true, 1},
+		{"pvp", "loss", true, 0},
+		{"pvp", "draw", true, 0},
+		{"hard", "win", false, 0},
+		{"medium", "win", false, 0},
+		{"easy", "loss", false, 0},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		r, err := s.newResult("u1", "", "m1", Classic.ID(), ResultDetails{Difficulty: tt.difficulty, Result: tt.result, Moves: 9})
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.difficulty, tt.result, err)
+		}
+		if r.Ranked != tt.ranked || r.Score != tt.score {
+			t.Errorf("%s %s: ranked %v, score %d; want %v, %d", tt.difficulty, tt.result, r.Ranked, r.Score, tt.ranked, tt.score)
+		}
+	}
+}
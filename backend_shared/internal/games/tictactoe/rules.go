@@ -0,0 +1,119 @@
+package tictactoe
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrInvalidRules = errors.New("invalid ruleset")
+
+// Rules describes a board: its size, how many in a row win, and how many
+// pieces each player may have before their oldest one vanishes.
+type Rules struct {
+	Rows      int `json:"rows"`
+	Cols      int `json:"cols"`
+	WinLength int `json:"win_length"`
+	MaxPieces int `json:"max_pieces"`
+}
+
+// Classic is the original 3x3 game with three vanishing pieces each. It is
+// the only ruleset small enough to be solved outright.
+var Classic = Rules{Rows: 3, Cols: 3, WinLength: 3, MaxPieces: 3}
+
+// Limits on custom rulesets, chosen so the search stays responsive.
+const (
+	MinBoardSide = 3
+	MaxBoardSide = 7
+	MaxPiecesCap = 8
+)
+
+// ID is the canonical name stored with matches, e.g. "4x4-k3-p4".
+func (r Rules) ID() string {
+	return fmt.Sprintf("%dx%d-k%d-p%d", r.Rows, r.Cols, r.WinLength, r.MaxPieces)
+}
+
+func (r Rules) Cells() int {
+	return r.Rows * r.Cols
+}
+
+// ParseRules reads a ruleset ID. An empty string or "classic" means Classic.
+func ParseRules(id string) (Rules, error) {
+	if id == "" || id == "classic" {
+		return Classic, nil
+	}
+	var r Rules
+	if _, err := fmt.Sscanf(id, "%dx%d-k%d-p%d", &r.Rows, &r.Cols, &r.WinLength, &r.MaxPieces); err != nil || r.ID() != id {
+		return Rules{}, fmt.Errorf("%w: %q is not of the form <rows>x<cols>-k<win length>-p<max pieces>", ErrInvalidRules, id)
+	}
+	if err := r.Validate(); err != nil {
+		return Rules{}, err
+	}
+	return r, nil
+}
+
+func (r Rules) Validate() error {
+	switch {
+	case r.Rows < MinBoardSide || r.Rows > MaxBoardSide || r.Cols < MinBoardSide || r.Cols > MaxBoardSide:
+		return fmt.Errorf("%w: board sides must be between %d and %d", ErrInvalidRules, MinBoardSide, MaxBoardSide)
+	case r.WinLength < 3 || (r.WinLength > r.Rows && r.WinLength > r.Cols):
+		return fmt.Errorf("%w: win length must be at least 3 and fit on the board", ErrInvalidRules)
+	case r.MaxPieces < r.WinLength || r.MaxPieces > MaxPiecesCap:
+		return fmt.Errorf("%w: max pieces must be between the win length and %d", ErrInvalidRules, MaxPiecesCap)
+	case 2*r.MaxPieces >= r.Cells():
+		return fmt.Errorf("%w: the board must keep a free cell when both players are capped", ErrInvalidRules)
+	}
+	return nil
+}
+
+var lineCache sync.Map // Rules -> [][]int
+
+// Lines returns every run of WinLength cells in a row, column or diagonal.
+func (r Rules) Lines() [][]int {
+	if cached, ok := lineCache.Load(r); ok {
+		return cached.([][]int)
+	}
+
+	var lines [][]int
+	dirs := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for row := 0; row < r.Rows; row++ {
+		for col := 0; col < r.Cols; col++ {
+			for _, d := range dirs {
+				endRow := row + d[0]*(r.WinLength-1)
+				endCol := col + d[1]*(r.WinLength-1)
+				if endRow < 0 || endRow >= r.Rows || endCol < 0 || endCol >= r.Cols {
+					continue
+				}
+				line := make([]int, r.WinLength)
+				for k := range line {
+					line[k] = (row+d[0]*k)*r.Cols + col + d[1]*k
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	lineCache.Store(r, lines)
+	return lines
+}
+
+// Winner returns the player holding a complete line, or "" if none.
+func (r Rules) Winner(board []string) string {
+	for _, line := range r.Lines() {
+		first := board[line[0]]
+		if first == Empty {
+			continue
+		}
+		won := true
+		for _, i := range line[1:] {
+			if board[i] != first {
+				won = false
+				break
+			}
+		}
+		if won {
+			return first
+		}
+	}
+	return ""
+}
@@ -1,43 +1,222 @@
 package tictactoe
 
 import (
-	"github.com/google/uuid"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
 	"github.com/ramanasai/local-game-play/internal/repos"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	ErrInvalidDifficulty = errors.New("difficulty must be easy, medium or hard")
+	ErrMatchNotFound     = errors.New("match not found")
+	ErrMatchClosed       = errors.New("match already finished")
+	ErrMatchBusy         = errors.New("match was updated by another request")
+	ErrMatchInProgress   = errors.New("match is still in progress")
+)
+
 type Service struct {
 	matchRepo *repos.MatchRepo
+	events    *games.Events
+}
+
+func NewService(matchRepo *repos.MatchRepo, events *games.Events) *Service {
+	return &Service{matchRepo: matchRepo, events: events}
+}
+
+// MatchState is returned after every request on a match. AIMove is the
+// cell the AI answered with, if it moved.
+type MatchState struct {
+	MatchID    string `json:"match_id"`
+	Difficulty string `json:"difficulty"`
+	Ruleset    string `json:"ruleset"`
+	Status     string `json:"status"`
+	Game       *Game  `json:"game"`
+	AIMove     *int   `json:"ai_move,omitempty"`
+	Result     string `json:"result,omitempty"`
+}
+
+func (s *Service) CreateMatch(userID, difficulty, ruleset string) (*MatchState, error) {
+	if _, err := ParseDifficulty(difficulty); err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(ruleset)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.matchRepo.CreateSession(userID, difficulty, rules.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("match_id", session.ID).Str("user_id", userID).Str("difficulty", difficulty).Str("ruleset", session.Ruleset).Msg("TicTacToe Service: Match created")
+	return &MatchState{MatchID: session.ID, Difficulty: difficulty, Ruleset: session.Ruleset, Status: session.Status, Game: NewGame(rules)}, nil
+}
+
+func (s *Service) GetMatch(userID, matchID string) (*MatchState, error) {
+	session, game, err := s.load(userID, matchID)
+	if err != nil {
+		return nil, err
+	}
+	return s.state(session, game), nil
+}
+
+// PlayMove applies the human's move, lets the AI answer if the match is
+// still going, and records the result once either ends it.
+func (s *Service) PlayMove(userID, matchID string, index int) (*MatchState, error) {
+	session, game, err := s.load(userID, matchID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "active" {
+		return nil, ErrMatchClosed
+	}
+
+	if err := game.Play(index); err != nil {
+		return nil, err
+	}
+
+	var aiMove *int
+	if !game.Over {
+		move := ChooseMove(game.Rules, game.Board, game.XQueue, game.OQueue, Difficulty(session.Difficulty))
+		if err := game.Play(move); err != nil {
+			log.Error().Err(err).Str("match_id", matchID).Int("move", move).Msg("TicTacToe Service: AI produced an illegal move")
+			return nil, err
+		}
+		aiMove = &move
+	}
+
+	prevCount := session.MoveCount
+	encoded, err := json.Marshal(game.Moves)
+	if err != nil {
+		return nil, err
+	}
+	session.Moves = string(encoded)
+	session.MoveCount = len(game.Moves)
+
+	var result *domain.GameResult
+	if game.Over {
+		result, err = s.newResult(userID, "", session.ID, session.Ruleset, ResultDetails{
+			Difficulty: session.Difficulty,
+			Result:     game.Result(),
+			Moves:      len(game.Moves),
+		})
+		if err == nil {
+			log.Info().Str("match_id", matchID).Str("user_id", userID).Str("result", game.Result()).Msg("TicTacToe Service: Saving match")
+			err = s.matchRepo.FinishSession(session, prevCount, result)
+		}
+		session.Status = "finished"
+	} else {
+		err = s.matchRepo.UpdateSessionMoves(session, prevCount)
+	}
+	if err != nil {
+		if errors.Is(err, repos.ErrStaleSession) {
+			return nil, ErrMatchBusy
+		}
+		return nil, err
+	}
+	if result != nil {
+		s.events.Publish(result)
+	}
+
+	state := s.state(session, game)
+	state.AIMove = aiMove
+	return state, nil
 }
 
-func NewService(matchRepo *repos.MatchRepo) *Service {
-	return &Service{matchRepo: matchRepo}
+func (s *Service) load(userID, matchID string) (*domain.TicTacToeSession, *Game, error) {
+	session, err := s.matchRepo.GetSession(matchID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrMatchNotFound
+		}
+		return nil, nil, err
+	}
+	if session.UserID != userID {
+		return nil, nil, ErrMatchNotFound
+	}
+
+	var moves []int
+	if err := json.Unmarshal([]byte(session.Moves), &moves); err != nil {
+		log.Error().Err(err).Str("match_id", matchID).Msg("TicTacToe Service: Corrupt move list")
+		return nil, nil, err
+	}
+	rules, err := ParseRules(session.Ruleset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stored match %s has a bad ruleset: %w", matchID, err)
+	}
+	game, err := ReplayGame(rules, moves)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stored match %s no longer replays: %w", matchID, err)
+	}
+	return session, game, nil
 }
 
-func (s *Service) SaveMatch(userID, difficulty, result string, moves int) error {
-	matchID := uuid.New().String()
-	log.Info().Str("match_id", matchID).Str("user_id", userID).Str("result", result).Msg("TicTacToe Service: Saving match")
+func (s *Service) state(session *domain.TicTacToeSession, game *Game) *MatchState {
+	state := &MatchState{
+		MatchID:    session.ID,
+		Difficulty: session.Difficulty,
+		Ruleset:    session.Ruleset,
+		Status:     session.Status,
+		Game:       game,
+	}
+	if game.Over {
+		state.Result = game.Result()
+	}
+	return state
+}
 
-	match := &domain.Match{
-		ID:         matchID,
-		UserID:     userID,
-		Difficulty: difficulty,
-		Result:     result,
-		Moves:      moves,
+// AnalyzeMatch reviews every move of a finished match. Unfinished matches
+// are refused so the analysis can't be used as a live hint.
+func (s *Service) AnalyzeMatch(userID, matchID string) ([]PlyReview, error) {
+	session, game, err := s.load(userID, matchID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != "finished" {
+		return nil, ErrMatchInProgress
 	}
-	return s.matchRepo.Create(match)
+	return ReviewGame(game.Rules, game.Moves)
 }
 
-func (s *Service) GetStats(userID string) (map[string]domain.StatsSummary, error) {
-	return s.matchRepo.GetStatsByUser(userID)
+// AnalyzePosition rates the moves of a Classic position, which is the only
+// ruleset the solver covers.
+func (s *Service) AnalyzePosition(board []string, xQueue, oQueue []int, turn string) (*PositionAnalysis, error) {
+	if turn == "" {
+		turn = InferTurn(Classic, xQueue, oQueue)
+	}
+	if err := ValidatePosition(Classic, board, xQueue, oQueue, turn); err != nil {
+		return nil, err
+	}
+	return AnalyzePosition(xQueue, oQueue, turn)
 }
 
-func (s *Service) GetLeaderboard(limit int) ([]repos.TTTLeaderboardEntry, error) {
-	return s.matchRepo.GetLeaderboard(limit)
+func (s *Service) GetStats(userID, ruleset string) (map[string]domain.StatsSummary, error) {
+	rules, err := ParseRules(ruleset)
+	if err != nil {
+		return nil, err
+	}
+	return s.matchRepo.GetStatsByUser(userID, rules.ID())
 }
 
-func (s *Service) GetMove(board []string, xQueue, oQueue []int) int {
-	log.Debug().Msg("TicTacToe Service: Calculating move")
-	return GetBestMove(board, xQueue, oQueue)
+func (s *Service) GetMove(board []string, xQueue, oQueue []int, difficulty, ruleset string) (int, error) {
+	d, err := ParseDifficulty(difficulty)
+	if err != nil {
+		return -1, err
+	}
+	rules, err := ParseRules(ruleset)
+	if err != nil {
+		return -1, err
+	}
+	if err := ValidatePosition(rules, board, xQueue, oQueue, PlayerAI); err != nil {
+		return -1, err
+	}
+	log.Debug().Str("difficulty", difficulty).Str("ruleset", rules.ID()).Msg("TicTacToe Service: Calculating move")
+	return ChooseMove(rules, board, xQueue, oQueue, d), nil
 }
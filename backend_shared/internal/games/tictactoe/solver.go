@@ -0,0 +1,307 @@
+package tictactoe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Outcome is a game-theoretic result from the point of view of the player
+// to move.
+type Outcome int8
+
+const (
+	Draw Outcome = 0
+	Win  Outcome = 1
+	Loss Outcome = -1
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Win:
+		return "win"
+	case Loss:
+		return "loss"
+	}
+	return "draw"
+}
+
+func (o Outcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// Evaluation is the solved value of a position. Distance is the number of
+// plies until the result with best play from both sides (the winner hurries,
+// the loser stalls); it is 0 for draws.
+type Evaluation struct {
+	Outcome  Outcome `json:"outcome"`
+	Distance int     `json:"distance"`
+}
+
+// position is a solver state on the Classic board. The queues fully
+// determine the board, since every piece on it is in exactly one of them,
+// oldest first.
+type position struct {
+	x, o   []int
+	toMove string
+}
+
+// key packs a position into 25 bits: one bit for the side to move and four
+// bits for each of the six queue slots, with 0xF marking an empty slot.
+func (p position) key() uint32 {
+	k := uint32(0)
+	if p.toMove == PlayerAI {
+		k = 1
+	}
+	shift := 1
+	for _, q := range [][]int{p.x, p.o} {
+		for i := 0; i < Classic.MaxPieces; i++ {
+			cell := uint32(0xF)
+			if i < len(q) {
+				cell = uint32(q[i])
+			}
+			k |= cell << shift
+			shift += 4
+		}
+	}
+	return k
+}
+
+func (p position) board() []string {
+	board := make([]string, Classic.Cells())
+	for _, i := range p.x {
+		board[i] = PlayerHuman
+	}
+	for _, i := range p.o {
+		board[i] = PlayerAI
+	}
+	return board
+}
+
+// successors returns every position reachable in one move, paired with the
+// cell played. Positions where the previous mover already won have none.
+func (p position) successors() ([]position, []int) {
+	board := p.board()
+	if Classic.Winner(board) != "" {
+		return nil, nil
+	}
+
+	var next []position
+	var cells []int
+	for i := 0; i < len(board); i++ {
+		if board[i] != Empty {
+			continue
+		}
+		n := position{x: p.x, o: p.o}
+		if p.toMove == PlayerHuman {
+			n.x = pushQueue(p.x, i, Classic.MaxPieces)
+			n.toMove = PlayerAI
+		} else {
+			n.o = pushQueue(p.o, i, Classic.MaxPieces)
+			n.toMove = PlayerHuman
+		}
+		next = append(next, n)
+		cells = append(cells, i)
+	}
+	return next, cells
+}
+
+// pushQueue returns a copy of q with cell appended, dropping the oldest
+// entry once the queue holds maxPieces.
+func pushQueue(q []int, cell, maxPieces int) []int {
+	start := 0
+	if len(q) >= maxPieces {
+		start = len(q) - maxPieces + 1
+	}
+	out := make([]int, 0, maxPieces)
+	out = append(out, q[start:]...)
+	return append(out, cell)
+}
+
+// Table holds the solved value of every reachable position.
+type Table struct {
+	index map[uint32]int32
+	evals []Evaluation
+}
+
+var (
+	solveOnce sync.Once
+	solved    *Table
+)
+
+// Solution returns the solved table, building it on first use.
+func Solution() *Table {
+	solveOnce.Do(func() {
+		start := time.Now()
+		solved = solve()
+		log.Info().Int("positions", len(solved.evals)).Dur("took", time.Since(start)).Msg("TicTacToe: Solved disappearing-piece variant")
+	})
+	return solved
+}
+
+// solve enumerates every position reachable from the empty board and labels
+// it by retrograde analysis. A position whose previous mover completed a
+// line is lost in 0. Then, in rounds n = 1, 2, ...: a position is won in n
+// if some move reaches a position lost in n-1, and lost in n if every move
+// reaches a won position and the longest of those wins took n-1. Whatever
+// is unlabelled when a round changes nothing can be held forever: a draw.
+func solve() *Table {
+	s := &Table{index: map[uint32]int32{}}
+
+	var positions []position
+	add := func(p position) int32 {
+		k := p.key()
+		if i, ok := s.index[k]; ok {
+			return i
+		}
+		i := int32(len(positions))
+		s.index[k] = i
+		positions = append(positions, p)
+		return i
+	}
+
+	add(position{toMove: PlayerHuman})
+	var succ [][]int32
+	for i := 0; i < len(positions); i++ {
+		next, _ := positions[i].successors()
+		ids := make([]int32, len(next))
+		for j, n := range next {
+			ids[j] = add(n)
+		}
+		succ = append(succ, ids)
+	}
+
+	s.evals = make([]Evaluation, len(positions))
+	resolved := make([]bool, len(positions))
+	for i, ids := range succ {
+		if len(ids) == 0 {
+			s.evals[i] = Evaluation{Outcome: Loss}
+			resolved[i] = true
+		}
+	}
+
+	for n := 1; ; n++ {
+		changed := false
+		for i, ids := range succ {
+			if resolved[i] {
+				continue
+			}
+			allWon, longest := true, -1
+			for _, j := range ids {
+				if !resolved[j] {
+					allWon = false
+					continue
+				}
+				e := s.evals[j]
+				if e.Outcome == Loss && e.Distance == n-1 {
+					s.evals[i] = Evaluation{Outcome: Win, Distance: n}
+					resolved[i] = true
+					changed = true
+					break
+				}
+				if e.Outcome != Win {
+					allWon = false
+				} else if e.Distance > longest {
+					longest = e.Distance
+				}
+			}
+			if !resolved[i] && allWon && longest == n-1 {
+				s.evals[i] = Evaluation{Outcome: Loss, Distance: n}
+				resolved[i] = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// Anything left unresolved keeps the zero value: a draw.
+	return s
+}
+
+// Lookup returns the solved value of a position for the side to move, or
+// false if the position cannot arise in play from an empty board.
+func (s *Table) Lookup(xQueue, oQueue []int, toMove string) (Evaluation, bool) {
+	if len(xQueue) > Classic.MaxPieces || len(oQueue) > Classic.MaxPieces {
+		return Evaluation{}, false
+	}
+	for _, q := range [][]int{xQueue, oQueue} {
+		for _, c := range q {
+			if c < 0 || c >= Classic.Cells() {
+				return Evaluation{}, false
+			}
+		}
+	}
+	i, ok := s.index[position{x: xQueue, o: oQueue, toMove: toMove}.key()]
+	if !ok {
+		return Evaluation{}, false
+	}
+	return s.evals[i], true
+}
+
+// MoveEvaluation is the value of playing Index, for the player making it.
+type MoveEvaluation struct {
+	Index int `json:"index"`
+	Evaluation
+}
+
+// EvaluateMoves rates every legal move for the side to move. ok is false if
+// the position is not in the table.
+func (s *Table) EvaluateMoves(xQueue, oQueue []int, toMove string) ([]MoveEvaluation, bool) {
+	if _, ok := s.Lookup(xQueue, oQueue, toMove); !ok {
+		return nil, false
+	}
+
+	next, cells := position{x: xQueue, o: oQueue, toMove: toMove}.successors()
+	moves := make([]MoveEvaluation, 0, len(next))
+	for j, n := range next {
+		e := s.evals[s.index[n.key()]]
+		// The successor is valued for the opponent; flip it to the mover
+		// and count the move itself.
+		mine := Evaluation{Outcome: -e.Outcome}
+		if e.Outcome != Draw {
+			mine.Distance = e.Distance + 1
+		}
+		moves = append(moves, MoveEvaluation{Index: cells[j], Evaluation: mine})
+	}
+	return moves, true
+}
+
+// better reports whether a is a strictly better evaluation than b for the
+// player choosing between them: wins beat draws beat losses, faster wins
+// beat slower ones, and slower losses beat faster ones.
+func better(a, b Evaluation) bool {
+	if a.Outcome != b.Outcome {
+		return a.Outcome > b.Outcome
+	}
+	switch a.Outcome {
+	case Win:
+		return a.Distance < b.Distance
+	case Loss:
+		return a.Distance > b.Distance
+	}
+	return false
+}
+
+// bestSolvedMoves returns every optimal move for the side to move.
+func bestSolvedMoves(xQueue, oQueue []int, toMove string) ([]int, bool) {
+	moves, ok := Solution().EvaluateMoves(xQueue, oQueue, toMove)
+	if !ok || len(moves) == 0 {
+		return nil, false
+	}
+	best := moves[0].Evaluation
+	for _, m := range moves[1:] {
+		if better(m.Evaluation, best) {
+			best = m.Evaluation
+		}
+	}
+	var out []int
+	for _, m := range moves {
+		if m.Evaluation == best {
+			out = append(out, m.Index)
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,182 @@
+package tictactoe
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSolutionEmptyBoard(t *testing.T) {
+	got, ok := Solution().Lookup(nil, nil, PlayerHuman)
+	if !ok {
+		t.Fatal("empty board not in the table")
+	}
+	// With pieces vanishing, X forces a win by opening on an edge.
+	if want := (Evaluation{Outcome: Win, Distance: 13}); got != want {
+		t.Errorf("empty board = %+v, want %+v", got, want)
+	}
+
+	moves, _ := Solution().EvaluateMoves(nil, nil, PlayerHuman)
+	for _, m := range moves {
+		want := Evaluation{Outcome: Draw}
+		if m.Index%2 == 1 {
+			want = Evaluation{Outcome: Win, Distance: 13}
+		}
+		if m.Evaluation != want {
+			t.Errorf("opening at %d = %+v, want %+v", m.Index, m.Evaluation, want)
+		}
+	}
+}
+
+func TestSolutionKnownPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		x, o   []int
+		toMove string
+		want   Evaluation
+	}{
+		{
+			name:   "X has completed the top row",
+			x:      []int{0, 1, 2},
+			o:      []int{3, 4},
+			toMove: PlayerAI,
+			want:   Evaluation{Outcome: Loss},
+		},
+		{
+			name:   "X completes the top row",
+			x:      []int{0, 1},
+			o:      []int{3, 4},
+			toMove: PlayerHuman,
+			want:   Evaluation{Outcome: Win, Distance: 1},
+		},
+		{
+			// X's move gives up cell 1, which O takes to finish the top row.
+			name:   "X must vacate the top row",
+			x:      []int{1, 6, 4},
+			o:      []int{8, 2, 0},
+			toMove: PlayerHuman,
+			want:   Evaluation{Outcome: Loss, Distance: 2},
+		},
+		{
+			name:   "centre opening",
+			x:      []int{4},
+			toMove: PlayerAI,
+			want:   Evaluation{Outcome: Draw},
+		},
+		{
+			name:   "corner opening",
+			x:      []int{0},
+			toMove: PlayerAI,
+			want:   Evaluation{Outcome: Draw},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Solution().Lookup(tt.x, tt.o, tt.toMove)
+			if !ok {
+				t.Fatal("position not in the table")
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolutionUnreachable(t *testing.T) {
+	// O can't have moved without X having moved first.
+	if _, ok := Solution().Lookup(nil, []int{4}, PlayerHuman); ok {
+		t.Error("position with only an O piece found in the table")
+	}
+	if _, ok := Solution().Lookup([]int{9}, nil, PlayerAI); ok {
+		t.Error("position with an off-board piece found in the table")
+	}
+}
+
+// TestSolutionConsistent checks every solved value against the values of
+// the moves out of it.
+func TestSolutionConsistent(t *testing.T) {
+	s := Solution()
+	for k, i := range s.index {
+		p := decodeKey(k)
+		got := s.evals[i]
+		moves, _ := s.EvaluateMoves(p.x, p.o, p.toMove)
+		if len(moves) == 0 {
+			if got != (Evaluation{Outcome: Loss}) {
+				t.Fatalf("%+v: finished position valued %+v", p, got)
+			}
+			continue
+		}
+		best := moves[0].Evaluation
+		for _, m := range moves[1:] {
+			if better(m.Evaluation, best) {
+				best = m.Evaluation
+			}
+		}
+		if got != best {
+			t.Fatalf("%+v: valued %+v, best move gives %+v", p, got, best)
+		}
+	}
+}
+
+// TestHardNeverLosesDrawnGame plays every sequence of X moves after a
+// drawn opening against every move the hard AI may choose. The AI must
+// only choose optimal moves and never lose.
+func TestHardNeverLosesDrawnGame(t *testing.T) {
+	seen := map[uint32]bool{}
+	var explore func(p position)
+	explore = func(p position) {
+		if seen[p.key()] {
+			return
+		}
+		seen[p.key()] = true
+
+		board := p.board()
+		if Classic.Winner(board) == PlayerHuman {
+			t.Fatalf("X won: X %v, O %v", p.x, p.o)
+		}
+		next, cells := p.successors()
+		if p.toMove == PlayerAI {
+			best, ok := bestSolvedMoves(p.x, p.o, PlayerAI)
+			if !ok {
+				return
+			}
+			if m := ChooseMove(Classic, board, p.x, p.o, Hard); !slices.Contains(best, m) {
+				t.Fatalf("X %v, O %v: hard chose %d, best are %v", p.x, p.o, m, best)
+			}
+			for j, n := range next {
+				if slices.Contains(best, cells[j]) {
+					explore(n)
+				}
+			}
+			return
+		}
+		for _, n := range next {
+			explore(n)
+		}
+	}
+
+	for _, opening := range []int{0, 2, 4, 6, 8} {
+		explore(position{x: []int{opening}, toMove: PlayerAI})
+	}
+	if len(seen) < 1000 {
+		t.Errorf("only %d positions explored", len(seen))
+	}
+}
+
+// decodeKey is the inverse of position.key.
+func decodeKey(k uint32) position {
+	p := position{toMove: PlayerHuman}
+	if k&1 == 1 {
+		p.toMove = PlayerAI
+	}
+	shift := 1
+	for _, q := range []*[]int{&p.x, &p.o} {
+		for i := 0; i < Classic.MaxPieces; i++ {
+			if cell := int(k >> shift & 0xF); cell != 0xF {
+				*q = append(*q, cell)
+			}
+			shift += 4
+		}
+	}
+	return p
+}
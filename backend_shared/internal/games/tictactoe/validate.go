@@ -0,0 +1,96 @@
+package tictactoe
+
+import (
+	"fmt"
+)
+
+// PositionError describes why a client-supplied position was rejected. Code
+// is stable for clients to switch on; Field points at the offending input.
+type PositionError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *PositionError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return e.Message
+}
+
+func positionError(code, field, format string, args ...any) *PositionError {
+	return &PositionError{Code: code, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidatePosition checks that board and queues describe a position that can
+// occur in play under rules with toMove to play next. It returns a
+// *PositionError naming the first problem found, or nil. Reachability is
+// only checked on the Classic board, where the solved table knows it.
+func ValidatePosition(rules Rules, board []string, xQueue, oQueue []int, toMove string) error {
+	if len(board) != rules.Cells() {
+		return positionError("board_length", "board", "board must have %d cells, got %d", rules.Cells(), len(board))
+	}
+	for i, v := range board {
+		if v != Empty && v != PlayerHuman && v != PlayerAI {
+			return positionError("unknown_symbol", fmt.Sprintf("board[%d]", i), "unknown symbol %q, expected \"X\", \"O\" or \"\"", v)
+		}
+	}
+	if toMove != PlayerHuman && toMove != PlayerAI {
+		return positionError("invalid_turn", "turn", "turn must be \"X\" or \"O\", got %q", toMove)
+	}
+
+	seen := map[int]string{}
+	for _, q := range []struct {
+		name   string
+		player string
+		cells  []int
+	}{{"xQueue", PlayerHuman, xQueue}, {"oQueue", PlayerAI, oQueue}} {
+		if len(q.cells) > rules.MaxPieces {
+			return positionError("too_many_pieces", q.name, "at most %d pieces per side, got %d", rules.MaxPieces, len(q.cells))
+		}
+		for i, c := range q.cells {
+			field := fmt.Sprintf("%s[%d]", q.name, i)
+			if c < 0 || c >= len(board) {
+				return positionError("queue_index_out_of_range", field, "cell %d is outside the board", c)
+			}
+			if other, dup := seen[c]; dup {
+				return positionError("duplicate_queue_index", field, "cell %d is already listed in %s", c, other)
+			}
+			seen[c] = q.name
+			if board[c] != q.player {
+				return positionError("queue_board_mismatch", field, "queue lists cell %d for %s but the board has %q", c, q.player, board[c])
+			}
+		}
+	}
+	for i, v := range board {
+		if v != Empty {
+			if _, listed := seen[i]; !listed {
+				return positionError("queue_board_mismatch", fmt.Sprintf("board[%d]", i), "piece %q is not in its player's queue", v)
+			}
+		}
+	}
+
+	// X moves first, so before anyone is capped X has either as many pieces
+	// as O (X to move) or one more (O to move).
+	nx, no := len(xQueue), len(oQueue)
+	switch {
+	case nx == rules.MaxPieces && no == rules.MaxPieces:
+	case toMove == PlayerHuman && nx == no:
+	case toMove == PlayerAI && nx == no+1:
+	default:
+		return positionError("turn_order", "", "%d X and %d O pieces cannot occur with %s to move", nx, no, toMove)
+	}
+
+	if w := rules.Winner(board); w != "" {
+		return positionError("already_won", "board", "%s has already won", w)
+	}
+
+	if rules != Classic {
+		return nil
+	}
+	if _, ok := Solution().Lookup(xQueue, oQueue, toMove); !ok {
+		return positionError("unreachable", "", "the queues cannot be reached from an empty board in this order")
+	}
+	return nil
+}
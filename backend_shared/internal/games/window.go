@@ -0,0 +1,71 @@
+package games
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/repos"
+)
+
+var (
+	ErrInvalidWindow = errors.New("window must be season, all, month, week or day")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Leaderboard windows. Season is the running season, see SyncSeasons.
+// Month, week and day are calendar periods in UTC, so everyone sees the
+// same board reset at the same moment: the month from the 1st, the week
+// from Monday and the day from midnight.
+const (
+	WindowSeason = "season"
+	WindowAll    = "all"
+	WindowMonth  = "month"
+	WindowWeek   = "week"
+	WindowDay    = "day"
+)
+
+// windowStart returns when a calendar window began as of now; the zero
+// time for WindowAll.
+func windowStart(window string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch window {
+	case WindowAll:
+		return time.Time{}, nil
+	case WindowMonth:
+		return day.AddDate(0, 0, 1-day.Day()), nil
+	case WindowWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), nil
+	case WindowDay:
+		return day, nil
+	}
+	return time.Time{}, fmt.Errorf("%w, not %q", ErrInvalidWindow, window)
+}
+
+// encodeCursor makes a board key opaque to clients. Cursors are only
+// meaningful for the board they came from.
+func encodeCursor(key *repos.BoardKey) string {
+	if key == nil {
+		return ""
+	}
+	b, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) (*repos.BoardKey, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var key repos.BoardKey
+	if err := json.Unmarshal(b, &key); err != nil || key.UserID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &key, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ramanasai/local-game-play/internal/achievements"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type AchievementsHandler struct {
+	service *achievements.Service
+}
+
+func NewAchievementsHandler(service *achievements.Service) *AchievementsHandler {
+	return &AchievementsHandler{service: service}
+}
+
+// ListMine returns every achievement, with when the caller unlocked the
+// ones they have.
+func (h *AchievementsHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	list, err := h.service.ForUser(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to list achievements")
+		http.Error(w, "Failed to list achievements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ramanasai/local-game-play/internal/admin"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+)
+
+type AdminHandler struct {
+	service *admin.Service
+}
+
+func NewAdminHandler(service *admin.Service) *AdminHandler {
+	return &AdminHandler{service: service}
+}
+
+// ListUsers searches users by username. Query params: q, limit, offset.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := queryInt(q.Get("limit"), defaultAdminPageSize, 1, maxAdminPageSize)
+	offset := queryInt(q.Get("offset"), 0, 0, -1)
+
+	page, err := h.service.SearchUsers(q.Get("q"), limit, offset)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.service.GetUser(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+type RenameUserRequest struct {
+	Username string `json:"username"`
+}
+
+func (h *AdminHandler) RenameUser(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	var req RenameUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid RenameUser request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.Rename(adminUser, chi.URLParam(r, "id"), req.Username)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+type PINResetResponse struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ResetPIN issues a one-time code for the admin to pass on to the user,
+// who redeems it at /auth/pin-reset.
+func (h *AdminHandler) ResetPIN(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	code, expiresAt, err := h.service.IssuePINReset(adminUser, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PINResetResponse{Code: code, ExpiresAt: expiresAt.UTC()})
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	var req SuspendUserRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warn().Err(err).Msg("Invalid SuspendUser request body")
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := h.service.Suspend(adminUser, chi.URLParam(r, "id"), req.Reason)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	user, err := h.service.Unsuspend(adminUser, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"` // player, admin
+}
+
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid SetRole request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.SetRole(adminUser, chi.URLParam(r, "id"), req.Role)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+type SetParentRequest struct {
+	ParentID string `json:"parent_id"` // Empty to unlink
+}
+
+func (h *AdminHandler) SetParent(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	var req SetParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid SetParent request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.SetParent(adminUser, chi.URLParam(r, "id"), req.ParentID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	adminUser := r.Context().Value("user").(*domain.User)
+
+	if err := h.service.DeleteUser(adminUser, chi.URLParam(r, "id")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// AuditLog lists recent admin actions. Query params: user_id, limit.
+func (h *AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	entries, err := h.service.AuditLog(q.Get("user_id"), queryInt(q.Get("limit"), defaultAdminPageSize, 1, maxAdminPageSize))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (h *AdminHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, admin.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, repos.ErrUsernameTaken), errors.Is(err, admin.ErrSelf):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, admin.ErrInvalidRole), errors.Is(err, usernames.ErrInvalid), errors.Is(err, admin.ErrInvalidParent):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg("Admin request failed")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+	}
+}
+
+// queryInt parses a query parameter, falling back to def when it is
+// missing or malformed and clamping it to [min, max]. A negative max means
+// no upper bound.
+func queryInt(raw string, def, min, max int) int {
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return def
+	}
+	if n < min {
+		n = min
+	}
+	if max >= 0 && n > max {
+		n = max
+	}
+	return n
+}
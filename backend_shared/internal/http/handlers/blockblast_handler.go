@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/ramanasai/local-game-play/internal/domain"
 	"github.com/ramanasai/local-game-play/internal/games/blockblast"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,43 +20,109 @@ func NewBlockBlastHandler(service *blockblast.Service) *BlockBlastHandler {
 	return &BlockBlastHandler{service: service}
 }
 
-func (h *BlockBlastHandler) SubmitScore(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(string)
+func (h *BlockBlastHandler) StartSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	state, err := h.service.StartSession(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to start BlockBlast session")
+		http.Error(w, "Failed to start game", http.StatusInternalServerError)
+		return
+	}
 
-	var req struct {
-		Score int `json:"score"`
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
+}
+
+// StartDaily starts the caller's one attempt at today's challenge.
+func (h *BlockBlastHandler) StartDaily(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	state, err := h.service.StartDaily(user.ID)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *BlockBlastHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	state, err := h.service.GetState(user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *BlockBlastHandler) Place(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req blockblast.Placement
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Warn().Err(err).Msg("Invalid SubmitBlockBlastScore request body")
+		log.Warn().Err(err).Msg("Invalid BlockBlast placement request body")
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	log.Info().Str("user_id", userID).Int("score", req.Score).Msg("Submitting BlockBlast score")
-	if err := h.service.SubmitScore(userID, req.Score); err != nil {
-		log.Error().Err(err).Msg("Failed to save BlockBlast score")
-		http.Error(w, "Failed to save score", http.StatusInternalServerError)
+	state, err := h.service.Place(user.ID, chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+type SubmitBlockBlastScoreRequest struct {
+	SessionID string `json:"session_id"`
 }
 
-func (h *BlockBlastHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+func (h *BlockBlastHandler) SubmitScore(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req SubmitBlockBlastScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid SubmitBlockBlastScore request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	scores, err := h.service.GetLeaderboard(limit)
+	log.Info().Str("user_id", user.ID).Str("session_id", req.SessionID).Msg("Submitting BlockBlast score")
+	session, err := h.service.SubmitScore(user.ID, req.SessionID)
 	if err != nil {
-		http.Error(w, "Failed to get leaderboard", http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(scores)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *BlockBlastHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, blockblast.ErrSessionNotFound):
+		http.Error(w, "Session not found", http.StatusNotFound)
+	case errors.Is(err, blockblast.ErrSessionClosed):
+		http.Error(w, "Session already finished", http.StatusConflict)
+	case errors.Is(err, daily.ErrPlayed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, blockblast.ErrSessionBusy):
+		http.Error(w, "Session was updated concurrently, reload it", http.StatusConflict)
+	case errors.Is(err, blockblast.ErrIllegalPlacement):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg("BlockBlast request failed")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+	}
 }
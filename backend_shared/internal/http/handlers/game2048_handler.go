@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
 	"github.com/ramanasai/local-game-play/internal/games/game2048"
 	"github.com/rs/zerolog/log"
 )
@@ -18,46 +19,91 @@ func NewGame2048Handler(service *game2048.Service) *Game2048Handler {
 	return &Game2048Handler{service: service}
 }
 
-type Submit2048ScoreRequest struct {
-	Score int `json:"score"`
+type Start2048SessionResponse struct {
+	SessionID string         `json:"session_id"`
+	Seed      uint32         `json:"seed"`
+	DailyDate string         `json:"daily_date,omitempty"`
+	Board     game2048.Board `json:"board"`
 }
 
-func (h *Game2048Handler) SubmitScore(w http.ResponseWriter, r *http.Request) {
+func (h *Game2048Handler) StartSession(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*domain.User)
 
-	var req Submit2048ScoreRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Warn().Err(err).Msg("Invalid Submit2048Score request body")
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	session, game, err := h.service.StartSession(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to start 2048 session")
+		http.Error(w, "Failed to start game", http.StatusInternalServerError)
 		return
 	}
 
-	log.Info().Str("user_id", user.ID).Int("score", req.Score).Msg("Submitting 2048 game score")
-	if err := h.service.SubmitScore(user.ID, req.Score); err != nil {
-		log.Error().Err(err).Msg("Failed to submit 2048 score")
-		http.Error(w, "Failed to submit score", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Start2048SessionResponse{
+		SessionID: session.ID,
+		Seed:      session.Seed,
+		Board:     game.Board,
+	})
+}
+
+// StartDaily starts the caller's one attempt at today's challenge. The seed
+// is the day's, so clients spawn the same tiles for everyone.
+func (h *Game2048Handler) StartDaily(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	session, game, err := h.service.StartDaily(user.ID)
+	if err != nil {
+		if errors.Is(err, daily.ErrPlayed) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to start 2048 daily challenge")
+		http.Error(w, "Failed to start game", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Start2048SessionResponse{
+		SessionID: session.ID,
+		Seed:      session.Seed,
+		DailyDate: session.DailyDate,
+		Board:     game.Board,
+	})
 }
 
-func (h *Game2048Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+type Submit2048ScoreRequest struct {
+	SessionID string   `json:"session_id"`
+	Moves     []string `json:"moves"` // up, down, left, right
+}
+
+func (h *Game2048Handler) SubmitScore(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req Submit2048ScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid Submit2048Score request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	scores, err := h.service.GetLeaderboard(limit)
+	log.Info().Str("user_id", user.ID).Str("session_id", req.SessionID).Int("moves", len(req.Moves)).Msg("Submitting 2048 game moves")
+	session, err := h.service.SubmitScore(user.ID, req.SessionID, req.Moves)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get 2048 leaderboard")
-		http.Error(w, "Failed to get leaderboard", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, game2048.ErrSessionNotFound):
+			http.Error(w, "Session not found", http.StatusNotFound)
+		case errors.Is(err, game2048.ErrSessionClosed):
+			http.Error(w, "Session already finished", http.StatusConflict)
+		case errors.Is(err, game2048.ErrInvalidMoves):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Error().Err(err).Msg("Failed to submit 2048 score")
+			http.Error(w, "Failed to submit score", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(scores)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
 }
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games"
+	"github.com/rs/zerolog/log"
+)
+
+// GamesHandler serves the endpoints every registered game shares.
+type GamesHandler struct {
+	service *games.Service
+}
+
+func NewGamesHandler(service *games.Service) *GamesHandler {
+	return &GamesHandler{service: service}
+}
+
+type GameInfo struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Fields  []games.Field  `json:"fields"`
+	Ranking domain.Ranking `json:"ranking"`
+}
+
+func (h *GamesHandler) ListGames(w http.ResponseWriter, r *http.Request) {
+	list := []GameInfo{}
+	for _, g := range h.service.Games() {
+		list = append(list, GameInfo{ID: g.ID(), Name: g.Name(), Fields: g.Schema(), Ranking: g.Ranking()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *GamesHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	game := chi.URLParam(r, "game")
+	query := r.URL.Query()
+	limit := queryInt(query.Get("limit"), games.DefaultLimit, 1, games.MaxLimit)
+
+	page, err := h.service.Leaderboard(game, query.Get("variant"), query.Get("window"), query.Get("cursor"), limit)
+	if err != nil {
+		h.writeError(w, err, "Failed to get leaderboard")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetMyRank shows where the caller stands on one game's leaderboard, with
+// the players just above and below them.
+func (h *GamesHandler) GetMyRank(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+	query := r.URL.Query()
+	game := query.Get("game")
+	if game == "" {
+		http.Error(w, "game is required", http.StatusBadRequest)
+		return
+	}
+	neighbors := queryInt(query.Get("neighbors"), 2, 0, games.MaxNeighbors)
+
+	rank, err := h.service.PlayerRank(game, query.Get("variant"), query.Get("window"), user.ID, neighbors)
+	if err != nil {
+		h.writeError(w, err, "Failed to get rank")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rank)
+}
+
+// ListScores returns the caller's own recent results for a game, including
+// unranked ones.
+func (h *GamesHandler) ListScores(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+	game := chi.URLParam(r, "game")
+	limit := queryInt(r.URL.Query().Get("limit"), games.DefaultLimit, 1, games.MaxLimit)
+
+	results, err := h.service.UserResults(game, user.ID, limit)
+	if err != nil {
+		h.writeError(w, err, "Failed to get scores")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// GetDailyLeaderboard returns a day's challenge board, today's unless date
+// (YYYY-MM-DD) is given.
+func (h *GamesHandler) GetDailyLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := queryInt(query.Get("limit"), games.DefaultLimit, 1, games.MaxLimit)
+
+	board, err := h.service.DailyLeaderboard(chi.URLParam(r, "game"), query.Get("date"), query.Get("cursor"), limit)
+	if err != nil {
+		h.writeError(w, err, "Failed to get daily leaderboard")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
+
+// GetMyDaily shows the caller's progress on today's challenges and their
+// streaks.
+func (h *GamesHandler) GetMyDaily(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	summary, err := h.service.DailySummary(user.ID)
+	if err != nil {
+		h.writeError(w, err, "Failed to get daily challenges")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+func (h *GamesHandler) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	seasons, err := h.service.Seasons()
+	if err != nil {
+		h.writeError(w, err, "Failed to list seasons")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(seasons)
+}
+
+// GetSeasonStandings returns the archived final boards of an ended season.
+func (h *GamesHandler) GetSeasonStandings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := queryInt(query.Get("limit"), games.DefaultLimit, 1, games.MaxLimit)
+
+	standings, err := h.service.SeasonStandings(chi.URLParam(r, "id"), query.Get("game"), limit)
+	if err != nil {
+		h.writeError(w, err, "Failed to get season standings")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(standings)
+}
+
+func (h *GamesHandler) writeError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, games.ErrUnknownGame), errors.Is(err, games.ErrNotRanked),
+		errors.Is(err, games.ErrSeasonNotFound), errors.Is(err, games.ErrNoActiveSeason):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, games.ErrSeasonNotArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, games.ErrInvalidVariant), errors.Is(err, games.ErrInvalidWindow), errors.Is(err, games.ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/daily"
 	"github.com/ramanasai/local-game-play/internal/games/memory"
 	"github.com/rs/zerolog/log"
 )
@@ -18,48 +20,101 @@ func NewMemoryHandler(service *memory.Service) *MemoryHandler {
 	return &MemoryHandler{service: service}
 }
 
-type SubmitScoreRequest struct {
-	Moves       int `json:"moves"`
-	TimeSeconds int `json:"time_seconds"`
+type StartMemorySessionRequest struct {
+	Pairs int `json:"pairs"`
 }
 
-func (h *MemoryHandler) SubmitScore(w http.ResponseWriter, r *http.Request) {
+func (h *MemoryHandler) StartSession(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*domain.User)
 
-	var req SubmitScoreRequest
+	var req StartMemorySessionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Warn().Err(err).Msg("Invalid SubmitScore request body")
+		log.Warn().Err(err).Msg("Invalid StartMemorySession request body")
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	log.Info().Str("user_id", user.ID).Int("moves", req.Moves).Int("time", req.TimeSeconds).Msg("Submitting Memory game score")
-	if err := h.service.SubmitScore(user.ID, req.Moves, req.TimeSeconds); err != nil {
-		log.Error().Err(err).Msg("Failed to submit memory score")
-		http.Error(w, "Failed to submit score", http.StatusInternalServerError)
+	state, err := h.service.StartSession(user.ID, req.Pairs)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
+}
+
+// StartDaily starts the caller's one attempt at today's challenge, a board
+// of memory.DailyPairs laid out the same for everyone.
+func (h *MemoryHandler) StartDaily(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	state, err := h.service.StartDaily(user.ID)
+	if err != nil {
+		h.writeError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *MemoryHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	state, err := h.service.GetState(user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+type FlipRequest struct {
+	Index int `json:"index"`
 }
 
-func (h *MemoryHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
+func (h *MemoryHandler) Flip(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req FlipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid Flip request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	// log.Debug().Int("limit", limit).Msg("Fetching Memory leaderboard") // Optional
-	scores, err := h.service.GetLeaderboard(limit)
+	resp, err := h.service.Flip(user.ID, chi.URLParam(r, "id"), req.Index)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get memory leaderboard")
-		http.Error(w, "Failed to get leaderboard", http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
+	if resp.Completed {
+		log.Info().Str("user_id", user.ID).Int("moves", resp.Score.Moves).Int("time", resp.Score.TimeSeconds).Msg("Memory game completed")
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(scores)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *MemoryHandler) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, memory.ErrSessionNotFound):
+		http.Error(w, "Session not found", http.StatusNotFound)
+	case errors.Is(err, memory.ErrSessionClosed):
+		http.Error(w, "Session already finished", http.StatusConflict)
+	case errors.Is(err, daily.ErrPlayed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, memory.ErrSessionBusy):
+		http.Error(w, "Session was updated concurrently, reload it", http.StatusConflict)
+	case errors.Is(err, memory.ErrInvalidPairs), errors.Is(err, memory.ErrIllegalFlip):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg("Memory request failed")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+	}
 }
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/ramanasai/local-game-play/internal/domain"
 	"github.com/ramanasai/local-game-play/internal/games/tictactoe"
 	"github.com/rs/zerolog/log"
@@ -18,9 +20,12 @@ func NewTicTacToeHandler(service *tictactoe.Service) *TicTacToeHandler {
 }
 
 type GetMoveRequest struct {
-	Board  []string `json:"board"`
-	XQueue []int    `json:"xQueue"` // Indices of X's moves
-	OQueue []int    `json:"oQueue"` // Indices of O's moves
+	Board      []string `json:"board"`
+	XQueue     []int    `json:"xQueue"`               // Indices of X's moves
+	OQueue     []int    `json:"oQueue"`               // Indices of O's moves
+	Difficulty string   `json:"difficulty,omitempty"` // easy, medium, hard (default)
+	Ruleset    string   `json:"ruleset,omitempty"`    // e.g. 4x4-k3-p4, classic if empty
+	Turn       string   `json:"turn,omitempty"`       // Analysis only: X or O, inferred if empty
 }
 
 type GetMoveResponse struct {
@@ -35,62 +40,196 @@ func (h *TicTacToeHandler) GetMove(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	index := h.service.GetMove(req.Board, req.XQueue, req.OQueue)
+	if req.Difficulty == "" {
+		req.Difficulty = string(tictactoe.Hard)
+	}
+
+	index, err := h.service.GetMove(req.Board, req.XQueue, req.OQueue, req.Difficulty, req.Ruleset)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
 	// log.Debug().Int("move_index", index).Msg("Calculated Minimax move")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(GetMoveResponse{Index: index})
 }
 
-type SaveMatchRequest struct {
+func (h *TicTacToeHandler) AnalyzePosition(w http.ResponseWriter, r *http.Request) {
+	var req GetMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid AnalyzePosition request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	analysis, err := h.service.AnalyzePosition(req.Board, req.XQueue, req.OQueue, req.Turn)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
+}
+
+type CreateMatchRequest struct {
 	Difficulty string `json:"difficulty"`
-	Result     string `json:"result"`
-	Moves      int    `json:"moves"`
+	Ruleset    string `json:"ruleset,omitempty"` // classic if empty
 }
 
-func (h *TicTacToeHandler) SaveMatch(w http.ResponseWriter, r *http.Request) {
+func (h *TicTacToeHandler) CreateMatch(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*domain.User)
 
-	var req SaveMatchRequest
+	var req CreateMatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Warn().Err(err).Msg("Invalid SaveMatch request body")
+		log.Warn().Err(err).Msg("Invalid CreateMatch request body")
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	log.Info().Str("user_id", user.ID).Str("result", req.Result).Msg("Saving TicTacToe match")
-	if err := h.service.SaveMatch(user.ID, req.Difficulty, req.Result, req.Moves); err != nil {
-		log.Error().Err(err).Msg("Failed to save match")
-		http.Error(w, "Failed to save match", http.StatusInternalServerError)
+	state, err := h.service.CreateMatch(user.ID, req.Difficulty, req.Ruleset)
+	if err != nil {
+		h.writeError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
 }
 
-func (h *TicTacToeHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+func (h *TicTacToeHandler) GetMatch(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value("user").(*domain.User)
 
-	stats, err := h.service.GetStats(user.ID)
+	state, err := h.service.GetMatch(user.ID, chi.URLParam(r, "id"))
 	if err != nil {
-		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to get TicTacToe stats")
-		http.Error(w, "Failed to get stats", http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *TicTacToeHandler) AnalyzeMatch(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	reviews, err := h.service.AnalyzeMatch(user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reviews)
+}
+
+type PlayMoveRequest struct {
+	Index int `json:"index"`
+}
+
+func (h *TicTacToeHandler) PlayMove(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req PlayMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid PlayMove request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.service.PlayMove(user.ID, chi.URLParam(r, "id"), req.Index)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+type CreatePvPMatchRequest struct {
+	Ruleset string `json:"ruleset,omitempty"` // classic if empty
 }
 
-func (h *TicTacToeHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	limit := 10 // Default limit, could be parsed from query param
-	leaderboard, err := h.service.GetLeaderboard(limit)
+// CreatePvPMatch opens a match against another player, with the caller as
+// X. The opponent joins by connecting to the match's WebSocket.
+func (h *TicTacToeHandler) CreatePvPMatch(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req CreatePvPMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid CreatePvPMatch request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.service.CreatePvPMatch(user.ID, req.Ruleset)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get TicTacToe leaderboard")
-		http.Error(w, "Failed to get leaderboard", http.StatusInternalServerError)
+		h.writeError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(leaderboard)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *TicTacToeHandler) GetPvPMatch(w http.ResponseWriter, r *http.Request) {
+	state, err := h.service.GetPvPMatch(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *TicTacToeHandler) writeError(w http.ResponseWriter, err error) {
+	var posErr *tictactoe.PositionError
+	if errors.As(err, &posErr) {
+		log.Warn().Str("code", posErr.Code).Str("field", posErr.Field).Msg("Rejected TicTacToe position")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]*tictactoe.PositionError{"error": posErr})
+		return
+	}
+
+	switch {
+	case errors.Is(err, tictactoe.ErrMatchNotFound):
+		http.Error(w, "Match not found", http.StatusNotFound)
+	case errors.Is(err, tictactoe.ErrMatchClosed):
+		http.Error(w, "Match already finished", http.StatusConflict)
+	case errors.Is(err, tictactoe.ErrMatchInProgress):
+		http.Error(w, "Match is still in progress", http.StatusConflict)
+	case errors.Is(err, tictactoe.ErrUnknownPosition), errors.Is(err, tictactoe.ErrNotSolved):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, tictactoe.ErrMatchBusy):
+		http.Error(w, "Match was updated concurrently, reload it", http.StatusConflict)
+	case errors.Is(err, tictactoe.ErrInvalidDifficulty), errors.Is(err, tictactoe.ErrInvalidRules), errors.Is(err, tictactoe.ErrIllegalMove):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg("TicTacToe request failed")
+		http.Error(w, "Failed to process request", http.StatusInternalServerError)
+	}
+}
+
+func (h *TicTacToeHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	stats, err := h.service.GetStats(user.ID, r.URL.Query().Get("ruleset"))
+	if err != nil {
+		if errors.Is(err, tictactoe.ErrInvalidRules) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to get TicTacToe stats")
+		http.Error(w, "Failed to get stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
 }
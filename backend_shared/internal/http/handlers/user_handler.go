@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/ramanasai/local-game-play/internal/auth"
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/repos"
+	"github.com/ramanasai/local-game-play/internal/usernames"
 	"github.com/rs/zerolog/log"
 )
 
@@ -24,9 +29,11 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	User   *domain.User `json:"user"`
-	Token  string       `json:"token,omitempty"`
-	Status string       `json:"status"` // OK, PIN_REQUIRED, SET_PIN_REQUIRED
+	User         *domain.User `json:"user"`
+	Token        string       `json:"token,omitempty"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int          `json:"expires_in,omitempty"` // Access token lifetime in seconds
+	Status       string       `json:"status"`               // OK, PIN_REQUIRED, SET_PIN_REQUIRED
 }
 
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -44,24 +51,233 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info().Str("username", req.Username).Msg("Processing login request")
-	resp, err := h.authService.Login(req.Username, req.Pin, req.Hint)
+	resp, err := h.authService.Login(req.Username, req.Pin, req.Hint, auth.ClientFromRequest(r))
 	if err != nil {
 		log.Warn().Err(err).Str("username", req.Username).Msg("Login failed")
+		var locked *auth.LockedOutError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, auth.ErrAccountSuspended) || errors.Is(err, auth.ErrGuestAccount) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, usernames.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repos.ErrUsernameTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	out := LoginResponse{
+		User:         resp.User,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		Status:       resp.Status,
+	}
+	if resp.Token != "" {
+		out.ExpiresIn = int(auth.AccessTokenTTL.Seconds())
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Refresh exchanges a refresh token for a new token pair. The old refresh
+// token stops working.
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid refresh request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(req.RefreshToken, auth.ClientFromRequest(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, auth.ErrRefreshTokenReused), errors.Is(err, auth.ErrSessionRevoked):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error().Err(err).Msg("Token refresh failed")
+			http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RefreshResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+type RedeemResetCodeRequest struct {
+	Username string `json:"username"`
+	Code     string `json:"code"`
+	Pin      string `json:"pin"`
+}
+
+// RedeemResetCode sets a new PIN using a one-time code from an admin or a
+// parent account. It logs the user out everywhere else and answers like a
+// successful login.
+func (h *UserHandler) RedeemResetCode(w http.ResponseWriter, r *http.Request) {
+	var req RedeemResetCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid RedeemResetCode request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || req.Code == "" {
+		http.Error(w, "Username and code required", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.authService.RedeemResetCode(req.Username, req.Code, req.Pin, auth.ClientFromRequest(r))
+	if err != nil {
+		var locked *auth.LockedOutError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, auth.ErrPINTooShort):
+			http.Error(w, "PIN must be at least 4 digits", http.StatusBadRequest)
+		case errors.Is(err, auth.ErrInvalidResetCode):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error().Err(err).Str("username", req.Username).Msg("PIN reset failed")
+			http.Error(w, "Failed to reset PIN", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LoginResponse{
+		User:         resp.User,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		Status:       resp.Status,
+	})
+}
+
+// StartGuest signs in as a new guest account, so people can play without
+// picking a name first. Guests stay off the leaderboards until claimed.
+func (h *UserHandler) StartGuest(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.authService.StartGuest(auth.ClientFromRequest(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start guest session")
+		http.Error(w, "Failed to start guest session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(LoginResponse{
-		User:   resp.User,
-		Token:  resp.Token,
-		Status: resp.Status,
+		User:         resp.User,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		Status:       resp.Status,
 	})
 }
 
+type ClaimGuestRequest struct {
+	Username string `json:"username"`
+	Pin      string `json:"pin"`            // New PIN, or the existing account's PIN
+	Hint     string `json:"hint,omitempty"` // Only used for a new account
+}
+
+// ClaimGuest keeps the caller's guest history under a real name: a new
+// account if the username is free, otherwise merged into the existing
+// account whose PIN is given. The guest's tokens stop working; the response
+// carries tokens for the resulting account.
+func (h *UserHandler) ClaimGuest(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req ClaimGuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid ClaimGuest request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, "Username required", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.authService.ClaimGuest(user, req.Username, req.Pin, req.Hint, auth.ClientFromRequest(r))
+	if err != nil {
+		var locked *auth.LockedOutError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, auth.ErrPINTooShort):
+			http.Error(w, "PIN must be at least 4 digits", http.StatusBadRequest)
+		case errors.Is(err, usernames.ErrInvalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, auth.ErrInvalidPIN):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrNotGuest), errors.Is(err, auth.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, repos.ErrUsernameTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to claim guest account")
+			http.Error(w, "Failed to claim guest account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LoginResponse{
+		User:         resp.User,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		Status:       resp.Status,
+	})
+}
+
+// Logout revokes the session the request was authenticated with.
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Context().Value("session_id").(string)
+
+	if err := h.authService.Logout(sessionID); err != nil {
+		log.Error().Err(err).Str("session_id", sessionID).Msg("Logout failed")
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "logged_out"})
+}
+
 type UpdatePinRequest struct {
-	Pin  string `json:"pin"`
-	Hint string `json:"hint"`
+	Pin  string  `json:"pin"`
+	Hint *string `json:"hint,omitempty"` // Optional; left unchanged if omitted, cleared if empty
 }
 
 func (h *UserHandler) UpdatePIN(w http.ResponseWriter, r *http.Request) {
@@ -74,6 +290,11 @@ func (h *UserHandler) UpdatePIN(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.Guest {
+		http.Error(w, auth.ErrGuestAccount.Error(), http.StatusForbidden)
+		return
+	}
+
 	if len(req.Pin) < 4 {
 		log.Warn().Str("user_id", user.ID).Msg("UpdatePIN rejected: PIN too short")
 		http.Error(w, "PIN must be at least 4 digits", http.StatusBadRequest)
@@ -81,7 +302,11 @@ func (h *UserHandler) UpdatePIN(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Info().Str("user_id", user.ID).Msg("Processing PIN update request")
-	if err := h.authService.UpdatePIN(user.ID, req.Pin, req.Hint); err != nil {
+	hint := user.Hint
+	if req.Hint != nil {
+		hint = *req.Hint
+	}
+	if err := h.authService.UpdatePIN(user.ID, req.Pin, hint); err != nil {
 		log.Error().Err(err).Str("user_id", user.ID).Msg("UpdatePIN failed")
 		http.Error(w, "Failed to update PIN: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -97,3 +322,213 @@ func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
+
+// Export sends the caller everything stored about them as a JSON download.
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	export, err := h.authService.ExportAccount(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to export account")
+		http.Error(w, "Failed to export account", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="local-game-play-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+type DeleteAccountRequest struct {
+	Pin string `json:"pin"`
+}
+
+// DeleteAccount permanently removes the caller's account and data. Guests
+// may send no body.
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warn().Err(err).Msg("Invalid DeleteAccount request body")
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.authService.DeleteAccount(user, req.Pin, auth.ClientFromRequest(r)); err != nil {
+		var locked *auth.LockedOutError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, auth.ErrInvalidPIN):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to delete account")
+			http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// SessionView is a session as shown to its owner. Current marks the one
+// the request came from.
+type SessionView struct {
+	*domain.Session
+	Current bool `json:"current"`
+}
+
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+	currentID := r.Context().Value("session_id").(string)
+
+	sessions, err := h.authService.ListSessions(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to list sessions")
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		views = append(views, SessionView{Session: s, Current: s.ID == currentID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// RevokeSession signs out one of the user's devices. Revoking the current
+// session works like logging out.
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	if err := h.authService.RevokeSession(user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to revoke session")
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// RevokeOtherSessions signs out every device except the one making the
+// request.
+func (h *UserHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+	currentID := r.Context().Value("session_id").(string)
+
+	n, err := h.authService.RevokeOtherSessions(user.ID, currentID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to revoke other sessions")
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"revoked": n})
+}
+
+type LinkParentRequest struct {
+	Username string `json:"username"` // The parent's
+	Pin      string `json:"pin"`      // The caller's own PIN, to confirm
+}
+
+// LinkParent names a parent account that may reset the caller's PIN.
+func (h *UserHandler) LinkParent(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	var req LinkParentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn().Err(err).Msg("Invalid LinkParent request body")
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	parent, err := h.authService.LinkParent(user, req.Pin, req.Username, auth.ClientFromRequest(r))
+	if err != nil {
+		var locked *auth.LockedOutError
+		switch {
+		case errors.As(err, &locked):
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, auth.ErrInvalidPIN):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrGuestAccount):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, auth.ErrParentNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, auth.ErrInvalidParent):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to link parent")
+			http.Error(w, "Failed to link parent", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"parent_id": parent.ID, "parent_username": parent.Username})
+}
+
+func (h *UserHandler) UnlinkParent(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	if err := h.authService.UnlinkParent(user.ID); err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to unlink parent")
+		http.Error(w, "Failed to unlink parent", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "unlinked"})
+}
+
+func (h *UserHandler) ListChildren(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	children, err := h.authService.ListChildren(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to list children")
+		http.Error(w, "Failed to list children", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(children)
+}
+
+// ResetChildPIN issues a one-time PIN reset code for one of the caller's
+// children.
+func (h *UserHandler) ResetChildPIN(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+
+	code, expiresAt, err := h.authService.IssueChildResetCode(user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, auth.ErrNotYourChild) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to issue child PIN reset")
+		http.Error(w, "Failed to issue reset code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PINResetResponse{Code: code, ExpiresAt: expiresAt.UTC()})
+}
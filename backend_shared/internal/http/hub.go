@@ -0,0 +1,319 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/games/tictactoe"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	wsWriteTimeout = 5 * time.Second
+	wsPingInterval = 20 * time.Second
+)
+
+var errUnknownMessage = errors.New("unknown message type")
+
+// Hub relays human-vs-human Tic-Tac-Toe matches over WebSockets. The board
+// itself lives in the tictactoe service and the database; the hub only
+// tracks who is connected, pushes state to both players after every change
+// and runs the turn clocks that forfeit a stalled match.
+type Hub struct {
+	service *tictactoe.Service
+	origins []string
+
+	mu    sync.Mutex
+	rooms map[string]*room
+}
+
+// room is one match's live connections and turn clock.
+type room struct {
+	mu      sync.Mutex
+	clients map[string]*websocket.Conn // by user ID; a reconnect replaces the old conn
+	timer   *time.Timer
+}
+
+// wsMessage is what clients send. Type is "move" or "resign".
+type wsMessage struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// wsEvent is what the hub sends. Every "state" event carries the whole
+// match, so a client that missed events just renders the latest one.
+type wsEvent struct {
+	Type      string              `json:"type"` // state, error
+	Match     *tictactoe.PvPState `json:"match,omitempty"`
+	Connected map[string]bool     `json:"connected,omitempty"` // by symbol
+	Error     string              `json:"error,omitempty"`
+}
+
+// NewHub creates a hub accepting connections from the given browser
+// origins, as configured for CORS.
+func NewHub(service *tictactoe.Service, origins []string) *Hub {
+	h := &Hub{service: service, rooms: map[string]*room{}}
+	for _, o := range origins {
+		if u, err := url.Parse(o); err == nil && u.Host != "" {
+			h.origins = append(h.origins, u.Host)
+		}
+	}
+	return h
+}
+
+// Start re-arms the turn clocks of matches that were in progress when the
+// server last stopped.
+func (h *Hub) Start() error {
+	states, err := h.service.ActivePvPMatches()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		h.room(state.MatchID).arm(h, state)
+	}
+	log.Info().Int("matches", len(states)).Msg("Hub: Resumed PvP turn clocks")
+	return nil
+}
+
+// ServeMatch upgrades the request to a WebSocket for one match. Connecting
+// to a match that is still waiting for an opponent joins it as O.
+func (h *Hub) ServeMatch(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*domain.User)
+	matchID := chi.URLParam(r, "id")
+
+	state, err := h.service.JoinPvPMatch(user.ID, matchID)
+	if err != nil {
+		switch {
+		case errors.Is(err, tictactoe.ErrMatchNotFound):
+			http.Error(w, "Match not found", http.StatusNotFound)
+		case errors.Is(err, tictactoe.ErrMatchFull):
+			http.Error(w, "Match already has two players", http.StatusConflict)
+		default:
+			log.Error().Err(err).Str("match_id", matchID).Msg("Hub: Failed to join match")
+			http.Error(w, "Failed to join match", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.origins})
+	if err != nil {
+		log.Warn().Err(err).Str("match_id", matchID).Msg("Hub: WebSocket upgrade failed")
+		return
+	}
+	defer conn.CloseNow()
+
+	rm := h.attach(matchID, user.ID, conn)
+	log.Info().Str("match_id", matchID).Str("user_id", user.ID).Msg("Hub: Player connected")
+	rm.arm(h, state)
+	rm.broadcast(state)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go keepAlive(ctx, conn)
+
+	for {
+		var msg wsMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			break
+		}
+
+		var next *tictactoe.PvPState
+		switch msg.Type {
+		case "move":
+			next, err = h.service.PlayPvPMove(user.ID, matchID, msg.Index)
+		case "resign":
+			next, err = h.service.ResignPvPMatch(user.ID, matchID)
+		default:
+			err = errUnknownMessage
+		}
+		if err != nil {
+			send(ctx, conn, wsEvent{Type: "error", Error: clientError(matchID, err)})
+			continue
+		}
+		rm.arm(h, next)
+		rm.broadcast(next)
+		if next.Status == "finished" {
+			rm.closeAll("match finished")
+			break
+		}
+	}
+
+	if rm.detach(user.ID, conn) {
+		log.Info().Str("match_id", matchID).Str("user_id", user.ID).Msg("Hub: Player disconnected")
+		if state, err := h.service.GetPvPMatch(matchID); err == nil {
+			rm.broadcast(state)
+			h.release(matchID, rm, state)
+		}
+	}
+}
+
+func (h *Hub) room(matchID string) *room {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.roomLocked(matchID)
+}
+
+func (h *Hub) roomLocked(matchID string) *room {
+	rm, ok := h.rooms[matchID]
+	if !ok {
+		rm = &room{clients: map[string]*websocket.Conn{}}
+		h.rooms[matchID] = rm
+	}
+	return rm
+}
+
+// release forgets a room once nobody is connected and no clock is running.
+func (h *Hub) release(matchID string, rm *room, state *tictactoe.PvPState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if len(rm.clients) == 0 && state.Status != "active" {
+		delete(h.rooms, matchID)
+	}
+}
+
+// expire runs when a turn clock fires. The service decides whether the
+// turn really is overdue, so a clock racing a last-second move is harmless.
+func (h *Hub) expire(matchID string) {
+	state, err := h.service.GetPvPMatch(matchID)
+	if err != nil {
+		log.Error().Err(err).Str("match_id", matchID).Msg("Hub: Failed to settle turn clock")
+		return
+	}
+	rm := h.room(matchID)
+	rm.arm(h, state)
+	rm.broadcast(state)
+	if state.Status == "finished" {
+		rm.closeAll("match finished")
+		h.release(matchID, rm, state)
+	}
+}
+
+// attach registers conn in the match's room while holding the hub lock, so
+// the room can't be released between being looked up and joined.
+func (h *Hub) attach(matchID, userID string, conn *websocket.Conn) *room {
+	h.mu.Lock()
+	rm := h.roomLocked(matchID)
+	rm.mu.Lock()
+	old := rm.clients[userID]
+	rm.clients[userID] = conn
+	rm.mu.Unlock()
+	h.mu.Unlock()
+
+	if old != nil {
+		old.Close(websocket.StatusPolicyViolation, "replaced by a newer connection")
+	}
+	return rm
+}
+
+// detach removes conn unless a reconnect has already replaced it, and
+// reports whether it did.
+func (rm *room) detach(userID string, conn *websocket.Conn) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.clients[userID] != conn {
+		return false
+	}
+	delete(rm.clients, userID)
+	return true
+}
+
+// arm points the turn clock at the current turn's deadline, or stops it
+// once the match is no longer active.
+func (rm *room) arm(h *Hub, state *tictactoe.PvPState) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if rm.timer != nil {
+		rm.timer.Stop()
+		rm.timer = nil
+	}
+	if state.Status != "active" || state.TurnDeadline == nil {
+		return
+	}
+	// A little slack so the service sees the deadline as passed.
+	wait := time.Until(*state.TurnDeadline) + 100*time.Millisecond
+	rm.timer = time.AfterFunc(wait, func() { h.expire(state.MatchID) })
+}
+
+func (rm *room) broadcast(state *tictactoe.PvPState) {
+	rm.mu.Lock()
+	connected := map[string]bool{}
+	conns := make([]*websocket.Conn, 0, len(rm.clients))
+	for userID, conn := range rm.clients {
+		if symbol := state.Symbol(userID); symbol != "" {
+			connected[symbol] = true
+		}
+		conns = append(conns, conn)
+	}
+	rm.mu.Unlock()
+
+	event := wsEvent{Type: "state", Match: state, Connected: connected}
+	for _, conn := range conns {
+		send(context.Background(), conn, event)
+	}
+}
+
+func (rm *room) closeAll(reason string) {
+	rm.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(rm.clients))
+	for _, conn := range rm.clients {
+		conns = append(conns, conn)
+	}
+	rm.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close(websocket.StatusNormalClosure, reason)
+	}
+}
+
+// clientError turns a service error into a message for the player, hiding
+// anything unexpected.
+func clientError(matchID string, err error) string {
+	for _, known := range []error{
+		tictactoe.ErrNotYourTurn, tictactoe.ErrIllegalMove, tictactoe.ErrNotAPlayer, tictactoe.ErrMatchNotReady,
+		tictactoe.ErrMatchClosed, tictactoe.ErrMatchBusy, errUnknownMessage,
+	} {
+		if errors.Is(err, known) {
+			return err.Error()
+		}
+	}
+	log.Error().Err(err).Str("match_id", matchID).Msg("Hub: Failed to apply message")
+	return "failed to process message"
+}
+
+func send(ctx context.Context, conn *websocket.Conn, event wsEvent) {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	if err := wsjson.Write(ctx, conn, event); err != nil {
+		log.Debug().Err(err).Msg("Hub: Failed to send event")
+	}
+}
+
+// keepAlive pings the client so a connection that dropped without a close
+// frame is noticed and the player shows as disconnected.
+func keepAlive(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				conn.CloseNow()
+				return
+			}
+		}
+	}
+}
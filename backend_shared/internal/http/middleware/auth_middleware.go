@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/ramanasai/local-game-play/internal/auth"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
 )
 
 type AuthMiddleware struct {
@@ -19,6 +21,13 @@ func NewAuthMiddleware(authService *auth.AuthService) *AuthMiddleware {
 func (m *AuthMiddleware) Handle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" && isWebSocketUpgrade(r) {
+			// Browsers can't set headers on WebSocket handshakes, so the
+			// token may come in the query string there, and only there.
+			if token := r.URL.Query().Get("access_token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
 			return
@@ -32,14 +41,48 @@ func (m *AuthMiddleware) Handle(next http.Handler) http.Handler {
 
 		token := parts[1]
 		// Validate JWT using service
-		user, err := m.authService.GetUserFromToken(token)
+		user, session, err := m.authService.GetUserFromToken(token)
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
+		m.authService.Touch(session, auth.ClientFromRequest(r))
 
-		// Add user to context
+		// Add user and session to context
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "session_id", session.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// RequireAdmin refuses requests from users who aren't admins. It must run
+// after Handle.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value("user").(*domain.User)
+		if !user.IsAdmin() {
+			log.Warn().Str("user_id", user.ID).Str("path", r.URL.Path).Msg("Non-admin refused from admin route")
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAccount refuses requests from guests, for things each person may
+// only do once, such as a day's challenge: a guest could otherwise play it
+// again and merge the attempt into their account. It must run after Handle.
+func RequireAccount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value("user").(*domain.User)
+		if user.Guest {
+			http.Error(w, "Claim your guest account to play this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP sets RemoteAddr to the client address a trusted proxy forwarded
+// the request for, taken from X-Real-IP or else the last untrusted hop in
+// X-Forwarded-For. Requests that don't come from a trusted proxy keep
+// their own address, so a client can't pick the IP its login attempts are
+// counted under by sending those headers itself.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	isTrusted := func(ip net.IP) bool {
+		for _, network := range trusted {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedFor(r, isTrusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor returns the client address the proxy that sent r reports,
+// or "" if r didn't come from a trusted proxy or names no valid address.
+func forwardedFor(r *http.Request, isTrusted func(net.IP) bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if peer := net.ParseIP(host); peer == nil || !isTrusted(peer) {
+		return ""
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	// Each proxy appends the address it received the request from, so the
+	// rightmost address no trusted proxy claims is the client.
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !isTrusted(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -9,11 +9,23 @@ import (
 	authMw "github.com/ramanasai/local-game-play/internal/http/middleware"
 )
 
-func NewRouter(cfg *config.Config, userHandler *handlers.UserHandler, memHandler *handlers.MemoryHandler, tttHandler *handlers.TicTacToeHandler, game2048Handler *handlers.Game2048Handler, blockBlastHandler *handlers.BlockBlastHandler, auth *authMw.AuthMiddleware) *chi.Mux {
+// Handlers are the HTTP handlers NewRouter mounts.
+type Handlers struct {
+	User         *handlers.UserHandler
+	Games        *handlers.GamesHandler
+	Memory       *handlers.MemoryHandler
+	TicTacToe    *handlers.TicTacToeHandler
+	Game2048     *handlers.Game2048Handler
+	BlockBlast   *handlers.BlockBlastHandler
+	Admin        *handlers.AdminHandler
+	Achievements *handlers.AchievementsHandler
+}
+
+func NewRouter(cfg *config.Config, h Handlers, hub *Hub, auth *authMw.AuthMiddleware) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(authMw.RealIP(cfg.TrustedProxies))
 	r.Use(authMw.RequestLogger)
 	r.Use(middleware.Recoverer)
 
@@ -28,31 +40,85 @@ func NewRouter(cfg *config.Config, userHandler *handlers.UserHandler, memHandler
 
 	r.Route("/api/v1", func(r chi.Router) {
 		// Public Routes
-		r.Post("/users", userHandler.Login) // This is login/create
-		r.Get("/leaderboard", memHandler.GetLeaderboard)
-		r.Get("/leaderboard/tictactoe", tttHandler.GetLeaderboard)
-		r.Get("/leaderboard/2048", game2048Handler.GetLeaderboard)
-		r.Get("/leaderboard/blockblast", blockBlastHandler.GetLeaderboard)
-		r.Post("/play", tttHandler.GetMove) // Minimax
+		r.Post("/users", h.User.Login) // This is login/create
+		r.Post("/auth/refresh", h.User.Refresh)
+		r.Post("/auth/pin-reset", h.User.RedeemResetCode)
+		r.Post("/auth/guest", h.User.StartGuest)
+		r.Get("/games", h.Games.ListGames)
+		r.Get("/games/{game}/leaderboard", h.Games.GetLeaderboard)
+		r.Get("/games/{game}/daily/leaderboard", h.Games.GetDailyLeaderboard)
+		r.Get("/seasons", h.Games.ListSeasons)
+		r.Get("/seasons/{id}/standings", h.Games.GetSeasonStandings)
+		r.Post("/play", h.TicTacToe.GetMove) // Minimax
+		r.Post("/play/analysis", h.TicTacToe.AnalyzePosition)
 
 		// Protected Routes
 		r.Group(func(r chi.Router) {
 			r.Use(auth.Handle)
-			r.Get("/me", userHandler.Me)
-			r.Put("/users/pin", userHandler.UpdatePIN)
+			r.Get("/me", h.User.Me)
+			r.Get("/me/export", h.User.Export)
+			r.Get("/me/rank", h.Games.GetMyRank)
+			r.Get("/me/daily", h.Games.GetMyDaily)
+			r.Get("/me/achievements", h.Achievements.ListMine)
+			r.Delete("/me", h.User.DeleteAccount)
+			r.Post("/me/claim", h.User.ClaimGuest)
+			r.Post("/logout", h.User.Logout)
+			r.Get("/me/sessions", h.User.ListSessions)
+			r.Delete("/me/sessions", h.User.RevokeOtherSessions)
+			r.Delete("/me/sessions/{id}", h.User.RevokeSession)
+			r.Put("/users/pin", h.User.UpdatePIN)
+			r.Put("/me/parent", h.User.LinkParent)
+			r.Delete("/me/parent", h.User.UnlinkParent)
+			r.Get("/me/children", h.User.ListChildren)
+			r.Post("/me/children/{id}/pin-reset", h.User.ResetChildPIN)
+
+			// Every game
+			r.Get("/games/{game}/scores", h.Games.ListScores)
 
 			// Memory
-			r.Post("/scores", memHandler.SubmitScore)
+			r.Post("/memory/sessions", h.Memory.StartSession)
+			r.With(authMw.RequireAccount).Post("/memory/daily", h.Memory.StartDaily)
+			r.Get("/memory/sessions/{id}", h.Memory.GetSession)
+			r.Post("/memory/sessions/{id}/flips", h.Memory.Flip)
 
 			// TicTacToe
-			r.Post("/matches", tttHandler.SaveMatch)
-			r.Get("/stats", tttHandler.GetStats)
+			r.Post("/matches", h.TicTacToe.CreateMatch)
+			r.Get("/matches/{id}", h.TicTacToe.GetMatch)
+			r.Post("/matches/{id}/moves", h.TicTacToe.PlayMove)
+			r.Get("/matches/{id}/analysis", h.TicTacToe.AnalyzeMatch)
+			r.Get("/stats", h.TicTacToe.GetStats)
+
+			// TicTacToe against another player
+			r.Post("/pvp/matches", h.TicTacToe.CreatePvPMatch)
+			r.Get("/pvp/matches/{id}", h.TicTacToe.GetPvPMatch)
+			r.Get("/pvp/matches/{id}/ws", hub.ServeMatch)
 
 			// 2048
-			r.Post("/2048/scores", game2048Handler.SubmitScore)
+			r.Post("/2048/sessions", h.Game2048.StartSession)
+			r.With(authMw.RequireAccount).Post("/2048/daily", h.Game2048.StartDaily)
+			r.Post("/2048/scores", h.Game2048.SubmitScore)
 
 			// Block Blast
-			r.Post("/blockblast/scores", blockBlastHandler.SubmitScore)
+			r.Post("/blockblast/sessions", h.BlockBlast.StartSession)
+			r.With(authMw.RequireAccount).Post("/blockblast/daily", h.BlockBlast.StartDaily)
+			r.Get("/blockblast/sessions/{id}", h.BlockBlast.GetSession)
+			r.Post("/blockblast/sessions/{id}/placements", h.BlockBlast.Place)
+			r.Post("/blockblast/scores", h.BlockBlast.SubmitScore)
+
+			// Admin
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(authMw.RequireAdmin)
+				r.Get("/users", h.Admin.ListUsers)
+				r.Get("/users/{id}", h.Admin.GetUser)
+				r.Put("/users/{id}/username", h.Admin.RenameUser)
+				r.Put("/users/{id}/role", h.Admin.SetRole)
+				r.Put("/users/{id}/parent", h.Admin.SetParent)
+				r.Post("/users/{id}/pin-reset", h.Admin.ResetPIN)
+				r.Post("/users/{id}/suspension", h.Admin.SuspendUser)
+				r.Delete("/users/{id}/suspension", h.Admin.UnsuspendUser)
+				r.Delete("/users/{id}", h.Admin.DeleteUser)
+				r.Get("/audit", h.Admin.AuditLog)
+			})
 		})
 	})
 
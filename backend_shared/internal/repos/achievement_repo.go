@@ -0,0 +1,184 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// AchievementRepo keeps the achievements players have unlocked and works
+// them out from the results store.
+type AchievementRepo struct {
+	db *sql.DB
+}
+
+func NewAchievementRepo(db *sql.DB) *AchievementRepo {
+	return &AchievementRepo{db: db}
+}
+
+const unlockSelect = `SELECT achievement_id, unlocked_at FROM user_achievements`
+
+func scanUnlock(row interface{ Scan(...any) error }) (*domain.Unlock, error) {
+	var u domain.Unlock
+	if err := row.Scan(&u.AchievementID, &u.UnlockedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ListByUser returns a user's unlocks, earliest first.
+func (r *AchievementRepo) ListByUser(userID string) ([]*domain.Unlock, error) {
+	rows, err := r.db.Query(unlockSelect+` WHERE user_id = ? ORDER BY unlocked_at, achievement_id`, userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("AchievementRepo: Failed to list unlocks")
+		return nil, fmt.Errorf("failed to list achievements: %w", err)
+	}
+	defer rows.Close()
+
+	unlocks := []*domain.Unlock{}
+	for rows.Next() {
+		u, err := scanUnlock(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("AchievementRepo: Failed to scan unlock row")
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+	return unlocks, rows.Err()
+}
+
+// Unlock stores rule's unlock for userID if their results meet it and they
+// don't have it yet, and tells whether it did. gameIDs are the games an
+// EveryGameInADay rule asks for.
+func (r *AchievementRepo) Unlock(rule domain.AchievementRule, gameIDs []string, userID string) (bool, error) {
+	query, args, err := unlockSQL(rule, gameIDs, userID)
+	if err != nil {
+		return false, err
+	}
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		log.Error().Err(err).Str("achievement", rule.ID).Str("user_id", userID).Msg("AchievementRepo: Failed to unlock achievement")
+		return false, fmt.Errorf("failed to unlock achievement %s: %w", rule.ID, err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Backfilled returns the IDs of the rules Backfill has run for.
+func (r *AchievementRepo) Backfilled() (map[string]bool, error) {
+	rows, err := r.db.Query(`SELECT achievement_id FROM achievement_backfills`)
+	if err != nil {
+		log.Error().Err(err).Msg("AchievementRepo: Failed to list backfills")
+		return nil, fmt.Errorf("failed to list achievement backfills: %w", err)
+	}
+	defer rows.Close()
+
+	done := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		done[id] = true
+	}
+	return done, rows.Err()
+}
+
+// Backfill unlocks rule for every player whose existing results meet it
+// and records that it no longer needs doing, in one transaction. It
+// returns how many players unlocked it.
+func (r *AchievementRepo) Backfill(rule domain.AchievementRule, gameIDs []string) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query, args, err := unlockSQL(rule, gameIDs, "")
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		log.Error().Err(err).Str("achievement", rule.ID).Msg("AchievementRepo: Failed to backfill achievement")
+		return 0, fmt.Errorf("failed to backfill achievement %s: %w", rule.ID, err)
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO achievement_backfills (achievement_id) VALUES (?)`, rule.ID); err != nil {
+		log.Error().Err(err).Str("achievement", rule.ID).Msg("AchievementRepo: Failed to record backfill")
+		return 0, fmt.Errorf("failed to record achievement backfill: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+// conditionOps are the comparisons a condition may use; they end up in the
+// query text.
+var conditionOps = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// unlockSQL returns the statement storing rule's unlock for each player
+// meeting it, only userID if set, and the arguments it takes. Unlocks are
+// dated by the result that completed the rule.
+func unlockSQL(rule domain.AchievementRule, gameIDs []string, userID string) (string, []any, error) {
+	where := []string{"(? = '' OR r.user_id = ?)"}
+	args := []any{rule.ID, userID, userID}
+	if rule.Game != "" {
+		where = append(where, "r.game = ?")
+		args = append(args, rule.Game)
+	}
+	if rule.RankedOnly {
+		where = append(where, "r.ranked = 1")
+	}
+	for _, c := range rule.Where {
+		if !conditionOps[c.Op] {
+			return "", nil, fmt.Errorf("achievement %s: unknown operator %q", rule.ID, c.Op)
+		}
+		switch c.Field {
+		case "score", "tie_break":
+			where = append(where, "r."+c.Field+" "+c.Op+" ?")
+		default:
+			where = append(where, "json_extract(r.details, ?) "+c.Op+" ?")
+			args = append(args, "$."+c.Field)
+		}
+		args = append(args, c.Value)
+	}
+	at := `strftime('%Y-%m-%d %H:%M:%S', r.created_at)`
+
+	if rule.EveryGameInADay {
+		if len(gameIDs) == 0 {
+			return "", nil, fmt.Errorf("achievement %s: no games to play", rule.ID)
+		}
+		where = append(where, "r.game IN (?"+strings.Repeat(", ?", len(gameIDs)-1)+")")
+		for _, id := range gameIDs {
+			args = append(args, id)
+		}
+		// A day counts from the first result of the last game played on it.
+		query := `
+			INSERT OR IGNORE INTO user_achievements (user_id, achievement_id, unlocked_at)
+			SELECT user_id, ?, MIN(at) FROM (
+				SELECT user_id, day, MAX(first) AS at FROM (
+					SELECT r.user_id, date(r.created_at) AS day, r.game, MIN(` + at + `) AS first
+					FROM game_results r
+					WHERE ` + strings.Join(where, " AND ") + `
+					GROUP BY r.user_id, day, r.game
+				)
+				GROUP BY user_id, day
+				HAVING COUNT(*) = ?
+			)
+			GROUP BY user_id`
+		return query, append(args, len(gameIDs)), nil
+	}
+
+	query := `
+		INSERT OR IGNORE INTO user_achievements (user_id, achievement_id, unlocked_at)
+		SELECT user_id, ?, at FROM (
+			SELECT r.user_id, ` + at + ` AS at,
+			       ROW_NUMBER() OVER (PARTITION BY r.user_id ORDER BY ` + at + `, r.id) AS n
+			FROM game_results r
+			WHERE ` + strings.Join(where, " AND ") + `
+		)
+		WHERE n = ?`
+	return query, append(args, max(rule.Count, 1)), nil
+}
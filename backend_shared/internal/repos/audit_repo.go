@@ -0,0 +1,84 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+// insertAudit writes entry as part of tx, the transaction making the
+// change it describes, so the change and its audit entry are stored
+// together or not at all. A nil entry writes nothing.
+func insertAudit(tx *sql.Tx, entry *domain.AuditEntry) error {
+	if entry == nil {
+		return nil
+	}
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = time.Now().UTC()
+	if len(entry.Details) == 0 {
+		entry.Details = []byte("{}")
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO admin_audit (id, admin_id, admin_username, action, target_id, target_username, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.AdminID, entry.AdminUsername, entry.Action, entry.TargetID, entry.TargetUsername, string(entry.Details), entry.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Str("action", entry.Action).Msg("AuditRepo: Failed to record admin action")
+		return fmt.Errorf("failed to record admin action: %w", err)
+	}
+	return nil
+}
+
+const auditSelect = `
+	SELECT id, admin_id, admin_username, action, COALESCE(target_id, ''), COALESCE(target_username, ''), details, created_at
+	FROM admin_audit
+`
+
+func scanAuditEntry(row interface{ Scan(...any) error }) (*domain.AuditEntry, error) {
+	var e domain.AuditEntry
+	var details string
+	if err := row.Scan(&e.ID, &e.AdminID, &e.AdminUsername, &e.Action, &e.TargetID, &e.TargetUsername, &details, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.Details = []byte(details)
+	return &e, nil
+}
+
+// List returns the most recent entries, only those about targetID if it
+// is set.
+func (r *AuditRepo) List(targetID string, limit int) ([]*domain.AuditEntry, error) {
+	rows, err := r.db.Query(auditSelect+`
+		WHERE ? = '' OR target_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, targetID, targetID, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("AuditRepo: Failed to list admin actions")
+		return nil, fmt.Errorf("failed to list admin actions: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*domain.AuditEntry{}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("AuditRepo: Failed to scan audit row")
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -2,6 +2,7 @@ package repos
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -9,6 +10,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// ErrStaleSession is returned when a session changed between being read and
+// being written back, e.g. two placements submitted concurrently.
+var ErrStaleSession = errors.New("session was modified concurrently")
+
 type BlockBlastRepo struct {
 	db *sql.DB
 }
@@ -17,40 +22,114 @@ func NewBlockBlastRepo(db *sql.DB) *BlockBlastRepo {
 	return &BlockBlastRepo{db: db}
 }
 
-func (r *BlockBlastRepo) SaveScore(userID string, score int) error {
-	id := uuid.New().String()
-	query := `INSERT INTO scores_blockblast (id, user_id, score) VALUES (?, ?, ?)`
-	_, err := r.db.Exec(query, id, userID, score)
+// CreateSession stores a new session. A dailyDate makes it that day's
+// challenge, which fails with ErrDailyPlayed if the user already started it.
+func (r *BlockBlastRepo) CreateSession(userID string, seed uint32, dailyDate string) (*domain.BlockBlastSession, error) {
+	session := &domain.BlockBlastSession{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Seed:       seed,
+		DailyDate:  dailyDate,
+		Status:     "active",
+		Placements: "[]",
+	}
+	query := `INSERT INTO blockblast_sessions (id, user_id, seed, daily_date) VALUES (?, ?, ?, NULLIF(?, ''))`
+	_, err := r.db.Exec(query, session.ID, userID, int64(seed), dailyDate)
 	if err != nil {
-		log.Error().Err(err).Str("user_id", userID).Int("score", score).Msg("BlockBlastRepo: Failed to save score")
-		return fmt.Errorf("failed to save blockblast score: %w", err)
+		if isDailyConflict(err) {
+			return nil, ErrDailyPlayed
+		}
+		log.Error().Err(err).Str("user_id", userID).Msg("BlockBlastRepo: Failed to create session")
+		return nil, fmt.Errorf("failed to create blockblast session: %w", err)
 	}
-	return nil
+	return session, nil
 }
 
-func (r *BlockBlastRepo) GetLeaderboard(limit int) ([]domain.ScoreBlockBlast, error) {
-	query := `
-		SELECT s.id, s.user_id, s.score, s.created_at, u.username
-		FROM scores_blockblast s
-		JOIN users u ON s.user_id = u.id
-		ORDER BY s.score DESC
-		LIMIT ?
-	`
-	rows, err := r.db.Query(query, limit)
+const blockBlastSessionSelect = `
+	SELECT id, user_id, seed, COALESCE(daily_date, ''), status, placements, placement_count, score, created_at, finished_at
+	FROM blockblast_sessions
+`
+
+func scanBlockBlastSession(row interface{ Scan(...any) error }) (*domain.BlockBlastSession, error) {
+	var s domain.BlockBlastSession
+	var seed int64
+	var finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &seed, &s.DailyDate, &s.Status, &s.Placements, &s.PlacementCount, &s.Score, &s.CreatedAt, &finishedAt)
 	if err != nil {
-		log.Error().Err(err).Msg("BlockBlastRepo: Failed to get leaderboard")
-		return nil, fmt.Errorf("failed to get blockblast leaderboard: %w", err)
+		return nil, err
+	}
+	s.Seed = uint32(seed)
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
 	}
-	defer rows.Close()
+	return &s, nil
+}
 
-	scores := []domain.ScoreBlockBlast{}
-	for rows.Next() {
-		var s domain.ScoreBlockBlast
-		if err := rows.Scan(&s.ID, &s.UserID, &s.Score, &s.CreatedAt, &s.Username); err != nil {
-			log.Error().Err(err).Msg("BlockBlastRepo: Failed to scan score row")
-			return nil, err
+func (r *BlockBlastRepo) GetSession(id string) (*domain.BlockBlastSession, error) {
+	s, err := scanBlockBlastSession(r.db.QueryRow(blockBlastSessionSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("session_id", id).Msg("BlockBlastRepo: Failed to get session")
 		}
-		scores = append(scores, s)
+		return nil, err
 	}
-	return scores, nil
+	return s, nil
+}
+
+// HasDailySession tells whether the user started the challenge of date.
+func (r *BlockBlastRepo) HasDailySession(userID, date string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM blockblast_sessions WHERE user_id = ? AND daily_date = ?)`, userID, date).Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("BlockBlastRepo: Failed to look up daily session")
+		return false, fmt.Errorf("failed to look up daily session: %w", err)
+	}
+	return exists, nil
+}
+
+// UpdateSession stores a new placement log. prevCount is the placement count
+// the caller read; the write fails with ErrStaleSession if it has changed.
+func (r *BlockBlastRepo) UpdateSession(session *domain.BlockBlastSession, prevCount int) error {
+	res, err := r.db.Exec(`
+		UPDATE blockblast_sessions
+		SET placements = ?, placement_count = ?, score = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND placement_count = ?
+	`, session.Placements, session.PlacementCount, session.Score, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("BlockBlastRepo: Failed to update session")
+		return fmt.Errorf("failed to update blockblast session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+	return nil
+}
+
+// FinishSession closes the session and records result in one transaction.
+func (r *BlockBlastRepo) FinishSession(session *domain.BlockBlastSession, prevCount int, result *domain.GameResult) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE blockblast_sessions
+		SET status = 'finished', placements = ?, placement_count = ?, score = ?,
+		    updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND placement_count = ?
+	`, session.Placements, session.PlacementCount, session.Score, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("BlockBlastRepo: Failed to finish session")
+		return fmt.Errorf("failed to finish blockblast session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+
+	if err := insertResult(tx, result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
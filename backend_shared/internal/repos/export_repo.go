@@ -0,0 +1,123 @@
+package repos
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// Export gathers every row stored about a user, read in one transaction so
+// the parts agree with each other.
+func (r *UserRepo) Export(userID string) (*domain.UserExport, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	export := &domain.UserExport{ExportedAt: time.Now().UTC()}
+	if export.Profile, err = scanUser(tx.QueryRow(userSelect+` WHERE id = ?`, userID)); err != nil {
+		return nil, err
+	}
+
+	export.Sessions, err = collect(tx, sessionSelect+` WHERE user_id = ? ORDER BY created_at`, userID, scanSession)
+	if err != nil {
+		return nil, err
+	}
+	export.Results, err = collect(tx, resultSelect+` WHERE r.user_id = ? ORDER BY r.created_at`, userID, scanResult)
+	if err != nil {
+		return nil, err
+	}
+	export.Standings, err = collect(tx, standingSelect+` WHERE user_id = ? ORDER BY season_id, game, variant`, userID, scanStanding)
+	if err != nil {
+		return nil, err
+	}
+	export.Unlocks, err = collect(tx, unlockSelect+` WHERE user_id = ? ORDER BY unlocked_at, achievement_id`, userID, scanUnlock)
+	if err != nil {
+		return nil, err
+	}
+
+	export.MemorySessions, err = collect(tx, memorySessionSelect+` WHERE user_id = ? ORDER BY created_at`, userID,
+		withLog(scanMemorySession, func(s *domain.MemorySession) any { return json.RawMessage(s.Flips) }))
+	if err != nil {
+		return nil, err
+	}
+	export.Game2048Sessions, err = collect(tx, game2048SessionSelect+` WHERE user_id = ? ORDER BY created_at`, userID,
+		withLog(scanGame2048Session, func(s *domain.Game2048Session) any { return s.Moves }))
+	if err != nil {
+		return nil, err
+	}
+	export.BlockBlastSessions, err = collect(tx, blockBlastSessionSelect+` WHERE user_id = ? ORDER BY created_at`, userID,
+		withLog(scanBlockBlastSession, func(s *domain.BlockBlastSession) any { return json.RawMessage(s.Placements) }))
+	if err != nil {
+		return nil, err
+	}
+	export.TicTacToeSessions, err = collect(tx, ticTacToeSessionSelect+` WHERE user_id = ? ORDER BY created_at`, userID,
+		withLog(scanTicTacToeSession, func(s *domain.TicTacToeSession) any { return json.RawMessage(s.Moves) }))
+	if err != nil {
+		return nil, err
+	}
+	export.PvPMatches, err = collect(tx, pvpSelect+` WHERE ? IN (p.x_user_id, p.o_user_id) ORDER BY p.created_at`, userID,
+		withLog(scanPvPSession, func(s *domain.PvPSession) any { return json.RawMessage(s.Moves) }))
+	if err != nil {
+		return nil, err
+	}
+
+	export.PinResetCodes, err = collect(tx, pinResetSelect+` WHERE user_id = ? ORDER BY created_at`, userID, scanPinResetCode)
+	if err != nil {
+		return nil, err
+	}
+	// Login throttling is kept per username rather than per user ID.
+	byUsername := ` WHERE scope = 'user' AND key = (SELECT username_key FROM users WHERE id = ?)`
+	export.LoginFailures, err = collect(tx, loginFailuresSelect+byUsername, userID, scanLoginFailures)
+	if err != nil {
+		return nil, err
+	}
+	export.Lockouts, err = collect(tx, lockoutSelect+byUsername+` ORDER BY created_at`, userID, scanLockout)
+	if err != nil {
+		return nil, err
+	}
+	export.AuditEntries, err = collect(tx, auditSelect+` WHERE target_id = ? ORDER BY created_at`, userID, scanAuditEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// withLog wraps scan so each session comes with its log of play, as
+// returned by playLog.
+func withLog[T any](scan func(interface{ Scan(...any) error }) (T, error), playLog func(T) any) func(interface{ Scan(...any) error }) (*domain.SessionExport[T], error) {
+	return func(row interface{ Scan(...any) error }) (*domain.SessionExport[T], error) {
+		session, err := scan(row)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.SessionExport[T]{Session: session, Log: playLog(session)}, nil
+	}
+}
+
+// collect runs query for userID and scans every row with scan.
+func collect[T any](tx *sql.Tx, query, userID string, scan func(interface{ Scan(...any) error }) (T, error)) ([]T, error) {
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("UserRepo: Failed to export rows")
+		return nil, fmt.Errorf("failed to export user data: %w", err)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("UserRepo: Failed to scan exported row")
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -2,6 +2,7 @@ package repos
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -9,6 +10,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+var ErrSessionNotActive = errors.New("session is not active")
+
 type Game2048Repo struct {
 	db *sql.DB
 }
@@ -17,40 +20,98 @@ func NewGame2048Repo(db *sql.DB) *Game2048Repo {
 	return &Game2048Repo{db: db}
 }
 
-func (r *Game2048Repo) SaveScore(userID string, score int) error {
-	id := uuid.New().String()
-	query := `INSERT INTO scores_2048 (id, user_id, score) VALUES (?, ?, ?)`
-	_, err := r.db.Exec(query, id, userID, score)
+// CreateSession stores a new session. A dailyDate makes it that day's
+// challenge, which fails with ErrDailyPlayed if the user already started it.
+func (r *Game2048Repo) CreateSession(userID string, seed uint32, dailyDate string) (*domain.Game2048Session, error) {
+	session := &domain.Game2048Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Seed:      seed,
+		DailyDate: dailyDate,
+		Status:    "active",
+	}
+	query := `INSERT INTO game2048_sessions (id, user_id, seed, daily_date) VALUES (?, ?, ?, NULLIF(?, ''))`
+	_, err := r.db.Exec(query, session.ID, userID, int64(seed), dailyDate)
 	if err != nil {
-		log.Error().Err(err).Str("user_id", userID).Int("score", score).Msg("Game2048Repo: Failed to save score")
-		return fmt.Errorf("failed to save 2048 score: %w", err)
+		if isDailyConflict(err) {
+			return nil, ErrDailyPlayed
+		}
+		log.Error().Err(err).Str("user_id", userID).Msg("Game2048Repo: Failed to create session")
+		return nil, fmt.Errorf("failed to create 2048 session: %w", err)
 	}
-	return nil
+	return session, nil
 }
 
-func (r *Game2048Repo) GetLeaderboard(limit int) ([]domain.Score2048, error) {
-	query := `
-		SELECT s.id, s.user_id, s.score, s.created_at, u.username
-		FROM scores_2048 s
-		JOIN users u ON s.user_id = u.id
-		ORDER BY s.score DESC
-		LIMIT ?
-	`
-	rows, err := r.db.Query(query, limit)
+const game2048SessionSelect = `
+	SELECT id, user_id, seed, COALESCE(daily_date, ''), status, moves, score, max_tile, created_at, finished_at
+	FROM game2048_sessions
+`
+
+func scanGame2048Session(row interface{ Scan(...any) error }) (*domain.Game2048Session, error) {
+	var s domain.Game2048Session
+	var seed int64
+	var score, maxTile sql.NullInt64
+	var finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &seed, &s.DailyDate, &s.Status, &s.Moves, &score, &maxTile, &s.CreatedAt, &finishedAt)
 	if err != nil {
-		log.Error().Err(err).Msg("Game2048Repo: Failed to get leaderboard")
-		return nil, fmt.Errorf("failed to get 2048 leaderboard: %w", err)
+		return nil, err
+	}
+	s.Seed = uint32(seed)
+	s.Score = int(score.Int64)
+	s.MaxTile = int(maxTile.Int64)
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
 	}
-	defer rows.Close()
+	return &s, nil
+}
 
-	scores := []domain.Score2048{}
-	for rows.Next() {
-		var s domain.Score2048
-		if err := rows.Scan(&s.ID, &s.UserID, &s.Score, &s.CreatedAt, &s.Username); err != nil {
-			log.Error().Err(err).Msg("Game2048Repo: Failed to scan score row")
-			return nil, err
+func (r *Game2048Repo) GetSession(id string) (*domain.Game2048Session, error) {
+	s, err := scanGame2048Session(r.db.QueryRow(game2048SessionSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("session_id", id).Msg("Game2048Repo: Failed to get session")
 		}
-		scores = append(scores, s)
+		return nil, err
+	}
+	return s, nil
+}
+
+// HasDailySession tells whether the user started the challenge of date.
+func (r *Game2048Repo) HasDailySession(userID, date string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM game2048_sessions WHERE user_id = ? AND daily_date = ?)`, userID, date).Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Game2048Repo: Failed to look up daily session")
+		return false, fmt.Errorf("failed to look up daily session: %w", err)
 	}
-	return scores, nil
+	return exists, nil
+}
+
+// FinishSession closes an active session with its verified result and records
+// result in the same transaction.
+func (r *Game2048Repo) FinishSession(session *domain.Game2048Session, result *domain.GameResult) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE game2048_sessions
+		SET status = 'finished', moves = ?, score = ?, max_tile = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active'
+	`, session.Moves, session.Score, session.MaxTile, session.ID)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("Game2048Repo: Failed to finish session")
+		return fmt.Errorf("failed to finish 2048 session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotActive
+	}
+
+	if err := insertResult(tx, result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,142 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type LoginAttemptRepo struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepo(db *sql.DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db}
+}
+
+const loginFailuresSelect = `SELECT scope, key, failures, lockouts, last_failure_at, locked_until FROM login_failures`
+
+func scanLoginFailures(row interface{ Scan(...any) error }) (*domain.LoginFailures, error) {
+	var f domain.LoginFailures
+	var lastFailure, lockedUntil int64
+	if err := row.Scan(&f.Scope, &f.Key, &f.Failures, &f.Lockouts, &lastFailure, &lockedUntil); err != nil {
+		return nil, err
+	}
+	f.LastFailureAt = time.Unix(lastFailure, 0)
+	f.LockedUntil = time.Unix(lockedUntil, 0)
+	return &f, nil
+}
+
+const lockoutSelect = `SELECT id, scope, failures, locked_until, created_at FROM lockout_events`
+
+func scanLockout(row interface{ Scan(...any) error }) (*domain.LockoutEvent, error) {
+	var e domain.LockoutEvent
+	if err := row.Scan(&e.ID, &e.Scope, &e.Failures, &e.LockedUntil, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Get returns the failure record for scope and key, or an empty one if
+// there have been no failures.
+func (r *LoginAttemptRepo) Get(scope, key string) (*domain.LoginFailures, error) {
+	f, err := scanLoginFailures(r.db.QueryRow(loginFailuresSelect+` WHERE scope = ? AND key = ?`, scope, key))
+	if err == sql.ErrNoRows {
+		return &domain.LoginFailures{Scope: scope, Key: key}, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to get failures")
+		return nil, fmt.Errorf("failed to get login failures: %w", err)
+	}
+	return f, nil
+}
+
+// RecordAttempt counts one PIN attempt at now, before the PIN is checked.
+// Failures older than window are forgotten first, and lockouts older than
+// lockoutWindow. An attempt is refused, and nothing counted, while the key
+// is locked or while more than freeAttempts recent failures make it wait
+// baseDelay, doubling per failure, since the last one; RecordAttempt then
+// returns sql.ErrNoRows. Deciding and counting in one statement means
+// attempts made at the same time can't all get past the limits.
+func (r *LoginAttemptRepo) RecordAttempt(scope, key string, now time.Time, window, lockoutWindow time.Duration, freeAttempts int, baseDelay time.Duration) (*domain.LoginFailures, error) {
+	f := &domain.LoginFailures{Scope: scope, Key: key, LastFailureAt: now}
+	var lockedUntil int64
+	windowStart := now.Add(-window).Unix()
+	err := r.db.QueryRow(`
+		INSERT INTO login_failures (scope, key, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < ? THEN 1 ELSE login_failures.failures + 1 END,
+			lockouts = CASE WHEN login_failures.last_failure_at < ? THEN 0 ELSE login_failures.lockouts END,
+			last_failure_at = excluded.last_failure_at
+		WHERE login_failures.locked_until <= excluded.last_failure_at
+			AND (login_failures.failures <= ? OR login_failures.last_failure_at < ?
+				OR login_failures.last_failure_at + (? << MIN(login_failures.failures - ? - 1, 16)) <= excluded.last_failure_at)
+		RETURNING failures, lockouts, locked_until
+	`, scope, key, now.Unix(), windowStart, now.Add(-lockoutWindow).Unix(),
+		freeAttempts, windowStart, int64(baseDelay/time.Second), freeAttempts).Scan(&f.Failures, &f.Lockouts, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to record attempt")
+		return nil, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	f.LockedUntil = time.Unix(lockedUntil, 0)
+	return f, nil
+}
+
+// Uncount takes back one attempt counted by RecordAttempt.
+func (r *LoginAttemptRepo) Uncount(scope, key string) error {
+	_, err := r.db.Exec(`UPDATE login_failures SET failures = MAX(failures - 1, 0) WHERE scope = ? AND key = ?`, scope, key)
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to uncount attempt")
+		return fmt.Errorf("failed to uncount login attempt: %w", err)
+	}
+	return nil
+}
+
+// Lock locks scope and key out until the given time, starts the failure
+// count over and writes the lockout to the audit trail. It does nothing
+// unless the key still has the given number of failures, so two requests
+// that both reached the limit lock it out once.
+func (r *LoginAttemptRepo) Lock(scope, key string, failures int, until time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE login_failures SET failures = 0, lockouts = lockouts + 1, locked_until = ?
+		WHERE scope = ? AND key = ? AND failures = ?
+	`, until.Unix(), scope, key, failures)
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to lock")
+		return fmt.Errorf("failed to lock login: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`INSERT INTO lockout_events (id, scope, key, failures, locked_until) VALUES (?, ?, ?, ?, ?)`,
+		uuid.New().String(), scope, key, failures, until.UTC())
+	if err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to record lockout")
+		return fmt.Errorf("failed to record lockout: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Reset forgets all failures for scope and key.
+func (r *LoginAttemptRepo) Reset(scope, key string) error {
+	if _, err := r.db.Exec(`DELETE FROM login_failures WHERE scope = ? AND key = ?`, scope, key); err != nil {
+		log.Error().Err(err).Str("scope", scope).Msg("LoginAttemptRepo: Failed to reset failures")
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
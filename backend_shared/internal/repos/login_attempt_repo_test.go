@@ -0,0 +1,136 @@
+package repos
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/db/dbtest"
+)
+
+const (
+	testWindow        = time.Hour
+	testLockoutWindow = 24 * time.Hour
+	testFreeAttempts  = 3
+	testBaseDelay     = time.Second
+	testScope         = "user"
+)
+
+func TestRecordAttempt(t *testing.T) {
+	repo := NewLoginAttemptRepo(dbtest.Open(t))
+	t0 := time.Unix(1_700_000_000, 0)
+
+	// Each attempt runs against the count the ones before it left; a
+	// refused attempt wants 0 and must leave the count alone.
+	attempts := []struct {
+		name string
+		at   time.Duration
+		want int
+	}{
+		{"first", 0, 1},
+		{"second", 0, 2},
+		{"third", 0, 3},
+		{"last free attempt", 0, 4},
+		{"before the 1s delay", 0, 0},
+		{"after the 1s delay", time.Second, 5},
+		{"before the 2s delay", 2 * time.Second, 0},
+		{"after the 2s delay", 3 * time.Second, 6},
+		{"after the window", 4*time.Second + testWindow, 1},
+		{"free again", 4*time.Second + testWindow, 2},
+	}
+	for _, a := range attempts {
+		f, err := repo.RecordAttempt(testScope, "bob", t0.Add(a.at), testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay)
+		if a.want == 0 {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Fatalf("%s: got %v, want the attempt refused", a.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", a.name, err)
+		}
+		if f.Failures != a.want {
+			t.Fatalf("%s: %d failures, want %d", a.name, f.Failures, a.want)
+		}
+	}
+
+	// Other keys have counts of their own.
+	f, err := repo.RecordAttempt(testScope, "carol", t0, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay)
+	if err != nil || f.Failures != 1 {
+		t.Fatalf("other key: %+v, %v", f, err)
+	}
+}
+
+func TestLockRefusesAttemptsUntilExpired(t *testing.T) {
+	repo := NewLoginAttemptRepo(dbtest.Open(t))
+	t0 := time.Unix(1_700_000_000, 0)
+	until := t0.Add(15 * time.Minute)
+
+	if _, err := repo.RecordAttempt(testScope, "bob", t0, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay); err != nil {
+		t.Fatal(err)
+	}
+	// A stale failure count means another request locked it already.
+	if err := repo.Lock(testScope, "bob", 2, until); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := repo.Get(testScope, "bob"); f.Lockouts != 0 {
+		t.Fatalf("locked with a stale failure count: %+v", f)
+	}
+	if err := repo.Lock(testScope, "bob", 1, until); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, at := range []time.Time{t0, until.Add(-time.Second)} {
+		if _, err := repo.RecordAttempt(testScope, "bob", at, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("attempt at %v while locked: %v", at.Sub(t0), err)
+		}
+	}
+	f, err := repo.RecordAttempt(testScope, "bob", until, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay)
+	if err != nil {
+		t.Fatalf("attempt once unlocked: %v", err)
+	}
+	if f.Failures != 1 || f.Lockouts != 1 {
+		t.Errorf("after lockout: %d failures, %d lockouts; want 1 and 1", f.Failures, f.Lockouts)
+	}
+
+	// Lockouts are forgotten once the lockout window has passed.
+	later := until.Add(testLockoutWindow + time.Second)
+	if f, err = repo.RecordAttempt(testScope, "bob", later, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay); err != nil || f.Lockouts != 0 {
+		t.Errorf("after the lockout window: %+v, %v", f, err)
+	}
+}
+
+func TestUncountAndReset(t *testing.T) {
+	repo := NewLoginAttemptRepo(dbtest.Open(t))
+	t0 := time.Unix(1_700_000_000, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := repo.RecordAttempt(testScope, "bob", t0, testWindow, testLockoutWindow, testFreeAttempts, testBaseDelay); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want int
+	}{
+		{"uncount", func() error { return repo.Uncount(testScope, "bob") }, 1},
+		{"uncount", func() error { return repo.Uncount(testScope, "bob") }, 0},
+		{"uncount at zero", func() error { return repo.Uncount(testScope, "bob") }, 0},
+		{"uncount unknown key", func() error { return repo.Uncount(testScope, "nobody") }, 0},
+		{"reset", func() error { return repo.Reset(testScope, "bob") }, 0},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		f, err := repo.Get(testScope, "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Failures != step.want {
+			t.Fatalf("%s: %d failures, want %d", step.name, f.Failures, step.want)
+		}
+	}
+}
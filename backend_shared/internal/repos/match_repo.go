@@ -3,7 +3,9 @@ package repos
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ramanasai/local-game-play/internal/domain"
 	"github.com/rs/zerolog/log"
 )
@@ -16,24 +18,108 @@ func NewMatchRepo(db *sql.DB) *MatchRepo {
 	return &MatchRepo{db: db}
 }
 
-func (r *MatchRepo) Create(match *domain.Match) error {
-	query := `INSERT INTO matches (id, user_id, difficulty, result, moves) VALUES (?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, match.ID, match.UserID, match.Difficulty, match.Result, match.Moves)
+func (r *MatchRepo) CreateSession(userID, difficulty, ruleset string) (*domain.TicTacToeSession, error) {
+	session := &domain.TicTacToeSession{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Difficulty: difficulty,
+		Ruleset:    ruleset,
+		Status:     "active",
+		Moves:      "[]",
+	}
+	query := `INSERT INTO tictactoe_sessions (id, user_id, difficulty, ruleset) VALUES (?, ?, ?, ?)`
+	_, err := r.db.Exec(query, session.ID, userID, difficulty, ruleset)
 	if err != nil {
-		log.Error().Err(err).Str("match_id", match.ID).Msg("MatchRepo: Failed to create match")
-		return fmt.Errorf("failed to create match: %w", err)
+		log.Error().Err(err).Str("user_id", userID).Msg("MatchRepo: Failed to create session")
+		return nil, fmt.Errorf("failed to create tictactoe session: %w", err)
+	}
+	return session, nil
+}
+
+const ticTacToeSessionSelect = `
+	SELECT id, user_id, difficulty, ruleset, status, moves, move_count, created_at, finished_at
+	FROM tictactoe_sessions
+`
+
+func scanTicTacToeSession(row interface{ Scan(...any) error }) (*domain.TicTacToeSession, error) {
+	var s domain.TicTacToeSession
+	var finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.Difficulty, &s.Ruleset, &s.Status, &s.Moves, &s.MoveCount, &s.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+	return &s, nil
+}
+
+func (r *MatchRepo) GetSession(id string) (*domain.TicTacToeSession, error) {
+	s, err := scanTicTacToeSession(r.db.QueryRow(ticTacToeSessionSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("session_id", id).Msg("MatchRepo: Failed to get session")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateSessionMoves stores the move list, failing with ErrStaleSession if
+// another move was applied since prevCount was read.
+func (r *MatchRepo) UpdateSessionMoves(session *domain.TicTacToeSession, prevCount int) error {
+	res, err := r.db.Exec(`
+		UPDATE tictactoe_sessions SET moves = ?, move_count = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND move_count = ?
+	`, session.Moves, session.MoveCount, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("MatchRepo: Failed to update session")
+		return fmt.Errorf("failed to update tictactoe session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
 	}
 	return nil
 }
 
-func (r *MatchRepo) GetStatsByUser(userID string) (map[string]domain.StatsSummary, error) {
+// FinishSession closes the session and records result in one transaction.
+func (r *MatchRepo) FinishSession(session *domain.TicTacToeSession, prevCount int, result *domain.GameResult) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE tictactoe_sessions
+		SET status = 'finished', moves = ?, move_count = ?, updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND move_count = ?
+	`, session.Moves, session.MoveCount, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("MatchRepo: Failed to finish session")
+		return fmt.Errorf("failed to finish tictactoe session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+
+	if err := insertResult(tx, result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetStatsByUser counts a user's results per difficulty on one ruleset.
+// Matches against other players are counted under "pvp".
+func (r *MatchRepo) GetStatsByUser(userID, ruleset string) (map[string]domain.StatsSummary, error) {
 	query := `
-		SELECT difficulty, result, COUNT(*) 
-		FROM matches 
-		WHERE user_id = ? 
+		SELECT json_extract(details, '$.difficulty') AS difficulty, json_extract(details, '$.result') AS result, COUNT(*)
+		FROM game_results
+		WHERE game = 'tictactoe' AND user_id = ? AND variant = ? AND session_id IS NOT NULL
 		GROUP BY difficulty, result
 	`
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.Query(query, userID, ruleset)
 	if err != nil {
 		log.Error().Err(err).Str("user_id", userID).Msg("MatchRepo: Failed to query stats")
 		return nil, fmt.Errorf("failed to query stats: %w", err)
@@ -42,7 +128,7 @@ func (r *MatchRepo) GetStatsByUser(userID string) (map[string]domain.StatsSummar
 
 	summary := make(map[string]domain.StatsSummary)
 	// Initialize map
-	for _, d := range []string{"easy", "medium", "hard"} {
+	for _, d := range []string{"easy", "medium", "hard", "pvp"} {
 		summary[d] = domain.StatsSummary{}
 	}
 
@@ -69,37 +155,138 @@ func (r *MatchRepo) GetStatsByUser(userID string) (map[string]domain.StatsSummar
 	return summary, nil
 }
 
-type TTTLeaderboardEntry struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Wins     int    `json:"wins"`
+const pvpSelect = `
+	SELECT p.id, p.x_user_id, ux.username, COALESCE(p.o_user_id, ''), COALESCE(uo.username, ''), p.ruleset, p.status,
+	       p.moves, p.move_count, COALESCE(p.winner, ''), COALESCE(p.end_reason, ''), p.turn_started_at, p.created_at, p.finished_at
+	FROM pvp_matches p
+	JOIN users ux ON ux.id = p.x_user_id
+	LEFT JOIN users uo ON uo.id = p.o_user_id
+`
+
+func scanPvPSession(row interface{ Scan(...any) error }) (*domain.PvPSession, error) {
+	var s domain.PvPSession
+	var turnStartedAt, finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.XUserID, &s.XUsername, &s.OUserID, &s.OUsername, &s.Ruleset, &s.Status,
+		&s.Moves, &s.MoveCount, &s.Winner, &s.EndReason, &turnStartedAt, &s.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if turnStartedAt.Valid {
+		s.TurnStartedAt = &turnStartedAt.Time
+	}
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+	return &s, nil
 }
 
-func (r *MatchRepo) GetLeaderboard(limit int) ([]TTTLeaderboardEntry, error) {
-	query := `
-		SELECT m.user_id, u.username, COUNT(*) as wins
-		FROM matches m
-		JOIN users u ON m.user_id = u.id
-		WHERE m.result = 'win' AND m.difficulty = 'hard'
-		GROUP BY m.user_id
-		ORDER BY wins DESC
-		LIMIT ?
-	`
-	rows, err := r.db.Query(query, limit)
+func (r *MatchRepo) CreatePvPSession(userID, ruleset string) (*domain.PvPSession, error) {
+	id := uuid.New().String()
+	_, err := r.db.Exec(`INSERT INTO pvp_matches (id, x_user_id, ruleset) VALUES (?, ?, ?)`, id, userID, ruleset)
 	if err != nil {
-		log.Error().Err(err).Msg("MatchRepo: Failed to query leaderboard")
-		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+		log.Error().Err(err).Str("user_id", userID).Msg("MatchRepo: Failed to create PvP match")
+		return nil, fmt.Errorf("failed to create pvp match: %w", err)
+	}
+	return r.GetPvPSession(id)
+}
+
+func (r *MatchRepo) GetPvPSession(id string) (*domain.PvPSession, error) {
+	s, err := scanPvPSession(r.db.QueryRow(pvpSelect+` WHERE p.id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("match_id", id).Msg("MatchRepo: Failed to get PvP match")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListActivePvPSessions returns every PvP match still being played, so
+// turn clocks can be re-armed after a restart.
+func (r *MatchRepo) ListActivePvPSessions() ([]*domain.PvPSession, error) {
+	rows, err := r.db.Query(pvpSelect + ` WHERE p.status = 'active'`)
+	if err != nil {
+		log.Error().Err(err).Msg("MatchRepo: Failed to list active PvP matches")
+		return nil, fmt.Errorf("failed to list pvp matches: %w", err)
 	}
 	defer rows.Close()
 
-	leaderboard := []TTTLeaderboardEntry{}
+	sessions := []*domain.PvPSession{}
 	for rows.Next() {
-		var entry TTTLeaderboardEntry
-		if err := rows.Scan(&entry.UserID, &entry.Username, &entry.Wins); err != nil {
-			log.Error().Err(err).Msg("MatchRepo: Failed to scan leaderboard row")
+		s, err := scanPvPSession(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("MatchRepo: Failed to scan PvP match row")
 			return nil, err
 		}
-		leaderboard = append(leaderboard, entry)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// JoinPvPSession seats userID as O and starts the first turn clock. It
+// fails with ErrSessionNotActive if someone else joined first.
+func (r *MatchRepo) JoinPvPSession(id, userID string, startedAt time.Time) error {
+	res, err := r.db.Exec(`
+		UPDATE pvp_matches
+		SET o_user_id = ?, status = 'active', turn_started_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'waiting' AND x_user_id != ?
+	`, userID, startedAt, id, userID)
+	if err != nil {
+		log.Error().Err(err).Str("match_id", id).Msg("MatchRepo: Failed to join PvP match")
+		return fmt.Errorf("failed to join pvp match: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotActive
+	}
+	return nil
+}
+
+// UpdatePvPSession stores the move list and restarts the turn clock,
+// failing with ErrStaleSession if another move was applied since prevCount
+// was read.
+func (r *MatchRepo) UpdatePvPSession(session *domain.PvPSession, prevCount int) error {
+	res, err := r.db.Exec(`
+		UPDATE pvp_matches SET moves = ?, move_count = ?, turn_started_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND move_count = ?
+	`, session.Moves, session.MoveCount, session.TurnStartedAt, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("match_id", session.ID).Msg("MatchRepo: Failed to update PvP match")
+		return fmt.Errorf("failed to update pvp match: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+	return nil
+}
+
+// FinishPvPSession closes the match and records each player's result in one
+// transaction.
+func (r *MatchRepo) FinishPvPSession(session *domain.PvPSession, prevCount int, results []*domain.GameResult) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE pvp_matches
+		SET status = 'finished', moves = ?, move_count = ?, winner = NULLIF(?, ''), end_reason = ?,
+		    updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND move_count = ?
+	`, session.Moves, session.MoveCount, session.Winner, session.EndReason, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("match_id", session.ID).Msg("MatchRepo: Failed to finish PvP match")
+		return fmt.Errorf("failed to finish pvp match: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+
+	for _, result := range results {
+		if err := insertResult(tx, result); err != nil {
+			return err
+		}
 	}
-	return leaderboard, nil
+
+	return tx.Commit()
 }
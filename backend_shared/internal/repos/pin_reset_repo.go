@@ -0,0 +1,81 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+type PinResetRepo struct {
+	db *sql.DB
+}
+
+func NewPinResetRepo(db *sql.DB) *PinResetRepo {
+	return &PinResetRepo{db: db}
+}
+
+const pinResetSelect = `SELECT id, issued_by, expires_at, used_at, created_at FROM pin_reset_codes`
+
+func scanPinResetCode(row interface{ Scan(...any) error }) (*domain.PinResetCode, error) {
+	var c domain.PinResetCode
+	var usedAt sql.NullTime
+	if err := row.Scan(&c.ID, &c.IssuedBy, &c.ExpiresAt, &usedAt, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		c.UsedAt = &usedAt.Time
+	}
+	return &c, nil
+}
+
+// Create stores a reset code for userID and expires any the user still
+// had, so only the newest code works. audit, if set, is written in the
+// same transaction.
+func (r *PinResetRepo) Create(userID, codeHash, issuedBy string, expiresAt time.Time, audit *domain.AuditEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`UPDATE pin_reset_codes SET expires_at = ? WHERE user_id = ? AND used_at IS NULL AND expires_at > ?`, now, userID, now)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("PinResetRepo: Failed to expire old codes")
+		return fmt.Errorf("failed to expire old reset codes: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO pin_reset_codes (id, user_id, code_hash, issued_by, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), userID, codeHash, issuedBy, expiresAt.UTC(), now)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("PinResetRepo: Failed to create code")
+		return fmt.Errorf("failed to create reset code: %w", err)
+	}
+	if err := insertAudit(tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Use marks userID's live code with codeHash as used. It returns
+// sql.ErrNoRows if there is no such code, or it expired or was used.
+func (r *PinResetRepo) Use(userID, codeHash string) error {
+	now := time.Now().UTC()
+	res, err := r.db.Exec(`
+		UPDATE pin_reset_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL AND expires_at > ?
+	`, now, userID, codeHash, now)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("PinResetRepo: Failed to use code")
+		return fmt.Errorf("failed to use reset code: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package repos
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrDailyPlayed is returned when creating a daily challenge session for a
+// user who already has one for that day.
+var ErrDailyPlayed = errors.New("daily challenge already attempted")
+
+// isDailyConflict tells whether err is a session insert hitting the one
+// daily challenge per user and day index.
+func isDailyConflict(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed") && strings.Contains(err.Error(), "daily_date")
+}
+
+// ResultRepo reads the results store shared by every game. Results are
+// written by each game's repo, in the same transaction that closes the
+// session they came from.
+type ResultRepo struct {
+	db *sql.DB
+}
+
+func NewResultRepo(db *sql.DB) *ResultRepo {
+	return &ResultRepo{db: db}
+}
+
+// insertResult stores result as part of tx, filling in its ID if unset.
+func insertResult(tx *sql.Tx, result *domain.GameResult) error {
+	if result.ID == "" {
+		result.ID = uuid.New().String()
+	}
+	details := result.Details
+	if len(details) == 0 {
+		details = []byte("{}")
+	}
+	_, err := tx.Exec(`
+		INSERT INTO game_results (id, game, variant, user_id, session_id, opponent_id, score, tie_break, ranked, details)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?)
+	`, result.ID, result.Game, result.Variant, result.UserID, result.SessionID, result.OpponentID,
+		result.Score, result.TieBreak, result.Ranked, string(details))
+	if err != nil {
+		log.Error().Err(err).Str("game", result.Game).Str("user_id", result.UserID).Msg("ResultRepo: Failed to save result")
+		return fmt.Errorf("failed to save %s result: %w", result.Game, err)
+	}
+	return nil
+}
+
+const resultSelect = `
+	SELECT r.id, r.game, r.variant, r.user_id, u.username, COALESCE(r.session_id, ''), COALESCE(r.opponent_id, ''),
+	       r.score, r.tie_break, r.ranked, r.details, r.created_at
+	FROM game_results r
+	JOIN users u ON u.id = r.user_id
+`
+
+func scanResult(row interface{ Scan(...any) error }) (*domain.GameResult, error) {
+	var r domain.GameResult
+	var details string
+	err := row.Scan(&r.ID, &r.Game, &r.Variant, &r.UserID, &r.Username, &r.SessionID, &r.OpponentID,
+		&r.Score, &r.TieBreak, &r.Ranked, &details, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.Details = []byte(details)
+	return &r, nil
+}
+
+// ListByUser returns a user's results for one game, newest first, ranked
+// or not.
+func (r *ResultRepo) ListByUser(game, userID string, limit int) ([]*domain.GameResult, error) {
+	rows, err := r.db.Query(resultSelect+`
+		WHERE r.game = ? AND r.user_id = ?
+		ORDER BY r.created_at DESC, r.id
+		LIMIT ?
+	`, game, userID, limit)
+	if err != nil {
+		log.Error().Err(err).Str("game", game).Str("user_id", userID).Msg("ResultRepo: Failed to list results")
+		return nil, fmt.Errorf("failed to list %s results: %w", game, err)
+	}
+	defer rows.Close()
+
+	results := []*domain.GameResult{}
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("ResultRepo: Failed to scan result row")
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// UserVariants lists the variants starting with prefix that a user has
+// results under for one game, ranked or not, in descending order.
+func (r *ResultRepo) UserVariants(game, userID, prefix string) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT variant FROM game_results
+		WHERE game = ? AND user_id = ? AND substr(variant, 1, length(?)) = ?
+		ORDER BY variant DESC
+	`, game, userID, prefix, prefix)
+	if err != nil {
+		log.Error().Err(err).Str("game", game).Str("user_id", userID).Msg("ResultRepo: Failed to list user variants")
+		return nil, fmt.Errorf("failed to list %s variants: %w", game, err)
+	}
+	defer rows.Close()
+
+	var variants []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// BoardQuery selects a leaderboard: one game and variant, ranked as
+// Ranking says, counting results from Since on and before Until. Either
+// bound may be zero to leave that side open.
+type BoardQuery struct {
+	Game    string
+	Variant string
+	Ranking domain.Ranking
+	Since   time.Time
+	Until   time.Time
+}
+
+// BoardKey is a line's position in board order, used to page through a
+// board without skipping or repeating players as it changes.
+type BoardKey struct {
+	Score    int    `json:"s"`
+	TieBreak int    `json:"t"`
+	At       string `json:"a"`
+	UserID   string `json:"u"`
+}
+
+// boardTime is how created_at is compared and kept in keys.
+const boardTime = "2006-01-02 15:04:05"
+
+// Leaderboard returns up to limit lines of q's board after the line at
+// after (from the top if nil), and the key to continue from if there are
+// more. Each player appears once, with their best result or their total.
+// Guests are left out.
+func (r *ResultRepo) Leaderboard(q BoardQuery, after *BoardKey, limit int) ([]domain.LeaderboardEntry, *BoardKey, error) {
+	board, args, err := boardSQL(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	order, _ := orderBy(q.Ranking)
+
+	query := board + ` SELECT ` + boardColumns + ` FROM ranked`
+	if after != nil {
+		cond, condArgs := order.after(after)
+		query += ` WHERE ` + cond
+		args = append(args, condArgs...)
+	}
+	query += ` ORDER BY ` + order.board() + ` LIMIT ?`
+	args = append(args, limit+1)
+
+	entries, keys, err := r.queryBoard(q, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) <= limit {
+		return entries, nil, nil
+	}
+	return entries[:limit], &keys[limit-1], nil
+}
+
+// PlayerRank returns userID's line on q's board with up to neighbors lines
+// either side of it, or sql.ErrNoRows if they have no line on it.
+func (r *ResultRepo) PlayerRank(q BoardQuery, userID string, neighbors int) (*domain.PlayerRank, error) {
+	board, args, err := boardSQL(q)
+	if err != nil {
+		return nil, err
+	}
+	order, _ := orderBy(q.Ranking)
+
+	entries, keys, err := r.queryBoard(q, board+` SELECT `+boardColumns+` FROM ranked WHERE user_id = ?`, append(args, userID)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	rank := &domain.PlayerRank{Rank: entries[0].Rank, Entry: entries[0]}
+
+	if err := r.db.QueryRow(board+` SELECT COUNT(*) FROM ranked`, args...).Scan(&rank.Players); err != nil {
+		log.Error().Err(err).Str("game", q.Game).Msg("ResultRepo: Failed to count leaderboard")
+		return nil, fmt.Errorf("failed to count %s leaderboard: %w", q.Game, err)
+	}
+	rank.Percentile = float64(rank.Players-rank.Rank+1) * 100 / float64(rank.Players)
+
+	cond, condArgs := order.before(&keys[0])
+	above, _, err := r.queryBoard(q, board+` SELECT `+boardColumns+` FROM ranked WHERE `+cond+` ORDER BY `+order.reversed()+` LIMIT ?`,
+		append(append(append([]any{}, args...), condArgs...), neighbors)...)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(above)-1; i < j; i, j = i+1, j-1 {
+		above[i], above[j] = above[j], above[i]
+	}
+	rank.Above = above
+
+	cond, condArgs = order.after(&keys[0])
+	rank.Below, _, err = r.queryBoard(q, board+` SELECT `+boardColumns+` FROM ranked WHERE `+cond+` ORDER BY `+order.board()+` LIMIT ?`,
+		append(append(append([]any{}, args...), condArgs...), neighbors)...)
+	if err != nil {
+		return nil, err
+	}
+	return rank, nil
+}
+
+const boardColumns = `rank, user_id, username, score, tie_break, id, details, at`
+
+// boardSQL returns the WITH clause defining q's board as "ranked": one row
+// per player with their rank, and the arguments it takes.
+func boardSQL(q BoardQuery) (string, []any, error) {
+	order, err := orderBy(q.Ranking)
+	if err != nil {
+		return "", nil, err
+	}
+
+	where := `r.game = ? AND r.variant = ? AND r.ranked = 1 AND u.is_guest = 0`
+	args := []any{q.Game, q.Variant}
+	if !q.Since.IsZero() {
+		where += ` AND r.created_at >= ?`
+		args = append(args, q.Since.UTC().Format(boardTime))
+	}
+	if !q.Until.IsZero() {
+		where += ` AND r.created_at < ?`
+		args = append(args, q.Until.UTC().Format(boardTime))
+	}
+
+	var board string
+	if q.Ranking.Aggregate == domain.AggregateTotal {
+		// Players tied on their total are ordered by who reached it first:
+		// the time of their last scoring result, as later results that
+		// score nothing don't move their total.
+		board = `
+			board AS (
+				SELECT '' AS id, r.user_id, u.username, SUM(r.score) AS score, 0 AS tie_break, '' AS details,
+				       MAX(CASE WHEN r.score > 0 THEN strftime('%Y-%m-%d %H:%M:%S', r.created_at) END) AS at
+				FROM game_results r
+				JOIN users u ON u.id = r.user_id
+				WHERE ` + where + `
+				GROUP BY r.user_id
+				HAVING SUM(r.score) > 0
+			)`
+	} else {
+		board = `
+			eligible AS (
+				SELECT r.id, r.user_id, u.username, r.score, r.tie_break, r.details,
+				       strftime('%Y-%m-%d %H:%M:%S', r.created_at) AS at,
+				       ROW_NUMBER() OVER (
+				           PARTITION BY r.user_id ORDER BY r.score ` + order.score + `, r.tie_break ` + order.tieBreak + `, r.created_at, r.id
+				       ) AS n
+				FROM game_results r
+				JOIN users u ON u.id = r.user_id
+				WHERE ` + where + `
+			),
+			board AS (
+				SELECT id, user_id, username, score, tie_break, details, at FROM eligible WHERE n = 1
+			)`
+	}
+	return `WITH ` + board + `,
+		ranked AS (
+			SELECT *, RANK() OVER (ORDER BY score ` + order.score + `, tie_break ` + order.tieBreak + `) AS rank FROM board
+		)`, args, nil
+}
+
+func (r *ResultRepo) queryBoard(q BoardQuery, query string, args ...any) ([]domain.LeaderboardEntry, []BoardKey, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Error().Err(err).Str("game", q.Game).Msg("ResultRepo: Failed to get leaderboard")
+		return nil, nil, fmt.Errorf("failed to get %s leaderboard: %w", q.Game, err)
+	}
+	defer rows.Close()
+
+	entries := []domain.LeaderboardEntry{}
+	var keys []BoardKey
+	for rows.Next() {
+		var e domain.LeaderboardEntry
+		var details, at string
+		if err := rows.Scan(&e.Rank, &e.UserID, &e.Username, &e.Score, &e.TieBreak, &e.ResultID, &details, &at); err != nil {
+			log.Error().Err(err).Msg("ResultRepo: Failed to scan leaderboard row")
+			return nil, nil, err
+		}
+		if q.Ranking.Aggregate != domain.AggregateTotal {
+			e.Details = []byte(details)
+			if t, err := time.Parse(boardTime, at); err == nil {
+				e.CreatedAt = &t
+			}
+		}
+		entries = append(entries, e)
+		keys = append(keys, BoardKey{Score: e.Score, TieBreak: e.TieBreak, At: at, UserID: e.UserID})
+	}
+	return entries, keys, rows.Err()
+}
+
+type sqlOrder struct {
+	score, tieBreak string
+}
+
+// orderBy turns a ranking into ORDER BY directions. Only the two known
+// orders are accepted, as they end up in the query text.
+func orderBy(ranking domain.Ranking) (sqlOrder, error) {
+	dir := func(order string) (string, error) {
+		switch order {
+		case domain.HigherFirst:
+			return "DESC", nil
+		case domain.LowerFirst, "":
+			return "ASC", nil
+		}
+		return "", fmt.Errorf("unknown ranking order %q", order)
+	}
+	var o sqlOrder
+	var err error
+	if o.score, err = dir(ranking.Order); err != nil {
+		return o, err
+	}
+	o.tieBreak, err = dir(ranking.TieBreak)
+	return o, err
+}
+
+// board is the full board order: score, tie break, then earliest, then
+// user ID so every line has a fixed place.
+func (o sqlOrder) board() string {
+	return `score ` + o.score + `, tie_break ` + o.tieBreak + `, at, user_id`
+}
+
+func (o sqlOrder) reversed() string {
+	flip := map[string]string{"ASC": "DESC", "DESC": "ASC"}
+	return `score ` + flip[o.score] + `, tie_break ` + flip[o.tieBreak] + `, at DESC, user_id DESC`
+}
+
+// after matches the lines that come after k in board order.
+func (o sqlOrder) after(k *BoardKey) (string, []any) {
+	return o.beyond(k, false)
+}
+
+// before matches the lines that come before k in board order.
+func (o sqlOrder) before(k *BoardKey) (string, []any) {
+	return o.beyond(k, true)
+}
+
+func (o sqlOrder) beyond(k *BoardKey, before bool) (string, []any) {
+	op := func(dir string) string {
+		if (dir == "DESC") != before {
+			return "<"
+		}
+		return ">"
+	}
+	return `(score ` + op(o.score) + ` ? OR (score = ? AND (tie_break ` + op(o.tieBreak) + ` ? OR (tie_break = ? AND (at ` + op("ASC") + ` ? OR (at = ? AND user_id ` + op("ASC") + ` ?))))))`,
+		[]any{k.Score, k.Score, k.TieBreak, k.TieBreak, k.At, k.At, k.UserID}
+}
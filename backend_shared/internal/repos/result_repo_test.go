@@ -0,0 +1,158 @@
+package repos
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ramanasai/local-game-play/internal/db"
+	"github.com/ramanasai/local-game-play/internal/db/dbtest"
+	"github.com/ramanasai/local-game-play/internal/domain"
+)
+
+// TestBoardCursors checks that, from every line of a board full of ties,
+// after and before match exactly the lines board order puts after and
+// before it, for every ranking direction.
+func TestBoardCursors(t *testing.T) {
+	database, err := db.Connect(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	lines := []BoardKey{
+		{10, 5, "2026-01-01 10:00:00", "a"},
+		{10, 5, "2026-01-01 10:00:00", "b"},
+		{10, 5, "2026-01-02 09:00:00", "c"},
+		{10, 7, "2026-01-01 10:00:00", "d"},
+		{10, 3, "2026-01-03 08:00:00", "e"},
+		{20, 0, "2026-01-01 10:00:00", "f"},
+		{5, 9, "2025-12-31 23:59:59", "g"},
+		{20, 0, "2025-12-31 00:00:00", "h"},
+	}
+	if _, err := database.Exec(`CREATE TABLE ranked (score INTEGER, tie_break INTEGER, at TEXT, user_id TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lines {
+		if _, err := database.Exec(`INSERT INTO ranked VALUES (?, ?, ?, ?)`, l.Score, l.TieBreak, l.At, l.UserID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(cond, order string, args ...any) []string {
+		t.Helper()
+		where := ""
+		if cond != "" {
+			where = ` WHERE ` + cond
+		}
+		rows, err := database.Query(`SELECT user_id FROM ranked`+where+` ORDER BY `+order, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		ranking domain.Ranking
+		want    []string
+	}{
+		{domain.Ranking{Order: domain.HigherFirst, TieBreak: domain.HigherFirst}, []string{"h", "f", "d", "a", "b", "c", "e", "g"}},
+		{domain.Ranking{Order: domain.HigherFirst, TieBreak: domain.LowerFirst}, []string{"h", "f", "e", "a", "b", "c", "d", "g"}},
+		{domain.Ranking{Order: domain.LowerFirst, TieBreak: domain.HigherFirst}, []string{"g", "d", "a", "b", "c", "e", "h", "f"}},
+		{domain.Ranking{Order: domain.LowerFirst, TieBreak: domain.LowerFirst}, []string{"g", "e", "a", "b", "c", "d", "h", "f"}},
+	}
+	for _, tt := range tests {
+		order, err := orderBy(tt.ranking)
+		if err != nil {
+			t.Fatal(err)
+		}
+		board := query("", order.board())
+		if !slices.Equal(board, tt.want) {
+			t.Fatalf("%+v: board %v, want %v", tt.ranking, board, tt.want)
+		}
+		reversed := slices.Clone(board)
+		slices.Reverse(reversed)
+		if got := query("", order.reversed()); !slices.Equal(got, reversed) {
+			t.Errorf("%+v: reversed %v, want %v", tt.ranking, got, reversed)
+		}
+
+		for i, id := range board {
+			k := &lines[slices.IndexFunc(lines, func(l BoardKey) bool { return l.UserID == id })]
+			cond, args := order.after(k)
+			if got := query(cond, order.board(), args...); !slices.Equal(got, board[i+1:]) {
+				t.Errorf("%+v: after %s = %v, want %v", tt.ranking, id, got, board[i+1:])
+			}
+			cond, args = order.before(k)
+			if got := query(cond, order.reversed(), args...); !slices.Equal(got, reversed[len(board)-i:]) {
+				t.Errorf("%+v: before %s = %v, want %v", tt.ranking, id, got, reversed[len(board)-i:])
+			}
+		}
+	}
+}
+
+func TestOrderByRejectsUnknownOrders(t *testing.T) {
+	for _, ranking := range []domain.Ranking{
+		{Order: "sideways"},
+		{Order: domain.HigherFirst, TieBreak: "DESC; DROP TABLE users"},
+	} {
+		if _, err := orderBy(ranking); err == nil {
+			t.Errorf("orderBy(%+v) accepted", ranking)
+		}
+	}
+}
+
+// TestTotalBoardTieOrder checks that players tied on a total are ordered
+// by when they reached it, not by when they last played.
+func TestTotalBoardTieOrder(t *testing.T) {
+	database := dbtest.Open(t)
+	users := NewUserRepo(database)
+	results := NewResultRepo(database)
+
+	ids := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		u, err := users.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = u.ID
+	}
+	played := []struct {
+		user  string
+		score int
+		at    string
+	}{
+		{"alice", 1, "2026-01-01 10:00:00"},
+		{"bob", 1, "2026-01-02 10:00:00"},
+		{"alice", 0, "2026-01-03 10:00:00"},
+	}
+	for i, p := range played {
+		_, err := database.Exec(`INSERT INTO game_results (id, game, variant, user_id, score, ranked, created_at) VALUES (?, 'tictactoe', '', ?, ?, 1, ?)`,
+			fmt.Sprint(i), ids[p.user], p.score, p.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := BoardQuery{Game: "tictactoe", Ranking: domain.Ranking{Order: domain.HigherFirst, Aggregate: domain.AggregateTotal}}
+	entries, _, err := results.Leaderboard(q, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Username)
+	}
+	if want := []string{"alice", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("board %v, want %v", got, want)
+	}
+}
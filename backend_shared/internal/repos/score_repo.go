@@ -2,6 +2,7 @@ package repos
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ramanasai/local-game-play/internal/domain"
@@ -16,40 +17,117 @@ func NewScoreRepo(d *sql.DB) *ScoreRepo {
 	return &ScoreRepo{DB: d}
 }
 
-func (r *ScoreRepo) Create(userID string, moves, timeSeconds int) error {
-	id := uuid.New().String()
-	query := `INSERT INTO scores (id, user_id, moves, time_seconds) VALUES (?, ?, ?, ?)`
-	_, err := r.DB.Exec(query, id, userID, moves, timeSeconds)
+// CreateSession stores a new Memory session. The start time is set here
+// rather than by SQLite so elapsed time keeps sub-second precision. A
+// dailyDate makes it that day's challenge, which fails with ErrDailyPlayed
+// if the user already started it.
+func (r *ScoreRepo) CreateSession(userID string, seed uint32, pairs int, dailyDate string) (*domain.MemorySession, error) {
+	session := &domain.MemorySession{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Seed:      seed,
+		Pairs:     pairs,
+		DailyDate: dailyDate,
+		Status:    "active",
+		Flips:     "[]",
+		CreatedAt: time.Now().UTC(),
+	}
+	query := `INSERT INTO memory_sessions (id, user_id, seed, pairs, daily_date, created_at) VALUES (?, ?, ?, ?, NULLIF(?, ''), ?)`
+	_, err := r.DB.Exec(query, session.ID, userID, int64(seed), pairs, dailyDate, session.CreatedAt)
 	if err != nil {
-		log.Error().Err(err).Str("user_id", userID).Int("moves", moves).Msg("ScoreRepo: Failed to create memory score")
-		return err
+		if isDailyConflict(err) {
+			return nil, ErrDailyPlayed
+		}
+		log.Error().Err(err).Str("user_id", userID).Msg("ScoreRepo: Failed to create memory session")
+		return nil, err
 	}
-	return nil
+	return session, nil
 }
 
-func (r *ScoreRepo) GetLeaderboard(limit int) ([]domain.Score, error) {
-	query := `
-		SELECT s.id, s.user_id, s.moves, s.time_seconds, s.created_at, u.username
-		FROM scores s
-		JOIN users u ON s.user_id = u.id
-		ORDER BY s.moves ASC, s.time_seconds ASC
-		LIMIT ?
-	`
-	rows, err := r.DB.Query(query, limit)
+const memorySessionSelect = `
+	SELECT id, user_id, seed, pairs, COALESCE(daily_date, ''), status, flips, flip_count, suspicious, created_at, finished_at
+	FROM memory_sessions
+`
+
+func scanMemorySession(row interface{ Scan(...any) error }) (*domain.MemorySession, error) {
+	var s domain.MemorySession
+	var seed int64
+	var finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &seed, &s.Pairs, &s.DailyDate, &s.Status, &s.Flips, &s.FlipCount, &s.Suspicious, &s.CreatedAt, &finishedAt)
 	if err != nil {
-		log.Error().Err(err).Msg("ScoreRepo: Failed to query leaderboard")
 		return nil, err
 	}
-	defer rows.Close()
+	s.Seed = uint32(seed)
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+	return &s, nil
+}
 
-	var scores []domain.Score
-	for rows.Next() {
-		var s domain.Score
-		if err := rows.Scan(&s.ID, &s.UserID, &s.Moves, &s.TimeSeconds, &s.CreatedAt, &s.Username); err != nil {
-			log.Error().Err(err).Msg("ScoreRepo: Failed to scan score row")
-			return nil, err
+func (r *ScoreRepo) GetSession(id string) (*domain.MemorySession, error) {
+	s, err := scanMemorySession(r.DB.QueryRow(memorySessionSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("session_id", id).Msg("ScoreRepo: Failed to get memory session")
 		}
-		scores = append(scores, s)
+		return nil, err
+	}
+	return s, nil
+}
+
+// HasDailySession tells whether the user started the challenge of date.
+func (r *ScoreRepo) HasDailySession(userID, date string) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM memory_sessions WHERE user_id = ? AND daily_date = ?)`, userID, date).Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("ScoreRepo: Failed to look up daily session")
+		return false, err
 	}
-	return scores, nil
+	return exists, nil
+}
+
+// UpdateSessionFlips stores the flip log, failing with ErrStaleSession if
+// another flip was recorded since prevCount was read.
+func (r *ScoreRepo) UpdateSessionFlips(session *domain.MemorySession, prevCount int) error {
+	res, err := r.DB.Exec(`
+		UPDATE memory_sessions SET flips = ?, flip_count = ?
+		WHERE id = ? AND status = 'active' AND flip_count = ?
+	`, session.Flips, session.FlipCount, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("ScoreRepo: Failed to update memory session")
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+	return nil
+}
+
+// FinishSession closes a completed session and records its result in the
+// same transaction.
+func (r *ScoreRepo) FinishSession(session *domain.MemorySession, prevCount int, result *domain.GameResult) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE memory_sessions
+		SET status = 'finished', flips = ?, flip_count = ?, suspicious = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active' AND flip_count = ?
+	`, session.Flips, session.FlipCount, session.Suspicious, session.ID, prevCount)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", session.ID).Msg("ScoreRepo: Failed to finish memory session")
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStaleSession
+	}
+
+	if err := insertResult(tx, result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
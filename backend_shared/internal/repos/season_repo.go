@@ -0,0 +1,218 @@
+package repos
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrSeasonArchived = errors.New("season already archived")
+
+type SeasonRepo struct {
+	db *sql.DB
+}
+
+func NewSeasonRepo(db *sql.DB) *SeasonRepo {
+	return &SeasonRepo{db: db}
+}
+
+const seasonSelect = `SELECT id, name, starts_at, ends_at, archived_at FROM seasons`
+
+func scanSeason(row interface{ Scan(...any) error }) (*domain.Season, error) {
+	var s domain.Season
+	var archivedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.Name, &s.StartsAt, &s.EndsAt, &archivedAt); err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		s.ArchivedAt = &archivedAt.Time
+	}
+	return &s, nil
+}
+
+// List returns every season, earliest first.
+func (r *SeasonRepo) List() ([]*domain.Season, error) {
+	rows, err := r.db.Query(seasonSelect + ` ORDER BY starts_at`)
+	if err != nil {
+		log.Error().Err(err).Msg("SeasonRepo: Failed to list seasons")
+		return nil, fmt.Errorf("failed to list seasons: %w", err)
+	}
+	defer rows.Close()
+
+	seasons := []*domain.Season{}
+	for rows.Next() {
+		s, err := scanSeason(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("SeasonRepo: Failed to scan season row")
+			return nil, err
+		}
+		seasons = append(seasons, s)
+	}
+	return seasons, rows.Err()
+}
+
+func (r *SeasonRepo) Get(id string) (*domain.Season, error) {
+	s, err := scanSeason(r.db.QueryRow(seasonSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("season_id", id).Msg("SeasonRepo: Failed to get season")
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *SeasonRepo) Create(season *domain.Season) error {
+	season.ID = uuid.New().String()
+	_, err := r.db.Exec(`INSERT INTO seasons (id, name, starts_at, ends_at) VALUES (?, ?, ?, ?)`,
+		season.ID, season.Name, season.StartsAt.UTC().Format(boardTime), season.EndsAt.UTC().Format(boardTime))
+	if err != nil {
+		log.Error().Err(err).Str("season", season.Name).Msg("SeasonRepo: Failed to create season")
+		return fmt.Errorf("failed to create season: %w", err)
+	}
+	return nil
+}
+
+// UpdateDates moves a season that hasn't been archived yet.
+func (r *SeasonRepo) UpdateDates(season *domain.Season) error {
+	res, err := r.db.Exec(`UPDATE seasons SET starts_at = ?, ends_at = ? WHERE id = ? AND archived_at IS NULL`,
+		season.StartsAt.UTC().Format(boardTime), season.EndsAt.UTC().Format(boardTime), season.ID)
+	if err != nil {
+		log.Error().Err(err).Str("season_id", season.ID).Msg("SeasonRepo: Failed to update season")
+		return fmt.Errorf("failed to update season: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a season that hasn't been archived yet.
+func (r *SeasonRepo) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM seasons WHERE id = ? AND archived_at IS NULL`, id)
+	if err != nil {
+		log.Error().Err(err).Str("season_id", id).Msg("SeasonRepo: Failed to delete season")
+		return fmt.Errorf("failed to delete season: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Variants lists the variants of game that have ranked results between
+// since and until.
+func (r *SeasonRepo) Variants(game string, since, until time.Time) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT variant FROM game_results
+		WHERE game = ? AND ranked = 1 AND created_at >= ? AND created_at < ?
+		ORDER BY variant
+	`, game, since.UTC().Format(boardTime), until.UTC().Format(boardTime))
+	if err != nil {
+		log.Error().Err(err).Str("game", game).Msg("SeasonRepo: Failed to list variants")
+		return nil, fmt.Errorf("failed to list %s variants: %w", game, err)
+	}
+	defer rows.Close()
+
+	var variants []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// Archive stores the final standings of every board in boards and marks
+// the season archived, all in one transaction. It fails with
+// ErrSeasonArchived if the season was archived already.
+func (r *SeasonRepo) Archive(seasonID string, boards []BoardQuery) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE seasons SET archived_at = ? WHERE id = ? AND archived_at IS NULL`,
+		time.Now().UTC().Format(boardTime), seasonID)
+	if err != nil {
+		log.Error().Err(err).Str("season_id", seasonID).Msg("SeasonRepo: Failed to archive season")
+		return fmt.Errorf("failed to archive season: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSeasonArchived
+	}
+
+	for _, q := range boards {
+		board, args, err := boardSQL(q)
+		if err != nil {
+			return err
+		}
+		order, _ := orderBy(q.Ranking)
+		_, err = tx.Exec(board+`
+			INSERT INTO season_standings (season_id, game, variant, position, rank, user_id, username, score, tie_break, result_id, details)
+			SELECT ?, ?, ?, ROW_NUMBER() OVER (ORDER BY `+order.board()+`), rank, user_id, username, score, tie_break,
+			       NULLIF(id, ''), CASE WHEN details = '' THEN '{}' ELSE details END
+			FROM ranked
+		`, append(args, seasonID, q.Game, q.Variant)...)
+		if err != nil {
+			log.Error().Err(err).Str("season_id", seasonID).Str("game", q.Game).Msg("SeasonRepo: Failed to archive standings")
+			return fmt.Errorf("failed to archive %s standings: %w", q.Game, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+const standingSelect = `
+	SELECT season_id, game, variant, rank, user_id, username, score, tie_break, COALESCE(result_id, ''), details
+	FROM season_standings
+`
+
+func scanStanding(row interface{ Scan(...any) error }) (*domain.Standing, error) {
+	var s domain.Standing
+	var details string
+	err := row.Scan(&s.SeasonID, &s.Game, &s.Variant, &s.Rank, &s.UserID, &s.Username, &s.Score, &s.TieBreak, &s.ResultID, &details)
+	if err != nil {
+		return nil, err
+	}
+	if details != "{}" {
+		s.Details = []byte(details)
+	}
+	return &s, nil
+}
+
+// Standings returns the top limit lines of each archived board of a
+// season, only game's if it is set.
+func (r *SeasonRepo) Standings(seasonID, game string, limit int) ([]domain.SeasonBoard, error) {
+	rows, err := r.db.Query(standingSelect+`
+		WHERE season_id = ? AND (? = '' OR game = ?) AND position <= ?
+		ORDER BY game, variant, position
+	`, seasonID, game, game, limit)
+	if err != nil {
+		log.Error().Err(err).Str("season_id", seasonID).Msg("SeasonRepo: Failed to get standings")
+		return nil, fmt.Errorf("failed to get season standings: %w", err)
+	}
+	defer rows.Close()
+
+	boards := []domain.SeasonBoard{}
+	for rows.Next() {
+		s, err := scanStanding(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("SeasonRepo: Failed to scan standing row")
+			return nil, err
+		}
+		if n := len(boards); n == 0 || boards[n-1].Game != s.Game || boards[n-1].Variant != s.Variant {
+			boards = append(boards, domain.SeasonBoard{Game: s.Game, Variant: s.Variant})
+		}
+		boards[len(boards)-1].Entries = append(boards[len(boards)-1].Entries, s.LeaderboardEntry)
+	}
+	return boards, rows.Err()
+}
@@ -2,12 +2,19 @@ package repos
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ramanasai/local-game-play/internal/domain"
 	"github.com/rs/zerolog/log"
 )
 
+// ErrTokenMismatch is returned by Rotate when the session's current token
+// is not the one presented, or the session is no longer usable.
+var ErrTokenMismatch = errors.New("refresh token does not match session")
+
 type SessionRepo struct {
 	DB *sql.DB
 }
@@ -16,36 +23,169 @@ func NewSessionRepo(d *sql.DB) *SessionRepo {
 	return &SessionRepo{DB: d}
 }
 
-func (r *SessionRepo) Create(userID string) (*domain.Session, error) {
-	id := uuid.New().String()
-	token := uuid.New().String()
-
+// Create starts a session whose current refresh token hashes to tokenHash,
+// remembering the device it was created from.
+func (r *SessionRepo) Create(userID, tokenHash string, expiresAt time.Time, ip, userAgent string) (*domain.Session, error) {
+	now := time.Now().UTC()
 	session := &domain.Session{
-		ID:     id,
-		Token:  token,
-		UserID: userID,
+		ID:         uuid.New().String(),
+		Token:      tokenHash,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: &now,
+		ExpiresAt:  expiresAt.UTC(),
 	}
 
-	query := `INSERT INTO sessions (id, token, user_id) VALUES (?, ?, ?)`
-	_, err := r.DB.Exec(query, id, token, userID)
+	query := `INSERT INTO sessions (id, token, user_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.DB.Exec(query, session.ID, tokenHash, userID, userAgent, ip, now, now, session.ExpiresAt)
 	if err != nil {
 		log.Error().Err(err).Str("user_id", userID).Msg("SessionRepo: Failed to create session")
-		return nil, err
+		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 	return session, nil
 }
 
-func (r *SessionRepo) GetByToken(token string) (*domain.Session, error) {
-	query := `SELECT id, token, user_id, created_at FROM sessions WHERE token = ?`
-	row := r.DB.QueryRow(query, token)
+const sessionSelect = `
+	SELECT id, token, user_id, user_agent, ip, created_at, last_seen_at, expires_at, rotated_at, revoked_at, COALESCE(revoke_reason, '')
+	FROM sessions
+`
 
+func scanSession(row interface{ Scan(...any) error }) (*domain.Session, error) {
 	var session domain.Session
-	err := row.Scan(&session.ID, &session.Token, &session.UserID, &session.CreatedAt)
+	var lastSeenAt, expiresAt, rotatedAt, revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.Token, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt,
+		&lastSeenAt, &expiresAt, &rotatedAt, &revokedAt, &session.RevokeReason)
+	if err != nil {
+		return nil, err
+	}
+	session.ExpiresAt = expiresAt.Time
+	if lastSeenAt.Valid {
+		session.LastSeenAt = &lastSeenAt.Time
+	}
+	if rotatedAt.Valid {
+		session.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+func (r *SessionRepo) GetByID(id string) (*domain.Session, error) {
+	session, err := scanSession(r.DB.QueryRow(sessionSelect+` WHERE id = ?`, id))
 	if err != nil {
 		if err != sql.ErrNoRows {
-			log.Error().Err(err).Msg("SessionRepo: Failed to get session by token")
+			log.Error().Err(err).Str("session_id", id).Msg("SessionRepo: Failed to get session")
 		}
 		return nil, err
 	}
-	return &session, nil
+	return session, nil
+}
+
+// ListActiveByUser returns the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func (r *SessionRepo) ListActiveByUser(userID string) ([]*domain.Session, error) {
+	rows, err := r.DB.Query(sessionSelect+`
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY COALESCE(last_seen_at, created_at) DESC
+	`, userID, time.Now().UTC())
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("SessionRepo: Failed to list sessions")
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("SessionRepo: Failed to scan session row")
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch records that the session was just used from ip with userAgent.
+func (r *SessionRepo) Touch(id, ip, userAgent string) error {
+	_, err := r.DB.Exec(`UPDATE sessions SET last_seen_at = ?, ip = ?, user_agent = ? WHERE id = ?`,
+		time.Now().UTC(), ip, userAgent, id)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", id).Msg("SessionRepo: Failed to touch session")
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// Rotate swaps the session's refresh token from oldHash to newHash and
+// extends it to expiresAt. Only one caller can win a given oldHash; the
+// rest get ErrTokenMismatch.
+func (r *SessionRepo) Rotate(id, oldHash, newHash string, expiresAt time.Time) error {
+	res, err := r.DB.Exec(`
+		UPDATE sessions SET token = ?, expires_at = ?, rotated_at = ?
+		WHERE id = ? AND token = ? AND revoked_at IS NULL
+	`, newHash, expiresAt.UTC(), time.Now().UTC(), id, oldHash)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", id).Msg("SessionRepo: Failed to rotate session")
+		return fmt.Errorf("failed to rotate session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenMismatch
+	}
+	return nil
+}
+
+// Revoke ends a session. Revoking one that is already revoked keeps the
+// original time and reason.
+func (r *SessionRepo) Revoke(id, reason string) error {
+	_, err := r.DB.Exec(`
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), reason, id)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", id).Msg("SessionRepo: Failed to revoke session")
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeForUser revokes one of userID's sessions, returning sql.ErrNoRows
+// if it doesn't exist, belongs to someone else or is already revoked.
+func (r *SessionRepo) RevokeForUser(id, userID, reason string) error {
+	res, err := r.DB.Exec(`
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), reason, id, userID)
+	if err != nil {
+		log.Error().Err(err).Str("session_id", id).Msg("SessionRepo: Failed to revoke session")
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOthers revokes every live session of userID except keepID and
+// returns how many it revoked.
+func (r *SessionRepo) RevokeOthers(userID, keepID, reason string) (int, error) {
+	res, err := r.DB.Exec(`
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE user_id = ? AND id != ? AND revoked_at IS NULL
+	`, time.Now().UTC(), reason, userID, keepID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("SessionRepo: Failed to revoke other sessions")
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// RevokeAll revokes every live session of userID, logging them out
+// everywhere, and returns how many it revoked.
+func (r *SessionRepo) RevokeAll(userID, reason string) (int, error) {
+	return r.RevokeOthers(userID, "", reason)
 }
@@ -2,12 +2,21 @@ package repos
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ramanasai/local-game-play/internal/domain"
+	"github.com/ramanasai/local-game-play/internal/usernames"
 	"github.com/rs/zerolog/log"
 )
 
+// ErrUsernameTaken is returned when a username has the same key as
+// another user's.
+var ErrUsernameTaken = errors.New("username is already taken")
+
 type UserRepo struct {
 	DB *sql.DB
 }
@@ -21,55 +30,211 @@ func (r *UserRepo) Create(username string) (*domain.User, error) {
 	user := &domain.User{
 		ID:       id,
 		Username: username,
+		Role:     domain.RolePlayer,
 	}
 
-	query := `INSERT INTO users (id, username) VALUES (?, ?)`
-	_, err := r.DB.Exec(query, id, username)
+	query := `INSERT INTO users (id, username, username_key) VALUES (?, ?, ?)`
+	_, err := r.DB.Exec(query, id, username, usernames.Key(username))
 	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, ErrUsernameTaken
+		}
 		log.Error().Err(err).Str("username", username).Msg("UserRepo: Failed to create user")
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *UserRepo) GetByUsername(username string) (*domain.User, error) {
-	query := `SELECT id, username, pin_hash, hint, created_at FROM users WHERE username = ?`
-	row := r.DB.QueryRow(query, username)
+// CreateGuest creates an anonymous user under a generated username.
+func (r *UserRepo) CreateGuest(username string) (*domain.User, error) {
+	user := &domain.User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Role:      domain.RolePlayer,
+		Guest:     true,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err := r.DB.Exec(`INSERT INTO users (id, username, username_key, is_guest, created_at) VALUES (?, ?, ?, 1, ?)`,
+		user.ID, username, usernames.Key(username), user.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Str("username", username).Msg("UserRepo: Failed to create guest")
+		return nil, err
+	}
+	return user, nil
+}
+
+// ClaimGuest turns a guest into a regular account with the given username
+// and PIN, keeping its ID and so all of its history.
+func (r *UserRepo) ClaimGuest(guestID, username, pinHash, hint string) error {
+	res, err := r.DB.Exec(`
+		UPDATE users SET username = ?, username_key = ?, pin_hash = ?, hint = NULLIF(?, ''), is_guest = 0
+		WHERE id = ? AND is_guest = 1
+	`, username, usernames.Key(username), pinHash, hint, guestID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUsernameTaken
+		}
+		log.Error().Err(err).Str("user_id", guestID).Msg("UserRepo: Failed to claim guest")
+		return fmt.Errorf("failed to claim guest: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MergeGuest moves everything a guest played into targetID's account and
+// removes the guest, all in one transaction. PvP games between the guest
+// and targetID itself are dropped, as a player can't have played
+// themselves.
+func (r *UserRepo) MergeGuest(guestID, targetID string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var isGuest bool
+	if err := tx.QueryRow(`SELECT is_guest FROM users WHERE id = ?`, guestID).Scan(&isGuest); err != nil {
+		return err
+	}
+	if !isGuest {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM game_results WHERE game = 'tictactoe' AND session_id IN (
+			SELECT id FROM pvp_matches WHERE (x_user_id = ? AND o_user_id = ?) OR (x_user_id = ? AND o_user_id = ?)
+		)
+	`, guestID, targetID, targetID, guestID)
+	if err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM pvp_matches WHERE (x_user_id = ? AND o_user_id = ?) OR (x_user_id = ? AND o_user_id = ?)`,
+		guestID, targetID, targetID, guestID)
+	if err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
+
+	// Both may have played the same daily challenge, the guest from before
+	// guests were kept out of it. The target keeps their own attempt: the
+	// guest's stops counting as one and its result is kept unranked, or it
+	// would be a second go at the day's board.
+	dailies := []struct{ game, table string }{
+		{"memory", "memory_sessions"}, {"2048", "game2048_sessions"}, {"blockblast", "blockblast_sessions"},
+	}
+	for _, d := range dailies {
+		_, err = tx.Exec(`
+			UPDATE game_results SET ranked = 0
+			WHERE game = ? AND session_id IN (
+				SELECT id FROM `+d.table+` WHERE user_id = ? AND daily_date IN (SELECT daily_date FROM `+d.table+` WHERE user_id = ?)
+			)
+		`, d.game, guestID, targetID)
+		if err != nil {
+			return fmt.Errorf("failed to merge guest: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE `+d.table+` SET daily_date = NULL
+			WHERE user_id = ? AND daily_date IN (SELECT daily_date FROM `+d.table+` WHERE user_id = ?)
+		`, guestID, targetID)
+		if err != nil {
+			return fmt.Errorf("failed to merge guest: %w", err)
+		}
+	}
+
+	// Achievements both have keep the earlier unlock.
+	_, err = tx.Exec(`
+		INSERT INTO user_achievements (user_id, achievement_id, unlocked_at)
+		SELECT ?, achievement_id, unlocked_at FROM user_achievements WHERE user_id = ?
+		ON CONFLICT (user_id, achievement_id) DO UPDATE SET unlocked_at = MIN(unlocked_at, excluded.unlocked_at)
+	`, targetID, guestID)
+	if err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_achievements WHERE user_id = ?`, guestID); err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
 
+	statements := []string{
+		`UPDATE game_results SET user_id = ? WHERE user_id = ?`,
+		`UPDATE game_results SET opponent_id = ? WHERE opponent_id = ?`,
+		`UPDATE memory_sessions SET user_id = ? WHERE user_id = ?`,
+		`UPDATE game2048_sessions SET user_id = ? WHERE user_id = ?`,
+		`UPDATE blockblast_sessions SET user_id = ? WHERE user_id = ?`,
+		`UPDATE tictactoe_sessions SET user_id = ? WHERE user_id = ?`,
+		`UPDATE pvp_matches SET x_user_id = ? WHERE x_user_id = ?`,
+		`UPDATE pvp_matches SET o_user_id = ? WHERE o_user_id = ?`,
+	}
+	for _, query := range statements {
+		if _, err := tx.Exec(query, targetID, guestID); err != nil {
+			log.Error().Err(err).Str("guest_id", guestID).Str("target_id", targetID).Msg("UserRepo: Failed to merge guest")
+			return fmt.Errorf("failed to merge guest: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, guestID); err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = ?`, guestID); err != nil {
+		return fmt.Errorf("failed to merge guest: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+const userSelect = `
+	SELECT id, username, pin_hash, hint, role, COALESCE(parent_id, ''), is_guest, suspended_at, COALESCE(suspend_reason, ''), created_at
+	FROM users
+`
+
+func scanUser(row interface{ Scan(...any) error }) (*domain.User, error) {
 	var user domain.User
 	var pinHash, hint sql.NullString
-	err := row.Scan(&user.ID, &user.Username, &pinHash, &hint, &user.CreatedAt)
+	var suspendedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &pinHash, &hint, &user.Role, &user.ParentID, &user.Guest, &suspendedAt, &user.SuspendReason, &user.CreatedAt)
 	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Error().Err(err).Str("username", username).Msg("UserRepo: Failed to get user by username")
-		}
 		return nil, err
 	}
 	user.PinHash = pinHash.String
 	user.Hint = hint.String
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
 	return &user, nil
 }
 
-func (r *UserRepo) GetByID(id string) (*domain.User, error) {
-	query := `SELECT id, username, pin_hash, hint, created_at FROM users WHERE id = ?`
-	row := r.DB.QueryRow(query, id)
+// GetByUsername finds the user whose username has the same key as
+// username. An exact match wins, so accounts that collided before keys
+// existed stay reachable by their own spelling.
+func (r *UserRepo) GetByUsername(username string) (*domain.User, error) {
+	user, err := scanUser(r.DB.QueryRow(userSelect+`
+		WHERE username_key = ? OR username = ?
+		ORDER BY username = ? DESC LIMIT 1
+	`, usernames.Key(username), username, username))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str("username", username).Msg("UserRepo: Failed to get user by username")
+		}
+		return nil, err
+	}
+	return user, nil
+}
 
-	var user domain.User
-	var pinHash, hint sql.NullString
-	err := row.Scan(&user.ID, &user.Username, &pinHash, &hint, &user.CreatedAt)
+func (r *UserRepo) GetByID(id string) (*domain.User, error) {
+	user, err := scanUser(r.DB.QueryRow(userSelect+` WHERE id = ?`, id))
 	if err != nil {
 		if err != sql.ErrNoRows {
 			log.Error().Err(err).Str("id", id).Msg("UserRepo: Failed to get user by ID")
 		}
 		return nil, err
 	}
-	user.PinHash = pinHash.String
-	user.Hint = hint.String
-	return &user, nil
+	return user, nil
 }
 
+// UpdatePIN stores a new PIN hash and hint. An empty hint is stored as
+// NULL.
 func (r *UserRepo) UpdatePIN(userID, pinHash, hint string) error {
-	query := `UPDATE users SET pin_hash = ?, hint = ? WHERE id = ?`
+	query := `UPDATE users SET pin_hash = ?, hint = NULLIF(?, '') WHERE id = ?`
 	_, err := r.DB.Exec(query, pinHash, hint, userID)
 	if err != nil {
 		log.Error().Err(err).Str("user_id", userID).Msg("UserRepo: Failed to update PIN")
@@ -77,3 +242,248 @@ func (r *UserRepo) UpdatePIN(userID, pinHash, hint string) error {
 	}
 	return nil
 }
+
+// UpdatePINHash replaces the stored hash of an unchanged PIN, e.g. after
+// upgrading how it is hashed.
+func (r *UserRepo) UpdatePINHash(userID, pinHash string) error {
+	_, err := r.DB.Exec(`UPDATE users SET pin_hash = ? WHERE id = ?`, pinHash, userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("UserRepo: Failed to update PIN hash")
+		return fmt.Errorf("failed to update PIN hash: %w", err)
+	}
+	return nil
+}
+
+// Search returns users whose username contains query (all users if it is
+// empty), alphabetically, along with the total number of matches.
+func (r *UserRepo) Search(query string, limit, offset int) ([]*domain.User, int, error) {
+	pattern := "%" + escapeLike(query) + "%"
+
+	var total int
+	if err := r.DB.QueryRow(`SELECT COUNT(*) FROM users WHERE username LIKE ? ESCAPE '\'`, pattern).Scan(&total); err != nil {
+		log.Error().Err(err).Msg("UserRepo: Failed to count users")
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	rows, err := r.DB.Query(userSelect+`
+		WHERE username LIKE ? ESCAPE '\'
+		ORDER BY username COLLATE NOCASE
+		LIMIT ? OFFSET ?
+	`, pattern, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("UserRepo: Failed to search users")
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("UserRepo: Failed to scan user row")
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// Rename changes a user's username, returning ErrUsernameTaken if another
+// user has it.
+// Rename changes a user's username. audit, if set, is written in the same
+// transaction.
+func (r *UserRepo) Rename(id, username string, audit *domain.AuditEntry) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET username = ?, username_key = ? WHERE id = ?`, username, usernames.Key(username), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUsernameTaken
+		}
+		log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to rename user")
+		return fmt.Errorf("failed to rename user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := insertAudit(tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetRole changes a user's role. audit, if set, is written in the same
+// transaction.
+func (r *UserRepo) SetRole(id, role string, audit *domain.AuditEntry) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to set role")
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := insertAudit(tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// HasAdmin tells whether any user is an admin.
+func (r *UserRepo) HasAdmin() (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE role = ?)`, domain.RoleAdmin).Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Msg("UserRepo: Failed to look for admins")
+		return false, fmt.Errorf("failed to look for admins: %w", err)
+	}
+	return exists, nil
+}
+
+// SetParent links a user to a parent account, or unlinks them if parentID
+// is empty. audit, if set, is written in the same transaction.
+func (r *UserRepo) SetParent(id, parentID string, audit *domain.AuditEntry) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET parent_id = NULLIF(?, '') WHERE id = ?`, parentID, id)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to set parent")
+		return fmt.Errorf("failed to set parent: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := insertAudit(tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListChildren returns the users linked to parentID, alphabetically.
+func (r *UserRepo) ListChildren(parentID string) ([]*domain.User, error) {
+	rows, err := r.DB.Query(userSelect+` WHERE parent_id = ? ORDER BY username COLLATE NOCASE`, parentID)
+	if err != nil {
+		log.Error().Err(err).Str("parent_id", parentID).Msg("UserRepo: Failed to list children")
+		return nil, fmt.Errorf("failed to list children: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("UserRepo: Failed to scan user row")
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Suspend blocks a user from signing in and revokes their sessions, and
+// returns how many it revoked. A zero at lifts the suspension. audit, if
+// set, is written in the same transaction.
+func (r *UserRepo) Suspend(id string, at time.Time, reason string, audit *domain.AuditEntry) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var suspendedAt any
+	if !at.IsZero() {
+		suspendedAt = at.UTC()
+	}
+	res, err := tx.Exec(`UPDATE users SET suspended_at = ?, suspend_reason = ? WHERE id = ?`, suspendedAt, reason, id)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to suspend user")
+		return 0, fmt.Errorf("failed to suspend user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	var revoked int64
+	if !at.IsZero() {
+		res, err := tx.Exec(`UPDATE sessions SET revoked_at = ?, revoke_reason = 'suspended' WHERE user_id = ? AND revoked_at IS NULL`, at.UTC(), id)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to revoke sessions of suspended user")
+			return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		revoked, _ = res.RowsAffected()
+	}
+
+	if err := insertAudit(tx, audit); err != nil {
+		return 0, err
+	}
+	return int(revoked), tx.Commit()
+}
+
+// Delete removes a user and everything they own in one transaction.
+// Foreign keys aren't enforced on this connection, so every table is
+// cleared explicitly. Opponents keep their PvP results, minus the link to
+// the deleted player and the shared match record. audit, if set, is
+// written in the same transaction.
+func (r *UserRepo) Delete(id string, audit *domain.AuditEntry) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var username string
+	if err := tx.QueryRow(`SELECT username FROM users WHERE id = ?`, id).Scan(&username); err != nil {
+		return err
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE game_results SET session_id = NULL WHERE game = 'tictactoe' AND session_id IN (SELECT id FROM pvp_matches WHERE x_user_id = ? OR o_user_id = ?)`, []any{id, id}},
+		{`UPDATE game_results SET opponent_id = NULL WHERE opponent_id = ?`, []any{id}},
+		{`DELETE FROM pvp_matches WHERE x_user_id = ? OR o_user_id = ?`, []any{id, id}},
+		{`DELETE FROM game_results WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM season_standings WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM user_achievements WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM tictactoe_sessions WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM memory_sessions WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM game2048_sessions WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM blockblast_sessions WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM sessions WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM pin_reset_codes WHERE user_id = ?`, []any{id}},
+		{`DELETE FROM login_failures WHERE scope = 'user' AND key = ?`, []any{usernames.Key(username)}},
+		{`DELETE FROM lockout_events WHERE scope = 'user' AND key = ?`, []any{usernames.Key(username)}},
+		{`UPDATE users SET parent_id = NULL WHERE parent_id = ?`, []any{id}},
+		{`DELETE FROM users WHERE id = ?`, []any{id}},
+	}
+	for _, st := range statements {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			log.Error().Err(err).Str("user_id", id).Msg("UserRepo: Failed to delete user data")
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+	}
+	if err := insertAudit(tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
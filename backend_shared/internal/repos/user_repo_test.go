@@ -0,0 +1,171 @@
+package repos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ramanasai/local-game-play/internal/db/dbtest"
+	"github.com/ramanasai/local-game-play/internal/domain"
+)
+
+func testAudit(action string, target *domain.User) *domain.AuditEntry {
+	return &domain.AuditEntry{AdminID: "admin", AdminUsername: "admin", Action: action, TargetID: target.ID, TargetUsername: target.Username}
+}
+
+// TestAuditedChanges checks that an admin change and its audit entry are
+// stored together or not at all.
+func TestAuditedChanges(t *testing.T) {
+	database := dbtest.Open(t)
+	users := NewUserRepo(database)
+	audits := NewAuditRepo(database)
+	alice, err := users.Create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Create("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func() error
+		audited bool
+	}{
+		{"rename", func() error { return users.Rename(alice.ID, "alicia", testAudit("rename", alice)) }, true},
+		{"rename to a taken name", func() error { return users.Rename(alice.ID, "bob", testAudit("rename", alice)) }, false},
+		{"set role", func() error { return users.SetRole(alice.ID, domain.RoleAdmin, testAudit("set_role", alice)) }, true},
+		{"set role of a missing user", func() error { return users.SetRole("missing", domain.RoleAdmin, testAudit("set_role", alice)) }, false},
+		{"suspend", func() error {
+			_, err := users.Suspend(alice.ID, time.Now(), "spam", testAudit("suspend", alice))
+			return err
+		}, true},
+		{"set parent without audit", func() error { return users.SetParent(alice.ID, "", nil) }, false},
+	}
+	for _, tt := range tests {
+		before, err := audits.List(alice.ID, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tt.change()
+		after, _ := audits.List(alice.ID, 100)
+		if audited := len(after) > len(before); audited != tt.audited {
+			t.Errorf("%s (err %v): audited = %v, want %v", tt.name, err, audited, tt.audited)
+		}
+		if tt.audited && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	// A change whose audit entry can't be written is rolled back.
+	if _, err := database.Exec(`DROP TABLE admin_audit`); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Rename(alice.ID, "ally", testAudit("rename", alice)); err == nil {
+		t.Fatal("rename succeeded without its audit entry")
+	}
+	if _, err := users.Suspend(alice.ID, time.Time{}, "", testAudit("unsuspend", alice)); err == nil {
+		t.Fatal("unsuspend succeeded without its audit entry")
+	}
+	user, err := users.GetByID(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alicia" || user.SuspendedAt == nil {
+		t.Errorf("changes kept without their audit entries: %+v", user)
+	}
+	if err := users.Delete(alice.ID, testAudit("delete", alice)); err == nil {
+		t.Fatal("delete succeeded without its audit entry")
+	}
+	if _, err := users.GetByID(alice.ID); err != nil {
+		t.Errorf("user deleted without an audit entry: %v", err)
+	}
+}
+
+func TestSuspendRevokesSessions(t *testing.T) {
+	database := dbtest.Open(t)
+	users := NewUserRepo(database)
+	sessions := NewSessionRepo(database)
+	alice, err := users.Create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"a", "b"} {
+		if _, err := sessions.Create(alice.ID, token, time.Now().Add(time.Hour), "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revoked, err := users.Suspend(alice.ID, time.Now(), "spam", nil)
+	if err != nil || revoked != 2 {
+		t.Fatalf("Suspend = %d, %v; want 2 sessions revoked", revoked, err)
+	}
+	if live, _ := sessions.ListActiveByUser(alice.ID); len(live) != 0 {
+		t.Errorf("%d sessions left after suspension", len(live))
+	}
+	if revoked, err := users.Suspend(alice.ID, time.Time{}, "", nil); err != nil || revoked != 0 {
+		t.Errorf("lifting suspension = %d, %v; want nothing revoked", revoked, err)
+	}
+}
+
+// TestMergeGuestDaily checks that a guest's attempt at a daily challenge
+// the target also played stops counting once merged, and that other days
+// carry over as they were.
+func TestMergeGuestDaily(t *testing.T) {
+	database := dbtest.Open(t)
+	users := NewUserRepo(database)
+	scores := NewScoreRepo(database)
+	alice, err := users.Create("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := users.CreateGuest("guest-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	play := func(userID, date string) string {
+		session, err := scores.CreateSession(userID, 1, 12, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = database.Exec(`INSERT INTO game_results (id, game, variant, user_id, session_id, score, ranked) VALUES (?, 'memory', ?, ?, ?, 100, 1)`,
+			session.ID, "daily:"+date, userID, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return session.ID
+	}
+	play(alice.ID, "2026-01-01")
+	shared := play(guest.ID, "2026-01-01")
+	own := play(guest.ID, "2026-01-02")
+
+	if err := users.MergeGuest(guest.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		session string
+		date    string
+		ranked  bool
+	}{
+		{shared, "", false},
+		{own, "2026-01-02", true},
+	}
+	for _, tt := range tests {
+		session, err := scores.GetSession(tt.session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ranked bool
+		if err := database.QueryRow(`SELECT ranked FROM game_results WHERE session_id = ?`, tt.session).Scan(&ranked); err != nil {
+			t.Fatal(err)
+		}
+		if session.UserID != alice.ID || session.DailyDate != tt.date || ranked != tt.ranked {
+			t.Errorf("session %s: user %s, daily %q, ranked %v; want %s, %q, %v",
+				tt.session, session.UserID, session.DailyDate, ranked, alice.ID, tt.date, tt.ranked)
+		}
+	}
+	if _, err := scores.CreateSession(alice.ID, 1, 12, "2026-01-02"); err != ErrDailyPlayed {
+		t.Errorf("second attempt at a merged day: err = %v, want ErrDailyPlayed", err)
+	}
+}
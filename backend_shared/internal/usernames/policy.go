@@ -0,0 +1,123 @@
+// Package usernames decides what a username may look like and when two
+// usernames count as the same.
+package usernames
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Length limits, in characters after normalization.
+const (
+	MinLength = 2
+	MaxLength = 20
+)
+
+// ErrInvalid is wrapped by every policy violation, so callers can tell
+// them apart from storage errors.
+var ErrInvalid = errors.New("invalid username")
+
+var (
+	ErrTooShort   = fmt.Errorf("%w: must be at least %d characters", ErrInvalid, MinLength)
+	ErrTooLong    = fmt.Errorf("%w: must be at most %d characters", ErrInvalid, MaxLength)
+	ErrCharacters = fmt.Errorf("%w: use letters, digits, spaces, '.', '-' or '_', starting and ending with a letter or digit", ErrInvalid)
+	ErrReserved   = fmt.Errorf("%w: username is reserved", ErrInvalid)
+	ErrBlocked    = fmt.Errorf("%w: username is not allowed", ErrInvalid)
+)
+
+// DefaultReserved are always reserved, whatever else is configured. An
+// entry ending in "*" reserves every name starting with it; "guest-*" keeps
+// the generated guest names apart from real accounts.
+var DefaultReserved = []string{
+	"admin", "administrator", "root", "system", "moderator", "support", "staff",
+	"guest", "guest-*", "anonymous", "null", "undefined",
+}
+
+// Policy checks names people pick for themselves. Names that were created
+// before it existed keep working; it only applies to new names.
+type Policy struct {
+	reserved map[string]bool
+	prefixes []string
+	blocked  []string
+}
+
+// NewPolicy reserves DefaultReserved plus reserved, and refuses any name
+// containing one of the blocked words. Both lists are matched by Key, so
+// case and look-alike forms don't get around them.
+func NewPolicy(reserved, blocked []string) *Policy {
+	p := &Policy{reserved: make(map[string]bool)}
+	for _, name := range append(append([]string{}, DefaultReserved...), reserved...) {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			p.prefixes = append(p.prefixes, Key(prefix))
+		} else {
+			p.reserved[Key(name)] = true
+		}
+	}
+	for _, word := range blocked {
+		if word = Key(word); word != "" {
+			p.blocked = append(p.blocked, word)
+		}
+	}
+	return p
+}
+
+// Check returns the normalized form of raw, the one to store and show, or
+// an error wrapping ErrInvalid saying what is wrong with it.
+func (p *Policy) Check(raw string) (string, error) {
+	name := Normalize(raw)
+
+	n := utf8.RuneCountInString(name)
+	if n < MinLength {
+		return "", ErrTooShort
+	}
+	if n > MaxLength {
+		return "", ErrTooLong
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+		case unicode.In(r, unicode.Mn, unicode.Mc) && i > 0:
+			// Combining marks that have no precomposed form, as in
+			// many Indic scripts.
+		case strings.ContainsRune(" .-_", r) && i > 0 && i+utf8.RuneLen(r) < len(name):
+		default:
+			return "", ErrCharacters
+		}
+	}
+
+	key := Key(name)
+	if p.reserved[key] {
+		return "", ErrReserved
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return "", ErrReserved
+		}
+	}
+	for _, word := range p.blocked {
+		if strings.Contains(key, word) {
+			return "", ErrBlocked
+		}
+	}
+	return name, nil
+}
+
+// Normalize trims raw, collapses runs of whitespace to one space and puts
+// it in Unicode NFC, so the same name typed on different devices is stored
+// the same way.
+func Normalize(raw string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(raw), " "))
+}
+
+// Key is what uniqueness is decided on: the normalized name case-folded,
+// with compatibility forms such as full-width letters replaced by their
+// plain equivalents. "Bob", "bob " and "ｂｏｂ" share a key.
+func Key(name string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(Normalize(name))))
+}
@@ -0,0 +1,77 @@
+package usernames
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Bob", "bob", true},
+		{"bob ", "  bob", true},
+		{"Mary  Jane", "mary jane", true},
+		{"ｂｏｂ", "bob", true},
+		{"ＢＯＢ", "bob", true},
+		{"Straße", "STRASSE", true},
+		{"café", "café", true},
+		{"ﬁsh", "fish", true},
+		{"bob", "bob2", false},
+		{"mary jane", "maryjane", false},
+		{"café", "cafe", false},
+	}
+	for _, tt := range tests {
+		if same := Key(tt.a) == Key(tt.b); same != tt.same {
+			t.Errorf("Key(%q) == Key(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := NewPolicy([]string{"Owner", "team-*"}, []string{"badword"})
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{"alice", "alice", nil},
+		{"  Mary   Jane ", "Mary Jane", nil},
+		{"o'neil", "", ErrCharacters},
+		{"a.b-c_d", "a.b-c_d", nil},
+		{"café", "café", nil},
+		{"अनिल", "अनिल", nil},
+		{"x", "", ErrTooShort},
+		{"   x   ", "", ErrTooShort},
+		{"abcdefghijklmnopqrstu", "", ErrTooLong},
+		{"abcdefghijklmnopqrst", "abcdefghijklmnopqrst", nil},
+		{"_alice", "", ErrCharacters},
+		{"alice.", "", ErrCharacters},
+		{"al!ce", "", ErrCharacters},
+		{"́alice", "", ErrCharacters},
+		{"Admin", "", ErrReserved},
+		{"ＡＤＭＩＮ", "", ErrReserved},
+		{"guest-1234", "", ErrReserved},
+		{"Guest-abc", "", ErrReserved},
+		{"guest", "", ErrReserved},
+		{"guesthouse", "guesthouse", nil},
+		{"owner", "", ErrReserved},
+		{"Team-Red", "", ErrReserved},
+		{"my BadWord 1", "", ErrBlocked},
+		{"ｂａｄｗｏｒｄ", "", ErrBlocked},
+	}
+	for _, tt := range tests {
+		got, err := p.Check(tt.raw)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Check(%q) error = %v, want %v", tt.raw, err, tt.err)
+			continue
+		}
+		if tt.err != nil && !errors.Is(err, ErrInvalid) {
+			t.Errorf("Check(%q) error %v doesn't wrap ErrInvalid", tt.raw, err)
+		}
+		if got != tt.want {
+			t.Errorf("Check(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
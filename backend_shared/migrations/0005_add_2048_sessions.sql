@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS game2048_sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    seed INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','finished')),
+    moves TEXT NOT NULL DEFAULT '',
+    score INTEGER,
+    max_tile INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_game2048_sessions_user ON game2048_sessions(user_id, created_at DESC);
+
+-- Scores without a session predate server-side verification and are kept
+-- for history only; the leaderboard ignores them.
+ALTER TABLE scores_2048 ADD COLUMN session_id TEXT REFERENCES game2048_sessions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scores_2048_session ON scores_2048(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_scores_2048_session;
+ALTER TABLE scores_2048 DROP COLUMN session_id;
+DROP TABLE IF EXISTS game2048_sessions;
+-- +goose StatementEnd
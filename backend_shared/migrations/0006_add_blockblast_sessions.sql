@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS blockblast_sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    seed INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','finished')),
+    placements TEXT NOT NULL DEFAULT '[]',
+    placement_count INTEGER NOT NULL DEFAULT 0,
+    score INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_blockblast_sessions_user ON blockblast_sessions(user_id, created_at DESC);
+
+-- As with 2048, rows without a session were client-reported and are no
+-- longer ranked.
+ALTER TABLE scores_blockblast ADD COLUMN session_id TEXT REFERENCES blockblast_sessions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scores_blockblast_session ON scores_blockblast(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_scores_blockblast_session;
+ALTER TABLE scores_blockblast DROP COLUMN session_id;
+DROP TABLE IF EXISTS blockblast_sessions;
+-- +goose StatementEnd
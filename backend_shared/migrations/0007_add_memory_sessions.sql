@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS memory_sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    seed INTEGER NOT NULL,
+    pairs INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','finished')),
+    flips TEXT NOT NULL DEFAULT '[]',
+    flip_count INTEGER NOT NULL DEFAULT 0,
+    suspicious INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_memory_sessions_user ON memory_sessions(user_id, created_at DESC);
+
+-- Moves and time are now derived from recorded flips; older client-reported
+-- rows keep a NULL session and drop off the leaderboard.
+ALTER TABLE scores ADD COLUMN session_id TEXT REFERENCES memory_sessions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scores_session ON scores(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_scores_session;
+ALTER TABLE scores DROP COLUMN session_id;
+DROP TABLE IF EXISTS memory_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tictactoe_sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    difficulty TEXT NOT NULL CHECK (difficulty IN ('easy','medium','hard')),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','finished')),
+    moves TEXT NOT NULL DEFAULT '[]',
+    move_count INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tictactoe_sessions_user ON tictactoe_sessions(user_id, created_at DESC);
+
+-- Only matches concluded by the server carry a session and count toward
+-- stats and the leaderboard.
+ALTER TABLE matches ADD COLUMN session_id TEXT REFERENCES tictactoe_sessions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matches_session ON matches(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_matches_session;
+ALTER TABLE matches DROP COLUMN session_id;
+DROP TABLE IF EXISTS tictactoe_sessions;
+-- +goose StatementEnd
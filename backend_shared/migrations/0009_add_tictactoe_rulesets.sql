@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing matches were all played on the classic 3x3 board.
+ALTER TABLE tictactoe_sessions ADD COLUMN ruleset TEXT NOT NULL DEFAULT '3x3-k3-p3';
+ALTER TABLE matches ADD COLUMN ruleset TEXT NOT NULL DEFAULT '3x3-k3-p3';
+CREATE INDEX IF NOT EXISTS idx_matches_ruleset ON matches(ruleset, difficulty, result);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_matches_ruleset;
+ALTER TABLE matches DROP COLUMN ruleset;
+ALTER TABLE tictactoe_sessions DROP COLUMN ruleset;
+-- +goose StatementEnd
@@ -0,0 +1,79 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS pvp_matches (
+    id TEXT PRIMARY KEY,
+    x_user_id TEXT NOT NULL,
+    o_user_id TEXT,
+    ruleset TEXT NOT NULL DEFAULT '3x3-k3-p3',
+    status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting','active','finished')),
+    moves TEXT NOT NULL DEFAULT '[]',
+    move_count INTEGER NOT NULL DEFAULT 0,
+    winner TEXT CHECK (winner IN ('X','O')),
+    end_reason TEXT,
+    turn_started_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY(x_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(o_user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pvp_matches_status ON pvp_matches(status);
+
+-- matches only allowed AI difficulties, so rebuild it to accept 'pvp' and
+-- to record who the opponent was. Each PvP match writes one row per player.
+CREATE TABLE matches_new (
+  id           TEXT PRIMARY KEY,
+  user_id      TEXT NOT NULL,
+  difficulty   TEXT NOT NULL CHECK (difficulty IN ('easy','medium','hard','pvp')),
+  result       TEXT NOT NULL CHECK (result IN ('win','loss','draw')),
+  moves        INTEGER NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  session_id   TEXT REFERENCES tictactoe_sessions(id),
+  ruleset      TEXT NOT NULL DEFAULT '3x3-k3-p3',
+  pvp_match_id TEXT REFERENCES pvp_matches(id),
+  opponent_id  TEXT REFERENCES users(id) ON DELETE SET NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO matches_new (id, user_id, difficulty, result, moves, created_at, session_id, ruleset)
+SELECT id, user_id, difficulty, result, moves, created_at, session_id, ruleset FROM matches;
+
+DROP TABLE matches;
+ALTER TABLE matches_new RENAME TO matches;
+
+CREATE INDEX IF NOT EXISTS idx_matches_user_created ON matches(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_matches_diff_result ON matches(difficulty, result);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matches_session ON matches(session_id);
+CREATE INDEX IF NOT EXISTS idx_matches_ruleset ON matches(ruleset, difficulty, result);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matches_pvp ON matches(pvp_match_id, user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE matches_old (
+  id          TEXT PRIMARY KEY,
+  user_id     TEXT NOT NULL,
+  difficulty  TEXT NOT NULL CHECK (difficulty IN ('easy','medium','hard')),
+  result      TEXT NOT NULL CHECK (result IN ('win','loss','draw')),
+  moves       INTEGER NOT NULL,
+  created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  session_id  TEXT REFERENCES tictactoe_sessions(id),
+  ruleset     TEXT NOT NULL DEFAULT '3x3-k3-p3',
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO matches_old (id, user_id, difficulty, result, moves, created_at, session_id, ruleset)
+SELECT id, user_id, difficulty, result, moves, created_at, session_id, ruleset FROM matches
+WHERE difficulty != 'pvp';
+
+DROP TABLE matches;
+ALTER TABLE matches_old RENAME TO matches;
+
+CREATE INDEX IF NOT EXISTS idx_matches_user_created ON matches(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_matches_diff_result ON matches(difficulty, result);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matches_session ON matches(session_id);
+CREATE INDEX IF NOT EXISTS idx_matches_ruleset ON matches(ruleset, difficulty, result);
+
+DROP TABLE IF EXISTS pvp_matches;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Each row is one login (a refresh-token family). token holds the SHA-256
+-- of the family's current refresh token; rotating replaces it, and showing
+-- an older token revokes the whole row. The old rows held random tokens
+-- nothing ever read, so they are dropped.
+DELETE FROM sessions;
+ALTER TABLE sessions ADD COLUMN expires_at DATETIME;
+ALTER TABLE sessions ADD COLUMN rotated_at DATETIME;
+ALTER TABLE sessions ADD COLUMN revoked_at DATETIME;
+ALTER TABLE sessions ADD COLUMN revoke_reason TEXT;
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_sessions_user;
+ALTER TABLE sessions DROP COLUMN revoke_reason;
+ALTER TABLE sessions DROP COLUMN revoked_at;
+ALTER TABLE sessions DROP COLUMN rotated_at;
+ALTER TABLE sessions DROP COLUMN expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed PIN attempts per username and per client IP. Times are unix
+-- seconds so the failure window can be applied inside one UPSERT.
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope TEXT NOT NULL CHECK (scope IN ('user','ip')),
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    last_failure_at INTEGER NOT NULL DEFAULT 0,
+    locked_until INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (scope, key)
+);
+
+-- Audit trail: one row per lockout imposed.
+CREATE TABLE IF NOT EXISTS lockout_events (
+    id TEXT PRIMARY KEY,
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lockout_events_key ON lockout_events(scope, key, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS lockout_events;
+DROP TABLE IF EXISTS login_failures;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'player' CHECK (role IN ('player','admin'));
+ALTER TABLE users ADD COLUMN suspended_at DATETIME;
+ALTER TABLE users ADD COLUMN suspend_reason TEXT;
+
+-- Single-use codes that let a user set a new PIN. Only the hash is kept.
+CREATE TABLE IF NOT EXISTS pin_reset_codes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    issued_by TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_pin_reset_codes_user ON pin_reset_codes(user_id, created_at DESC);
+
+-- One row per admin action. Usernames are copied in so the trail still
+-- reads after a user is renamed or deleted.
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id TEXT PRIMARY KEY,
+    admin_id TEXT NOT NULL,
+    admin_username TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_id TEXT,
+    target_username TEXT,
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_created ON admin_audit(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_target ON admin_audit(target_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS admin_audit;
+DROP TABLE IF EXISTS pin_reset_codes;
+ALTER TABLE users DROP COLUMN suspend_reason;
+ALTER TABLE users DROP COLUMN suspended_at;
+ALTER TABLE users DROP COLUMN role;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A parent account may issue PIN reset codes for its children.
+ALTER TABLE users ADD COLUMN parent_id TEXT REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_users_parent ON users(parent_id);
+-- Empty hints were stored as ''; they are optional now and kept as NULL.
+UPDATE users SET hint = NULL WHERE hint = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_parent;
+ALTER TABLE users DROP COLUMN parent_id;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Guests play without a PIN. Their scores are kept but not ranked until
+-- they claim them into a real account.
+ALTER TABLE users ADD COLUMN is_guest INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_users_guest ON users(is_guest, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_guest;
+ALTER TABLE users DROP COLUMN is_guest;
+-- +goose StatementEnd
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/ramanasai/local-game-play/internal/usernames"
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	goose.AddMigrationContext(upAddUsernameKeys, downAddUsernameKeys)
+}
+
+// upAddUsernameKeys gives every user the case-folded key usernames are now
+// unique on. Keys can't be computed in SQL, hence a Go migration. Where
+// existing names collide ("Bob" and "bob") the oldest account keeps the
+// key; the others are logged and left without one, so they still sign in
+// by their exact name until an admin renames them.
+func upAddUsernameKeys(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN username_key TEXT`); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, username FROM users ORDER BY created_at, id`)
+	if err != nil {
+		return err
+	}
+	type user struct{ id, username string }
+	var users []user
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.id, &u.username); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	owners := make(map[string]user, len(users))
+	collisions := 0
+	for _, u := range users {
+		key := usernames.Key(u.username)
+		if owner, taken := owners[key]; taken {
+			collisions++
+			log.Warn().Str("user_id", u.id).Str("username", u.username).
+				Str("collides_with_id", owner.id).Str("collides_with", owner.username).
+				Msg("Username collides with an older account; rename it to make it unique")
+			continue
+		}
+		owners[key] = u
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET username_key = ? WHERE id = ?`, key, u.id); err != nil {
+			return fmt.Errorf("failed to set key for user %s: %w", u.id, err)
+		}
+	}
+	if collisions > 0 {
+		log.Warn().Int("collisions", collisions).Msg("Existing usernames differ only by case or spacing")
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE UNIQUE INDEX idx_users_username_key ON users(username_key)`)
+	return err
+}
+
+func downAddUsernameKeys(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `DROP INDEX IF EXISTS idx_users_username_key`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `ALTER TABLE users DROP COLUMN username_key`)
+	return err
+}
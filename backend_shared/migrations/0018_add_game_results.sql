@@ -0,0 +1,133 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One results store for every game. score and tie_break are what the game
+-- ranks on; details holds the game's own fields as JSON. session_id is the
+-- verified session (or PvP match) a result came from, NULL for results
+-- recorded before sessions existed.
+CREATE TABLE IF NOT EXISTS game_results (
+    id TEXT PRIMARY KEY,
+    game TEXT NOT NULL,
+    variant TEXT NOT NULL DEFAULT '',
+    user_id TEXT NOT NULL,
+    session_id TEXT,
+    opponent_id TEXT,
+    score INTEGER NOT NULL,
+    tie_break INTEGER NOT NULL DEFAULT 0,
+    ranked INTEGER NOT NULL DEFAULT 1,
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(opponent_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_game_results_session ON game_results(game, session_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_game_results_board ON game_results(game, variant, ranked, score);
+CREATE INDEX IF NOT EXISTS idx_game_results_user ON game_results(user_id, game, created_at);
+
+-- Memory ranks fewest moves, then fastest. Flagged runs stay unranked.
+INSERT INTO game_results (id, game, user_id, session_id, score, tie_break, ranked, details, created_at)
+SELECT s.id, 'memory', s.user_id, s.session_id, s.moves, s.time_seconds,
+       ms.id IS NOT NULL AND ms.suspicious = 0,
+       json_object('moves', s.moves, 'time_seconds', s.time_seconds, 'pairs', ms.pairs),
+       COALESCE(s.created_at, CURRENT_TIMESTAMP)
+FROM scores s
+LEFT JOIN memory_sessions ms ON ms.id = s.session_id;
+
+INSERT INTO game_results (id, game, user_id, session_id, score, ranked, details, created_at)
+SELECT s.id, '2048', s.user_id, s.session_id, s.score, s.session_id IS NOT NULL,
+       json_object('max_tile', gs.max_tile),
+       COALESCE(s.created_at, CURRENT_TIMESTAMP)
+FROM scores_2048 s
+LEFT JOIN game2048_sessions gs ON gs.id = s.session_id;
+
+INSERT INTO game_results (id, game, user_id, session_id, score, ranked, details, created_at)
+SELECT s.id, 'blockblast', s.user_id, s.session_id, s.score, s.session_id IS NOT NULL,
+       json_object('placements', bs.placement_count),
+       COALESCE(s.created_at, CURRENT_TIMESTAMP)
+FROM scores_blockblast s
+LEFT JOIN blockblast_sessions bs ON bs.id = s.session_id;
+
+-- Tic-Tac-Toe boards count wins against the hard AI, so those score 1 and
+-- only hard AI matches are ranked.
+INSERT INTO game_results (id, game, variant, user_id, session_id, opponent_id, score, ranked, details, created_at)
+SELECT id, 'tictactoe', ruleset, user_id, COALESCE(session_id, pvp_match_id), opponent_id,
+       difficulty = 'hard' AND result = 'win',
+       difficulty = 'hard' AND session_id IS NOT NULL,
+       json_object('difficulty', difficulty, 'result', result, 'moves', moves),
+       created_at
+FROM matches;
+
+DROP TABLE scores;
+DROP TABLE scores_2048;
+DROP TABLE scores_blockblast;
+DROP TABLE matches;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE scores (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    moves INTEGER NOT NULL,
+    time_seconds INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    session_id TEXT REFERENCES memory_sessions(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_scores_session ON scores(session_id);
+INSERT INTO scores (id, user_id, moves, time_seconds, created_at, session_id)
+SELECT id, user_id, score, tie_break, created_at, session_id FROM game_results WHERE game = 'memory';
+
+CREATE TABLE scores_2048 (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    score INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    session_id TEXT REFERENCES game2048_sessions(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_scores_2048_score ON scores_2048(score DESC);
+CREATE UNIQUE INDEX idx_scores_2048_session ON scores_2048(session_id);
+INSERT INTO scores_2048 (id, user_id, score, created_at, session_id)
+SELECT id, user_id, score, created_at, session_id FROM game_results WHERE game = '2048';
+
+CREATE TABLE scores_blockblast (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    score INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    session_id TEXT REFERENCES blockblast_sessions(id),
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_scores_blockblast_score ON scores_blockblast(score DESC);
+CREATE INDEX idx_scores_blockblast_user_id ON scores_blockblast(user_id);
+CREATE UNIQUE INDEX idx_scores_blockblast_session ON scores_blockblast(session_id);
+INSERT INTO scores_blockblast (id, user_id, score, created_at, session_id)
+SELECT id, user_id, score, created_at, session_id FROM game_results WHERE game = 'blockblast';
+
+CREATE TABLE matches (
+  id           TEXT PRIMARY KEY,
+  user_id      TEXT NOT NULL,
+  difficulty   TEXT NOT NULL CHECK (difficulty IN ('easy','medium','hard','pvp')),
+  result       TEXT NOT NULL CHECK (result IN ('win','loss','draw')),
+  moves        INTEGER NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  session_id   TEXT REFERENCES tictactoe_sessions(id),
+  ruleset      TEXT NOT NULL DEFAULT '3x3-k3-p3',
+  pvp_match_id TEXT REFERENCES pvp_matches(id),
+  opponent_id  TEXT REFERENCES users(id) ON DELETE SET NULL,
+  FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_matches_user_created ON matches(user_id, created_at DESC);
+CREATE INDEX idx_matches_diff_result ON matches(difficulty, result);
+CREATE UNIQUE INDEX idx_matches_session ON matches(session_id);
+CREATE INDEX idx_matches_ruleset ON matches(ruleset, difficulty, result);
+CREATE UNIQUE INDEX idx_matches_pvp ON matches(pvp_match_id, user_id);
+INSERT INTO matches (id, user_id, difficulty, result, moves, created_at, session_id, ruleset, pvp_match_id, opponent_id)
+SELECT id, user_id, json_extract(details, '$.difficulty'), json_extract(details, '$.result'), json_extract(details, '$.moves'), created_at,
+       CASE WHEN json_extract(details, '$.difficulty') = 'pvp' THEN NULL ELSE session_id END, variant,
+       CASE WHEN json_extract(details, '$.difficulty') = 'pvp' THEN session_id END, opponent_id
+FROM game_results WHERE game = 'tictactoe';
+
+DROP TABLE game_results;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Seasons come from configuration and are synced here at startup, so
+-- archived standings keep pointing at them after they leave the config.
+CREATE TABLE IF NOT EXISTS seasons (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    starts_at DATETIME NOT NULL,
+    ends_at DATETIME NOT NULL,
+    archived_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Final boards of ended seasons. username is kept as it was at the end of
+-- the season; position is the place in board order, rank is shared by ties.
+CREATE TABLE IF NOT EXISTS season_standings (
+    season_id TEXT NOT NULL,
+    game TEXT NOT NULL,
+    variant TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL,
+    rank INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    score INTEGER NOT NULL,
+    tie_break INTEGER NOT NULL DEFAULT 0,
+    result_id TEXT,
+    details TEXT NOT NULL DEFAULT '{}',
+    PRIMARY KEY (season_id, game, variant, user_id),
+    FOREIGN KEY(season_id) REFERENCES seasons(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_season_standings_board ON season_standings(season_id, game, variant, position);
+CREATE INDEX IF NOT EXISTS idx_season_standings_user ON season_standings(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS season_standings;
+DROP TABLE IF EXISTS seasons;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- daily_date marks a daily challenge session; each user gets one per game
+-- and day. Regular sessions leave it NULL, which the unique indexes ignore.
+ALTER TABLE memory_sessions ADD COLUMN daily_date TEXT;
+ALTER TABLE game2048_sessions ADD COLUMN daily_date TEXT;
+ALTER TABLE blockblast_sessions ADD COLUMN daily_date TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_memory_sessions_daily ON memory_sessions(user_id, daily_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_game2048_sessions_daily ON game2048_sessions(user_id, daily_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blockblast_sessions_daily ON blockblast_sessions(user_id, daily_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_memory_sessions_daily;
+DROP INDEX IF EXISTS idx_game2048_sessions_daily;
+DROP INDEX IF EXISTS idx_blockblast_sessions_daily;
+
+ALTER TABLE memory_sessions DROP COLUMN daily_date;
+ALTER TABLE game2048_sessions DROP COLUMN daily_date;
+ALTER TABLE blockblast_sessions DROP COLUMN daily_date;
+-- +goose StatementEnd
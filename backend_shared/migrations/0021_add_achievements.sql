@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- unlocked_at is the time of the result that completed the rule, so an
+-- achievement found by a backfill keeps the date it was really earned.
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id TEXT NOT NULL,
+    achievement_id TEXT NOT NULL,
+    unlocked_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, achievement_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Rules whose unlocks have been worked out from the existing results.
+CREATE TABLE IF NOT EXISTS achievement_backfills (
+    achievement_id TEXT PRIMARY KEY,
+    backfilled_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS achievement_backfills;
+DROP TABLE IF EXISTS user_achievements;
+-- +goose StatementEnd
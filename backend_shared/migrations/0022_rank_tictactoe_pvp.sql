@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The hard AI can be beaten by force, so Tic-Tac-Toe boards count wins
+-- against other players instead: only PvP matches are ranked, and a win
+-- there scores 1.
+UPDATE game_results
+SET ranked = json_extract(details, '$.difficulty') = 'pvp',
+    score = json_extract(details, '$.difficulty') = 'pvp' AND json_extract(details, '$.result') = 'win'
+WHERE game = 'tictactoe';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE game_results
+SET ranked = json_extract(details, '$.difficulty') = 'hard' AND session_id IS NOT NULL,
+    score = json_extract(details, '$.difficulty') = 'hard' AND json_extract(details, '$.result') = 'win'
+WHERE game = 'tictactoe';
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Memory boards are per board size, as moves and times only compare on the
+-- same number of pairs. Results from before sessions recorded the size
+-- have no pairs; they were never ranked and stay on the old board.
+UPDATE game_results
+SET variant = CAST(json_extract(details, '$.pairs') AS TEXT)
+WHERE game = 'memory' AND variant = '' AND json_extract(details, '$.pairs') IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE game_results SET variant = '' WHERE game = 'memory' AND variant NOT LIKE 'daily:%';
+-- +goose StatementEnd
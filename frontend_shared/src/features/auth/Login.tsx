@@ -146,6 +146,7 @@ const Login = () => {
                                                 value={username}
                                                 onChange={(e) => setUsername(e.target.value)}
                                                 placeholder="Username"
+                                                maxLength={20}
                                                 className="bg-transparent w-full text-lg font-bold text-gray-800 outline-none placeholder:text-gray-400"
                                                 autoFocus
                                             />
@@ -271,7 +272,7 @@ const Login = () => {
                                         <div className="space-y-3">
                                             <input
                                                 type="text"
-                                                placeholder="Recovery Hint (Recommended)"
+                                                placeholder="Recovery Hint (Optional)"
                                                 value={hint}
                                                 onChange={(e) => setHint(e.target.value)}
                                                 className="w-full text-center bg-gray-50 border-none rounded-xl py-3 text-sm text-gray-700 placeholder:text-gray-400 focus:ring-2 focus:ring-[#FF6B6B]/20 outline-none"
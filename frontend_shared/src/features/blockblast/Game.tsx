@@ -1,65 +1,62 @@
-import { useEffect } from 'react';
+import { useEffect, useState } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
-import { RefreshCw, ArrowLeft, Pause, Play, ChevronLeft, ChevronRight, RotateCw, ArrowDown } from 'lucide-react';
+import { RefreshCw, ArrowLeft, Flag } from 'lucide-react';
 import { Link } from 'react-router-dom';
-import { useBlockBlastStore } from './store';
+import { useBlockBlastStore, fits, SIZE, SLOT_COLORS, type Piece } from './store';
 import { cn } from '../../lib/utils';
 
+const PiecePreview = ({ piece, color }: { piece: Piece, color: string }) => {
+    const rows = Math.max(...piece.cells.map(c => c.row)) + 1;
+    const cols = Math.max(...piece.cells.map(c => c.col)) + 1;
+    const filled = new Set(piece.cells.map(c => `${c.row}-${c.col}`));
+    return (
+        <div
+            className="grid gap-px"
+            style={{ gridTemplateColumns: `repeat(${cols}, 0.9rem)`, gridTemplateRows: `repeat(${rows}, 0.9rem)` }}
+        >
+            {Array.from({ length: rows * cols }, (_, i) => {
+                const r = Math.floor(i / cols);
+                const c = i % cols;
+                return (
+                    <div
+                        key={i}
+                        className={cn("rounded-[2px]", filled.has(`${r}-${c}`) ? color : "bg-transparent")}
+                    />
+                );
+            })}
+        </div>
+    );
+};
+
 const BlockBlastGame = () => {
     const {
-        grid, score, gameOver, isPaused, activePiece,
-        initGame, resetGame, tick, moveHorizontal, rotate, hardDrop, togglePause
+        grid, tray, score, combo, gameOver, selectedSlot, lastClear, busy, error,
+        initGame, resetGame, selectSlot, place, giveUp
     } = useBlockBlastStore();
+    const [hover, setHover] = useState<{ row: number, col: number } | null>(null);
 
-    // Game Loop
     useEffect(() => {
         initGame();
-        return () => { }; // Cleanup handled by component unmount mostly
     }, []);
 
-    useEffect(() => {
-        if (gameOver || isPaused) return;
-        const interval = setInterval(tick, 800); // 800ms initial speed
-        return () => clearInterval(interval);
-    }, [gameOver, isPaused, tick, score]);
-
-    // Keyboard Controls
+    // Keyboard: 1-3 pick a tray piece, Escape puts it back.
     useEffect(() => {
         const handleKeyDown = (e: KeyboardEvent) => {
-            if (gameOver || isPaused) return;
-            switch (e.key) {
-                case 'ArrowLeft': moveHorizontal(-1); break;
-                case 'ArrowRight': moveHorizontal(1); break;
-                case 'ArrowUp': rotate(); break;
-                case 'ArrowDown': tick(); break;
-                case ' ': hardDrop(); break;
-                case 'Escape': togglePause(); break;
-                case 'p': togglePause(); break;
-            }
+            if (gameOver) return;
+            if (e.key >= '1' && e.key <= '3') selectSlot(Number(e.key) - 1);
+            if (e.key === 'Escape' && selectedSlot !== null) selectSlot(selectedSlot);
         };
         window.addEventListener('keydown', handleKeyDown);
         return () => window.removeEventListener('keydown', handleKeyDown);
-    }, [gameOver, isPaused, moveHorizontal, rotate, tick, hardDrop, togglePause]);
-
-    const renderGrid = () => {
-        const displayGrid = grid.map(row => row.map(cell => cell ? cell.color : null));
-        if (activePiece) {
-            activePiece.matrix.forEach((row, r) => {
-                row.forEach((val, c) => {
-                    if (val !== 0) {
-                        const gridY = activePiece.y + r;
-                        const gridX = activePiece.x + c;
-                        if (gridY >= 0 && gridY < 20 && gridX >= 0 && gridX < 10) {
-                            displayGrid[gridY][gridX] = activePiece.color;
-                        }
-                    }
-                });
-            });
-        }
-        return displayGrid;
-    };
+    }, [gameOver, selectedSlot, selectSlot]);
 
-    const displayGrid = renderGrid();
+    // Cells the selected piece would cover at the hovered cell.
+    const selected = selectedSlot !== null ? tray[selectedSlot] : null;
+    const preview = new Set<string>();
+    const previewFits = !!(selected && hover && fits(grid, selected, hover.row, hover.col));
+    if (selected && hover) {
+        selected.cells.forEach(c => preview.add(`${hover.row + c.row}-${hover.col + c.col}`));
+    }
 
     return (
         <div className="h-[100dvh] w-full flex flex-col bg-background text-foreground overflow-hidden touch-none">
@@ -71,7 +68,7 @@ const BlockBlastGame = () => {
                     </Link>
                     <div>
                         <h1 className="text-xl font-black text-primary leading-tight">Block Blast</h1>
-                        <p className="text-xs text-muted-foreground font-medium">Clear lines!</p>
+                        <p className="text-xs text-muted-foreground font-medium">Fill rows and columns!</p>
                     </div>
                 </div>
 
@@ -81,10 +78,12 @@ const BlockBlastGame = () => {
                         <span className="text-xl font-bold leading-none">{score}</span>
                     </div>
                     <button
-                        onClick={togglePause}
-                        className="p-2 rounded-full bg-secondary text-secondary-foreground hover:bg-secondary/80"
+                        onClick={giveUp}
+                        disabled={gameOver || busy}
+                        title="End game"
+                        className="p-2 rounded-full bg-secondary text-secondary-foreground hover:bg-secondary/80 disabled:opacity-50"
                     >
-                        {isPaused ? <Play size={20} /> : <Pause size={20} />}
+                        <Flag size={20} />
                     </button>
                     <button
                         onClick={resetGame}
@@ -96,113 +95,82 @@ const BlockBlastGame = () => {
             </header>
 
             {/* Game Area: Flexible */}
-            <main className="flex-1 flex items-center justify-center p-2 min-h-0">
-                <div className="relative h-full w-full max-w-sm flex items-center justify-center">
-                    {/* Aspect Ratio Container for Grid (10 / 20 = 0.5) */}
-                    <div className="relative aspect-[10/20] h-full max-h-full border-2 border-muted bg-black/20 rounded-md p-1 shadow-2xl">
-                        <div className="grid grid-cols-10 grid-rows-[20] gap-px w-full h-full bg-muted/10">
-                            {displayGrid.map((row, r) => (
-                                row.map((color, c) => (
+            <main className="flex-1 flex flex-col items-center justify-center gap-4 p-2 min-h-0">
+                <div className="h-5 text-sm font-bold text-primary">
+                    {lastClear > 0 && `${lastClear} ${lastClear === 1 ? 'line' : 'lines'}${combo > 1 ? ` • Combo x${combo}` : ''}`}
+                    {error && <span className="text-destructive">{error}</span>}
+                </div>
+
+                <div className="relative aspect-square w-full max-w-sm border-2 border-muted bg-black/20 rounded-md p-1 shadow-2xl">
+                    <div
+                        className="grid gap-px w-full h-full bg-muted/10"
+                        style={{ gridTemplateColumns: `repeat(${SIZE}, 1fr)`, gridTemplateRows: `repeat(${SIZE}, 1fr)` }}
+                        onMouseLeave={() => setHover(null)}
+                    >
+                        {grid.map((row, r) => (
+                            row.map((filled, c) => {
+                                const inPreview = preview.has(`${r}-${c}`);
+                                return (
                                     <div
                                         key={`${r}-${c}`}
+                                        onMouseEnter={() => setHover({ row: r, col: c })}
+                                        onClick={() => place(r, c)}
                                         className={cn(
-                                            "w-full h-full rounded-[1px]",
-                                            color ? color : "bg-transparent"
+                                            "w-full h-full rounded-[2px] transition-colors",
+                                            filled ? "bg-primary" : "bg-muted/30",
+                                            inPreview && !filled && previewFits && cn(SLOT_COLORS[selectedSlot!], "opacity-60"),
+                                            inPreview && !previewFits && "bg-destructive/50"
                                         )}
                                     />
-                                ))
-                            ))}
-                        </div>
-
-                        {/* Overlays */}
-                        <AnimatePresence>
-                            {(gameOver || isPaused) && (
-                                <motion.div
-                                    initial={{ opacity: 0 }}
-                                    animate={{ opacity: 1 }}
-                                    exit={{ opacity: 0 }}
-                                    className="absolute inset-0 bg-background/80 backdrop-blur-sm z-50 flex flex-col items-center justify-center text-center p-6"
-                                >
-                                    <h2 className="text-3xl font-black mb-2 text-foreground">
-                                        {gameOver ? "Game Over" : "Paused"}
-                                    </h2>
-                                    {gameOver && (
-                                        <>
-                                            <p className="text-lg mb-6 text-muted-foreground">Final Score: {score}</p>
-                                            <button
-                                                onClick={resetGame}
-                                                className="bg-primary text-primary-foreground px-8 py-3 rounded-full font-bold shadow-lg active:scale-95 transition-transform"
-                                            >
-                                                Try Again
-                                            </button>
-                                        </>
-                                    )}
-                                    {isPaused && !gameOver && (
-                                        <button
-                                            onClick={togglePause}
-                                            className="bg-primary text-primary-foreground px-8 py-3 rounded-full font-bold shadow-lg active:scale-95 transition-transform"
-                                        >
-                                            Resume
-                                        </button>
-                                    )}
-                                </motion.div>
-                            )}
-                        </AnimatePresence>
+                                );
+                            })
+                        ))}
                     </div>
-                </div>
-            </main>
-
-            {/* Controls: Compact & Bottom Aligned */}
-            <div className="shrink-0 p-4 pb-8 md:pb-4 w-full max-w-sm mx-auto grid grid-cols-3 gap-3 md:hidden">
-                <div className="col-start-2 flex justify-center">
-                    <button
-                        className="w-14 h-14 bg-muted rounded-full flex items-center justify-center active:bg-muted/70 touch-manipulation shadow-sm"
-                        onClick={() => rotate()}
-                    >
-                        <RotateCw size={24} />
-                    </button>
-                </div>
 
-                <div className="col-start-1 row-start-2 flex justify-center">
-                    <button
-                        className="w-14 h-14 bg-muted rounded-full flex items-center justify-center active:bg-muted/70 touch-manipulation shadow-sm"
-                        onClick={() => moveHorizontal(-1)}
-                    >
-                        <ChevronLeft size={30} />
-                    </button>
-                </div>
-
-                <div className="col-start-2 row-start-2 flex justify-center">
-                    <button
-                        className="w-14 h-14 bg-muted rounded-full flex items-center justify-center active:bg-muted/70 touch-manipulation shadow-sm"
-                        onClick={() => tick()}
-                    >
-                        <ArrowDown size={30} />
-                    </button>
-                </div>
-
-                <div className="col-start-3 row-start-2 flex justify-center">
-                    <button
-                        className="w-14 h-14 bg-muted rounded-full flex items-center justify-center active:bg-muted/70 touch-manipulation shadow-sm"
-                        onClick={() => moveHorizontal(1)}
-                    >
-                        <ChevronRight size={30} />
-                    </button>
+                    {/* Overlays */}
+                    <AnimatePresence>
+                        {gameOver && (
+                            <motion.div
+                                initial={{ opacity: 0 }}
+                                animate={{ opacity: 1 }}
+                                exit={{ opacity: 0 }}
+                                className="absolute inset-0 bg-background/80 backdrop-blur-sm z-50 flex flex-col items-center justify-center text-center p-6"
+                            >
+                                <h2 className="text-3xl font-black mb-2 text-foreground">Game Over</h2>
+                                <p className="text-lg mb-6 text-muted-foreground">Final Score: {score}</p>
+                                <button
+                                    onClick={resetGame}
+                                    className="bg-primary text-primary-foreground px-8 py-3 rounded-full font-bold shadow-lg active:scale-95 transition-transform"
+                                >
+                                    Try Again
+                                </button>
+                            </motion.div>
+                        )}
+                    </AnimatePresence>
                 </div>
 
-                <div className="col-start-2 row-start-3 flex justify-center">
-                    <button
-                        className="w-16 h-16 bg-primary text-primary-foreground rounded-full flex items-center justify-center active:scale-95 shadow-lg touch-manipulation border-4 border-background"
-                        onClick={() => hardDrop()}
-                    >
-                        <ArrowDown size={32} className="animate-bounce" />
-                    </button>
+                {/* Tray */}
+                <div className="w-full max-w-sm grid grid-cols-3 gap-3">
+                    {tray.map((piece, slot) => (
+                        <button
+                            key={slot}
+                            onClick={() => selectSlot(slot)}
+                            disabled={!piece || gameOver}
+                            className={cn(
+                                "h-24 rounded-xl border-2 flex items-center justify-center transition-colors",
+                                selectedSlot === slot ? "border-primary bg-primary/10" : "border-muted bg-muted/20",
+                                !piece && "opacity-30"
+                            )}
+                        >
+                            {piece && <PiecePreview piece={piece} color={SLOT_COLORS[slot]} />}
+                        </button>
+                    ))}
                 </div>
-            </div>
+            </main>
 
             {/* Desktop Hint */}
             <div className="hidden md:block shrink-0 p-4 text-center text-muted-foreground text-sm border-t border-border/50">
-                Arrow Keys to Move/Rotate • Space to Drop • P to Pause
+                Pick a piece (1-3), then click where its top-left corner goes • Esc to put it back
             </div>
         </div>
     );
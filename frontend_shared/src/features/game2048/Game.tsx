@@ -22,7 +22,6 @@ const Game2048 = () => {
 
     useEffect(() => {
         initGame();
-        return () => resetGame(); // Cleanup
     }, []);
 
     // Keyboard controls
@@ -1,5 +1,8 @@
 import { create } from 'zustand';
-import { submit2048Score } from '../../lib/api';
+import { start2048Session, submit2048Score } from '../../lib/api';
+import { mulberry32, type Rng } from '../../lib/rng';
+
+type Direction = 'up' | 'down' | 'left' | 'right';
 
 type Tile = {
     id: number;
@@ -17,26 +20,37 @@ interface Game2048State {
     bestScore: number;
     gameOver: boolean;
     gameWon: boolean;
+    // The server session the moves are submitted to, null until it has
+    // started (or if it could not be, in which case the game is not scored).
+    sessionId: string | null;
+    moves: Direction[];
 
     // Actions
-    move: (direction: 'up' | 'down' | 'left' | 'right') => void;
-    resetGame: () => void;
+    move: (direction: Direction) => void;
+    resetGame: () => Promise<void>;
     cleanup: () => void;
     // Internal helpers
-    initGame: () => void;
+    initGame: () => Promise<void>;
 }
 
 const GRID_SIZE = 4;
 
+// Tiles spawn from the session's seeded generator so the server, replaying
+// the submitted moves, sees the same board. generation discards a session
+// that finishes starting after a newer game was requested.
+let rng: Rng = mulberry32(0);
+let generation = 0;
+
 const getBestScore = () => {
     if (typeof window === 'undefined') return 0;
     return parseInt(localStorage.getItem('2048-best') || '0');
 };
 
+// Empty cells in row-major order, the order the server picks spawns from.
 const getEmptyCells = (grid: Tile[]) => {
     const cells: { x: number, y: number }[] = [];
-    for (let x = 0; x < GRID_SIZE; x++) {
-        for (let y = 0; y < GRID_SIZE; y++) {
+    for (let y = 0; y < GRID_SIZE; y++) {
+        for (let x = 0; x < GRID_SIZE; x++) {
             if (!grid.find(t => t.x === x && t.y === y)) {
                 cells.push({ x, y });
             }
@@ -50,10 +64,10 @@ const spawnTile = (grid: Tile[], count = 1): Tile[] => {
     for (let i = 0; i < count; i++) {
         const empty = getEmptyCells(newGrid);
         if (empty.length === 0) break;
-        const { x, y } = empty[Math.floor(Math.random() * empty.length)];
+        const { x, y } = empty[rng.intn(empty.length)];
         newGrid.push({
             id: Date.now() + Math.random(),
-            value: Math.random() < 0.9 ? 2 : 4,
+            value: rng.float() < 0.9 ? 2 : 4,
             x,
             y,
             isNew: true
@@ -87,21 +101,34 @@ export const useGame2048Store = create<Game2048State>((set, get) => ({
     bestScore: getBestScore(),
     gameOver: false,
     gameWon: false,
+    sessionId: null,
+    moves: [],
+
+    initGame: async () => {
+        const current = ++generation;
+        set({ grid: [], score: 0, gameOver: false, gameWon: false, sessionId: null, moves: [] });
+
+        let sessionId: string | null = null;
+        let seed: number;
+        try {
+            const session = await start2048Session();
+            sessionId = session.session_id;
+            seed = session.seed;
+        } catch (err) {
+            console.error(err);
+            seed = Math.floor(Math.random() * 4294967296);
+        }
+        if (current !== generation) return;
 
-    initGame: () => {
-        set({
-            grid: spawnTile([], 2),
-            score: 0,
-            gameOver: false,
-            gameWon: false
-        });
+        rng = mulberry32(seed);
+        set({ grid: spawnTile([], 2), sessionId });
     },
 
     resetGame: () => get().initGame(),
 
     move: (direction) => {
-        const { grid, score, gameOver } = get();
-        if (gameOver) return;
+        const { grid, score, gameOver, sessionId, moves } = get();
+        if (gameOver || grid.length === 0) return;
 
         // Cleanup any pending destroyed tiles before starting new move
         const cleanGrid = grid.filter(t => !t.toDestroy);
@@ -177,6 +204,7 @@ export const useGame2048Store = create<Game2048State>((set, get) => ({
 
         if (moved) {
             const gridWithNew = spawnTile(newGrid, 1);
+            const newMoves = [...moves, direction];
 
             // Check best score
             const currentBest = get().bestScore;
@@ -188,12 +216,13 @@ export const useGame2048Store = create<Game2048State>((set, get) => ({
             const activeTiles = gridWithNew.filter(t => !t.toDestroy);
             const isGameOver = getEmptyCells(activeTiles).length === 0 && !canMove(activeTiles);
 
-            if (isGameOver) {
-                submit2048Score(newScore).catch(console.error);
+            if (isGameOver && sessionId) {
+                submit2048Score(sessionId, newMoves).catch(console.error);
             }
 
             set({
                 grid: gridWithNew,
+                moves: newMoves,
                 score: newScore,
                 bestScore: Math.max(newScore, currentBest),
                 gameOver: isGameOver
@@ -99,7 +99,7 @@ const SettingsModal: React.FC<SettingsModalProps> = ({ isOpen, onClose }) => {
                                             className="w-full pl-10 pr-4 py-2 rounded-lg border border-gray-200 focus:ring-2 focus:ring-black focus:border-transparent outline-none transition-all"
                                         />
                                     </div>
-                                    <p className="text-xs text-gray-500 mt-1">This hint will be shown if you enter the wrong PIN.</p>
+                                    <p className="text-xs text-gray-500 mt-1">Optional, and only shown to you once signed in. If you forget your PIN, a parent account or an admin can give you a reset code.</p>
                                 </div>
 
                                 {status === 'ERROR' && (
@@ -111,7 +111,7 @@ const Leaderboard = () => {
                     <div className="col-span-2 text-center">Rank</div>
                     <div className="col-span-6">Player</div>
                     <div className="col-span-4 text-right">
-                        {activeTab === 'memory' ? 'Time (Moves)' : activeTab === 'tictactoe' ? 'Wins (Hard)' : 'Score'}
+                        {activeTab === 'memory' ? 'Time (Moves, 4x4)' : activeTab === 'tictactoe' ? 'Wins (PvP)' : 'Score'}
                     </div>
                 </div>
 
@@ -126,7 +126,7 @@ const Leaderboard = () => {
                                 ) : (
                                     memoryScores.map((score, i) => (
                                         <motion.div
-                                            key={score.id}
+                                            key={score.result_id}
                                             initial={{ opacity: 0, x: -20 }}
                                             animate={{ opacity: 1, x: 0 }}
                                             transition={{ delay: i * 0.05 }}
@@ -135,8 +135,8 @@ const Leaderboard = () => {
                                             <div className="col-span-2 flex justify-center">{renderMedal(i)}</div>
                                             <div className="col-span-6 font-bold truncate pr-4">{score.username}</div>
                                             <div className="col-span-4 text-right font-mono flex items-center justify-end gap-2">
-                                                <span className="text-lg">{score.time_seconds}s</span>
-                                                <span className="text-xs text-muted-foreground">({score.moves}m)</span>
+                                                <span className="text-lg">{score.details.time_seconds}s</span>
+                                                <span className="text-xs text-muted-foreground">({score.details.moves}m)</span>
                                             </div>
                                         </motion.div>
                                     ))
@@ -156,7 +156,7 @@ const Leaderboard = () => {
                                             <div className="col-span-2 flex justify-center">{renderMedal(i)}</div>
                                             <div className="col-span-6 font-bold truncate pr-4">{score.username}</div>
                                             <div className="col-span-4 text-right font-mono text-lg text-primary">
-                                                {score.wins} Wins
+                                                {score.score} Wins
                                             </div>
                                         </motion.div>
                                     ))
@@ -167,7 +167,7 @@ const Leaderboard = () => {
                                 ) : (
                                     scores2048.map((score, i) => (
                                         <motion.div
-                                            key={score.id}
+                                            key={score.result_id}
                                             initial={{ opacity: 0, y: 20 }}
                                             animate={{ opacity: 1, y: 0 }}
                                             transition={{ delay: i * 0.05 }}
@@ -187,7 +187,7 @@ const Leaderboard = () => {
                                 ) : (
                                     scoresBlockBlast.map((score, i) => (
                                         <motion.div
-                                            key={score.id}
+                                            key={score.result_id}
                                             initial={{ opacity: 0, y: 20 }}
                                             animate={{ opacity: 1, y: 0 }}
                                             transition={{ delay: i * 0.05 }}
@@ -1,6 +1,6 @@
 import { create } from 'zustand';
-import { type Card, generateDeck } from './utils';
-import { submitMemoryScore } from '../../lib/api';
+import { type Card, faceDownDeck, faceFor } from './utils';
+import { flipMemoryCard, startMemorySession, type MemoryFlip } from '../../lib/api';
 
 export type GridSize = '4x3' | '4x4' | '6x4' | '6x6' | '8x5';
 
@@ -13,15 +13,22 @@ interface MemoryStore {
     isGameWon: boolean;
     flippedCards: Card[];
     gridSize: GridSize;
+    // The deck is dealt and kept by the server; a card's face is only known
+    // once the session reveals it.
+    sessionId: string | null;
+    busy: boolean;
 
     setGridSize: (size: GridSize) => void;
-    startGame: () => void;
+    startGame: () => Promise<void>;
     resetGame: () => void;
-    flipCard: (cardId: string) => void;
+    flipCard: (cardId: string) => Promise<void>;
     checkMatch: () => void;
     incrementTimer: () => void;
 }
 
+// A game is discarded if it was reset while a request was in flight.
+let generation = 0;
+
 export const useMemoryStore = create<MemoryStore>((set, get) => ({
     cards: [],
     moves: 0,
@@ -31,10 +38,12 @@ export const useMemoryStore = create<MemoryStore>((set, get) => ({
     isGameWon: false,
     flippedCards: [],
     gridSize: '4x4',
+    sessionId: null,
+    busy: false,
 
     setGridSize: (gridSize) => set({ gridSize }),
 
-    startGame: () => {
+    startGame: async () => {
         const { gridSize } = get();
         let pairs = 8;
         if (gridSize === '4x3') pairs = 6;
@@ -42,18 +51,31 @@ export const useMemoryStore = create<MemoryStore>((set, get) => ({
         if (gridSize === '6x6') pairs = 18;
         if (gridSize === '8x5') pairs = 20;
 
-        set({
-            cards: generateDeck(pairs),
-            moves: 0,
-            matches: 0,
-            timer: 0,
-            isPlaying: true,
-            isGameWon: false,
-            flippedCards: [],
-        });
+        const current = ++generation;
+        set({ busy: true });
+        try {
+            const session = await startMemorySession(pairs);
+            if (current !== generation) return;
+            set({
+                cards: faceDownDeck(session.cards),
+                moves: 0,
+                matches: 0,
+                timer: 0,
+                isPlaying: true,
+                isGameWon: false,
+                flippedCards: [],
+                sessionId: session.session_id,
+                busy: false,
+            });
+        } catch (err) {
+            if (current !== generation) return;
+            console.error(err);
+            set({ busy: false });
+        }
     },
 
     resetGame: () => {
+        generation++;
         set({
             cards: [],
             moves: 0,
@@ -62,63 +84,67 @@ export const useMemoryStore = create<MemoryStore>((set, get) => ({
             isPlaying: false,
             isGameWon: false,
             flippedCards: [],
+            sessionId: null,
+            busy: false,
         });
     },
 
-    flipCard: (cardId: string) => {
-        const { cards, flippedCards, isPlaying } = get();
-        if (!isPlaying || flippedCards.length >= 2) return;
+    flipCard: async (cardId: string) => {
+        const { cards, flippedCards, isPlaying, sessionId, busy } = get();
+        if (!isPlaying || !sessionId || busy || flippedCards.length >= 2) return;
 
         const cardIndex = cards.findIndex(c => c.id === cardId);
         if (cards[cardIndex].isFlipped || cards[cardIndex].isMatched) return;
 
-        const newCards = cards.map((c, i) =>
-            i === cardIndex ? { ...c, isFlipped: true } : c
-        );
+        const current = generation;
+        set({ busy: true });
+        let res: MemoryFlip;
+        try {
+            res = await flipMemoryCard(sessionId, cardIndex);
+        } catch (err) {
+            if (current !== generation) return;
+            console.error(err);
+            set({ busy: false });
+            return;
+        }
+        if (current !== generation) return;
+
+        const flipped = { ...get().cards[cardIndex], value: faceFor(res.value), isFlipped: true };
+        const matchedIds = res.matched ? [flipped.id, ...flippedCards.map(c => c.id)] : [];
+        const newCards = get().cards.map((c, i) => {
+            const card = i === cardIndex ? flipped : c;
+            return matchedIds.includes(card.id) ? { ...card, isMatched: true } : card;
+        });
+        const newFlipped = res.matched ? [] : [...flippedCards, flipped];
 
         set({
             cards: newCards,
-            flippedCards: [...flippedCards, newCards[cardIndex]],
-            moves: get().moves + 1,
+            flippedCards: newFlipped,
+            moves: res.moves,
+            matches: res.matches,
+            busy: false,
         });
 
-        if (flippedCards.length === 1) {
+        if (res.completed) {
+            set({ isPlaying: false, isGameWon: true, timer: res.score?.time_seconds ?? get().timer });
+        } else if (newFlipped.length === 2) {
             setTimeout(() => get().checkMatch(), 600);
         }
     },
 
+    // checkMatch turns a mismatched pair back face down. The server does the
+    // same on the next flip.
     checkMatch: () => {
-        const { cards, flippedCards, matches, moves, timer } = get();
-        const [first, second] = flippedCards;
-
-        if (first.value === second.value) {
-            // Match!
-            const newCards = cards.map(c =>
-                c.value === first.value ? { ...c, isMatched: true } : c
-            );
-            const newMatches = matches + 1;
-            set({
-                cards: newCards,
-                flippedCards: [],
-                matches: newMatches,
-            });
+        const { cards, flippedCards } = get();
+        if (flippedCards.length < 2) return;
 
-            // Check win
-            if (newMatches === cards.length / 2) {
-                set({ isPlaying: false, isGameWon: true });
-                submitMemoryScore(moves, timer).catch(console.error);
-            }
-
-        } else {
-            // No match
-            const newCards = cards.map(c =>
-                c.id === first.id || c.id === second.id ? { ...c, isFlipped: false } : c
-            );
-            set({
-                cards: newCards,
-                flippedCards: [],
-            });
-        }
+        const ids = flippedCards.map(c => c.id);
+        set({
+            cards: cards.map(c =>
+                ids.includes(c.id) ? { ...c, value: '', isFlipped: false } : c
+            ),
+            flippedCards: [],
+        });
     },
 
     incrementTimer: () => set(state => ({ timer: state.timer + 1 })),
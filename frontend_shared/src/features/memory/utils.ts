@@ -13,15 +13,13 @@ const EMOJIS = [
     '🐬', '🐳', '🐋', '🦈', '🐊', '🐅', '🐆', '🦓', '🦍', '🦧', '🦣', '🐘'
 ];
 
-export const generateDeck = (pairs: number = 8): Card[] => {
-    const selectedEmojis = EMOJIS.slice(0, pairs);
-    const deck = [...selectedEmojis, ...selectedEmojis]
-        .sort(() => Math.random() - 0.5)
-        .map((emoji, index) => ({
-            id: `card-${index}-${emoji}`,
-            value: emoji,
-            isFlipped: false,
-            isMatched: false,
-        }));
-    return deck;
-};
+// The server deals faces as pair IDs 0..pairs-1; each ID shows as an emoji.
+export const faceFor = (value: number) => EMOJIS[value % EMOJIS.length];
+
+export const faceDownDeck = (count: number): Card[] =>
+    Array.from({ length: count }, (_, index) => ({
+        id: `card-${index}`,
+        value: '',
+        isFlipped: false,
+        isMatched: false,
+    }));
@@ -1,8 +1,8 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import { RefreshCw, Cpu, Users } from 'lucide-react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { cn } from '../../lib/utils';
-import { getTicTacToeMove, saveTicTacToeMatch } from '../../lib/api';
+import { createTicTacToeMatch, getTicTacToeMatch, playTicTacToeMove, type TicTacToeMatch } from '../../lib/api';
 
 interface SquareProps {
     value: string;
@@ -50,6 +50,23 @@ const Square = ({ value, onClick, isWinning, isFading, isLatest, disabled }: Squ
     );
 };
 
+const DIFFICULTY = 'hard';
+
+const checkWinner = (squares: string[]) => {
+    const lines = [
+        [0, 1, 2], [3, 4, 5], [6, 7, 8],
+        [0, 3, 6], [1, 4, 7], [2, 5, 8],
+        [0, 4, 8], [2, 4, 6],
+    ];
+    for (let i = 0; i < lines.length; i++) {
+        const [a, b, c] = lines[i];
+        if (squares[a] && squares[a] === squares[b] && squares[a] === squares[c]) {
+            return { winner: squares[a], line: lines[i] };
+        }
+    }
+    return null;
+};
+
 const TicTacToeGame = () => {
     const [board, setBoard] = useState<string[]>(Array(9).fill(''));
     const [isHumanNext, setIsHumanNext] = useState(true);
@@ -59,128 +76,113 @@ const TicTacToeGame = () => {
     const [xQueue, setXQueue] = useState<number[]>([]); // Tracks X moves
     const [oQueue, setOQueue] = useState<number[]>([]); // Tracks O moves
     const [isTwoPlayer, setIsTwoPlayer] = useState(false);
-    const [movesCount, setMovesCount] = useState(0);
-
-    const checkWinner = (squares: string[]) => {
-        const lines = [
-            [0, 1, 2], [3, 4, 5], [6, 7, 8],
-            [0, 3, 6], [1, 4, 7], [2, 5, 8],
-            [0, 4, 8], [2, 4, 6],
-        ];
-        for (let i = 0; i < lines.length; i++) {
-            const [a, b, c] = lines[i];
-            if (squares[a] && squares[a] === squares[b] && squares[a] === squares[c]) {
-                return { winner: squares[a], line: lines[i] };
-            }
-        }
-        return null;
-    };
+    // Games against the AI are played on the server, which also records the
+    // result; local two-player games are not recorded.
+    const [matchId, setMatchId] = useState<string | null>(null);
+    const game = useRef(0);
 
-    const handleClick = (i: number) => {
-        if (board[i] || winner || loading) return;
-        if (!isTwoPlayer && !isHumanNext) return;
-
-        const currentPlayer = isHumanNext ? 'X' : 'O';
+    // Places a piece for player, removing their oldest one past three.
+    const placePiece = (i: number, player: 'X' | 'O') => {
         const newBoard = [...board];
-        let newXQueue = [...xQueue];
-        let newOQueue = [...oQueue];
-
-        if (currentPlayer === 'X') {
-            if (newXQueue.length >= 3) {
-                const remove = newXQueue.shift();
-                if (remove !== undefined) newBoard[remove] = '';
-            }
-            newXQueue.push(i);
-            setXQueue(newXQueue);
-        } else {
-            if (newOQueue.length >= 3) {
-                const remove = newOQueue.shift();
-                if (remove !== undefined) newBoard[remove] = '';
-            }
-            newOQueue.push(i);
-            setOQueue(newOQueue);
+        const queue = [...(player === 'X' ? xQueue : oQueue)];
+        if (queue.length >= 3) {
+            const remove = queue.shift();
+            if (remove !== undefined) newBoard[remove] = '';
         }
+        queue.push(i);
+        newBoard[i] = player;
 
-        newBoard[i] = currentPlayer;
         setBoard(newBoard);
-        setMovesCount(prev => prev + 1);
+        if (player === 'X') setXQueue(queue); else setOQueue(queue);
+        return newBoard;
+    };
 
-        const result = checkWinner(newBoard);
-        if (result) {
-            handleGameOver(result.winner, result.line);
+    const applyMatch = (match: TicTacToeMatch) => {
+        const { board, xQueue, oQueue, winner, over } = match.game;
+        setBoard(board);
+        setXQueue(xQueue);
+        setOQueue(oQueue);
+        if (over) {
+            setWinner(winner || 'Draw');
+            setWinningLine(checkWinner(board)?.line ?? null);
         } else {
-            setIsHumanNext(!isHumanNext);
+            setIsHumanNext(true);
         }
     };
 
-    const handleGameOver = (winner: string, line: number[]) => {
-        setWinner(winner);
-        setWinningLine(line);
-        // Save match
-        saveTicTacToeMatch(
-            isTwoPlayer ? 'pvp' : 'hard', // Assuming AI is hard
-            winner === 'X' ? 'win' : winner === 'O' ? 'loss' : 'draw',
-            movesCount // This is just local count, simpler than tracking exact moves count from server
-        ).catch(console.error);
-    };
-
-    // AI Turn
-    useEffect(() => {
-        if (!isTwoPlayer && !isHumanNext && !winner && !loading) {
-            const makeAiMove = async () => {
-                setLoading(true);
-                try {
-                    const { index } = await getTicTacToeMove(board, xQueue, oQueue);
-                    if (index !== -1) {
-                        const newBoard = [...board];
-                        let newOQueue = [...oQueue];
-
-                        if (newOQueue.length >= 3) {
-                            const remove = newOQueue.shift();
-                            if (remove !== undefined) newBoard[remove] = '';
-                        }
-
-                        newBoard[index] = 'O';
-                        newOQueue.push(index);
+    const handleClick = async (i: number) => {
+        if (board[i] || winner || loading) return;
+        if (!isTwoPlayer && !isHumanNext) return;
 
-                        setBoard(newBoard);
-                        setOQueue(newOQueue);
-                        setMovesCount(prev => prev + 1);
+        if (isTwoPlayer) {
+            const newBoard = placePiece(i, isHumanNext ? 'X' : 'O');
+            const result = checkWinner(newBoard);
+            if (result) {
+                setWinner(result.winner);
+                setWinningLine(result.line);
+            } else {
+                setIsHumanNext(!isHumanNext);
+            }
+            return;
+        }
 
-                        const result = checkWinner(newBoard);
-                        if (result) {
-                            handleGameOver(result.winner, result.line);
-                        } else {
-                            setIsHumanNext(true);
-                        }
-                    }
-                } catch (e) {
-                    console.error(e);
-                } finally {
-                    setLoading(false);
-                }
-            };
-            makeAiMove();
+        if (!matchId) return;
+        const current = game.current;
+        // Show the move straight away; the server's answer includes the AI's.
+        placePiece(i, 'X');
+        setIsHumanNext(false);
+        setLoading(true);
+        try {
+            const match = await playTicTacToeMove(matchId, i);
+            if (current === game.current) applyMatch(match);
+        } catch (e) {
+            console.error(e);
+            // Put the board back the way the server has it.
+            getTicTacToeMatch(matchId)
+                .then(match => {
+                    if (current === game.current) applyMatch(match);
+                })
+                .catch(console.error);
+        } finally {
+            if (current === game.current) setLoading(false);
         }
-    }, [isHumanNext, winner, isTwoPlayer]);
+    };
 
     const resetGame = () => {
+        const current = ++game.current;
         setBoard(Array(9).fill(''));
         setIsHumanNext(true);
         setWinner(null);
         setWinningLine(null);
         setXQueue([]);
         setOQueue([]);
-        setMovesCount(0);
+        setMatchId(null);
+        setLoading(false);
+        if (isTwoPlayer) return;
+
+        setLoading(true);
+        createTicTacToeMatch(DIFFICULTY)
+            .then(match => {
+                if (current === game.current) setMatchId(match.match_id);
+            })
+            .catch(console.error)
+            .finally(() => {
+                if (current === game.current) setLoading(false);
+            });
     };
 
+    // Start a match whenever the mode changes (and on first render).
+    useEffect(() => {
+        resetGame();
+    }, [isTwoPlayer]);
+
     return (
         <div className="min-h-screen flex flex-col items-center bg-background text-foreground p-4">
             {/* Header (Replaced by Layout) */}
             <div className="w-full max-w-lg flex items-center justify-between mb-12">
                 <div className="flex gap-2 bg-card p-1 rounded-lg border border-border">
                     <button
-                        onClick={() => { setIsTwoPlayer(false); resetGame(); }}
+                        onClick={() => setIsTwoPlayer(false)}
                         className={cn(
                             "flex items-center gap-2 px-3 py-1.5 rounded-md text-sm font-medium transition-all",
                             !isTwoPlayer ? "bg-primary text-primary-foreground shadow-sm" : "hover:bg-accent hover:text-accent-foreground"
@@ -189,7 +191,7 @@ const TicTacToeGame = () => {
                         <Cpu size={16} /> AI
                     </button>
                     <button
-                        onClick={() => { setIsTwoPlayer(true); resetGame(); }}
+                        onClick={() => setIsTwoPlayer(true)}
                         className={cn(
                             "flex items-center gap-2 px-3 py-1.5 rounded-md text-sm font-medium transition-all",
                             isTwoPlayer ? "bg-primary text-primary-foreground shadow-sm" : "hover:bg-accent hover:text-accent-foreground"
@@ -1,12 +1,89 @@
 const BASE = import.meta.env.VITE_API_BASE || '/api/v1';
 
-export async function api<T>(path: string, init: RequestInit = {}): Promise<T> {
-    const token = localStorage.getItem("token");
+// Access tokens last minutes; the refresh token trades them in for a new
+// pair at /auth/refresh and is itself replaced every time it is used.
+export const storeTokens = (token: string, refreshToken?: string) => {
+    localStorage.setItem("token", token);
+    if (refreshToken) localStorage.setItem("refresh_token", refreshToken);
+};
+
+export const clearTokens = () => {
+    localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
+};
+
+// The server revokes a session whose refresh token is presented twice, so
+// requests that fail together share one refresh.
+let refreshing: Promise<boolean> | null = null;
+
+const refreshTokens = () => {
+    if (!refreshing) {
+        refreshing = (async () => {
+            const refreshToken = localStorage.getItem("refresh_token");
+            if (!refreshToken) return false;
+            try {
+                const res = await fetch(`${BASE}/auth/refresh`, {
+                    method: "POST",
+                    headers: { "Content-Type": "application/json" },
+                    body: JSON.stringify({ refresh_token: refreshToken })
+                });
+                if (!res.ok) {
+                    // Another tab may have refreshed with this token already.
+                    if (localStorage.getItem("refresh_token") !== refreshToken) return true;
+                    clearTokens();
+                    return false;
+                }
+                const tokens: { token: string, refresh_token: string } = await res.json();
+                storeTokens(tokens.token, tokens.refresh_token);
+                return true;
+            } catch (err) {
+                console.error(err);
+                return false;
+            }
+        })().finally(() => {
+            refreshing = null;
+        });
+    }
+    return refreshing;
+};
+
+// expiresSoon reads the access token's exp claim, so it can be renewed
+// before the server turns it away.
+const expiresSoon = (token: string) => {
+    try {
+        const payload = token.split(".")[1].replace(/-/g, "+").replace(/_/g, "/");
+        const { exp } = JSON.parse(atob(payload));
+        return typeof exp === "number" && exp * 1000 < Date.now() + 30_000;
+    } catch {
+        return false;
+    }
+};
+
+const send = (path: string, init: RequestInit, token: string | null) => {
     const headers = new Headers(init.headers);
     headers.set("Content-Type", "application/json");
     if (token) headers.set("Authorization", `Bearer ${token}`);
+    return fetch(`${BASE}${path}`, { ...init, headers });
+};
+
+export async function api<T>(path: string, init: RequestInit = {}): Promise<T> {
+    let token = localStorage.getItem("token");
+    if (token && expiresSoon(token)) {
+        await refreshTokens();
+        token = localStorage.getItem("token");
+    }
+    let res = await send(path, init, token);
+
+    // The token expired in flight or was replaced meanwhile: renew it and
+    // retry once. Other 401s, such as a wrong PIN, are not retried.
+    if (res.status === 401 && token) {
+        const latest = localStorage.getItem("token");
+        const renewed = latest !== token || (expiresSoon(token) && await refreshTokens());
+        if (renewed) {
+            res = await send(path, init, localStorage.getItem("token"));
+        }
+    }
 
-    const res = await fetch(`${BASE}${path}`, { ...init, headers });
     if (!res.ok) {
         const text = await res.text();
         throw new Error(text || res.statusText);
@@ -14,15 +91,18 @@ export async function api<T>(path: string, init: RequestInit = {}): Promise<T> {
     return res.json();
 }
 
-// Auth
 // Auth
 export const login = async (username: string, pin?: string, hint?: string) => {
-    return api<{ user: any, token?: string, status: string }>("/users", {
+    return api<{ user: any, token?: string, refresh_token?: string, expires_in?: number, status: string }>("/users", {
         method: "POST",
         body: JSON.stringify({ username, pin, hint })
     });
 };
 
+export const logout = async () => {
+    return api<{ status: string }>("/logout", { method: "POST" });
+};
+
 export const updatePin = async (pin: string, hint: string) => {
     return api<{ status: string }>("/users/pin", {
         method: "PUT",
@@ -35,15 +115,43 @@ export const getMe = async () => {
 };
 
 // Memory
-export const submitMemoryScore = async (moves: number, timeSeconds: number) => {
-    return api("/scores", {
+export type MemorySession = {
+    session_id: string;
+    status: string;
+    cards: number;
+    revealed: Record<string, number>;
+    moves: number;
+    matches: number;
+};
+
+export type MemoryFlip = {
+    index: number;
+    value: number;
+    matched: boolean;
+    moves: number;
+    matches: number;
+    completed: boolean;
+    score?: { moves: number, time_seconds: number, suspicious: boolean };
+};
+
+export const startMemorySession = async (pairs: number) => {
+    return api<MemorySession>("/memory/sessions", {
         method: "POST",
-        body: JSON.stringify({ moves, time_seconds: timeSeconds })
+        body: JSON.stringify({ pairs })
     });
 };
 
-export const getMemoryLeaderboard = async (limit = 10) => {
-    return api<any[]>(`/leaderboard?limit=${limit}`);
+export const flipMemoryCard = async (sessionId: string, index: number) => {
+    return api<MemoryFlip>(`/memory/sessions/${sessionId}/flips`, {
+        method: "POST",
+        body: JSON.stringify({ index })
+    });
+};
+
+// Each board size has its own leaderboard; the default is 4x4 (8 pairs).
+export const getMemoryLeaderboard = async (limit = 10, pairs = 8) => {
+    const page = await api<{ entries: any[] }>(`/games/memory/leaderboard?variant=${pairs}&limit=${limit}`);
+    return page.entries;
 };
 
 // TicTacToe
@@ -54,10 +162,38 @@ export const getTicTacToeMove = async (board: string[], xQueue: number[], oQueue
     });
 };
 
-export const saveTicTacToeMatch = async (difficulty: string, result: string, moves: number) => {
-    return api("/matches", {
+export type TicTacToeMatch = {
+    match_id: string;
+    difficulty: string;
+    status: string;
+    game: {
+        board: string[];
+        xQueue: number[];
+        oQueue: number[];
+        turn: string;
+        winner?: string;
+        over: boolean;
+        moves: number[];
+    };
+    ai_move?: number;
+    result?: string;
+};
+
+export const createTicTacToeMatch = async (difficulty: string) => {
+    return api<TicTacToeMatch>("/matches", {
         method: "POST",
-        body: JSON.stringify({ difficulty, result, moves })
+        body: JSON.stringify({ difficulty })
+    });
+};
+
+export const getTicTacToeMatch = async (matchId: string) => {
+    return api<TicTacToeMatch>(`/matches/${matchId}`);
+};
+
+export const playTicTacToeMove = async (matchId: string, index: number) => {
+    return api<TicTacToeMatch>(`/matches/${matchId}/moves`, {
+        method: "POST",
+        body: JSON.stringify({ index })
     });
 };
 
@@ -66,29 +202,77 @@ export const getTicTacToeStats = async () => {
 };
 
 export const getTicTacToeLeaderboard = async (limit = 10) => {
-    return api<any[]>(`/leaderboard/tictactoe?limit=${limit}`);
+    const page = await api<{ entries: any[] }>(`/games/tictactoe/leaderboard?limit=${limit}`);
+    return page.entries;
 };
 
 // 2048
-export const submit2048Score = async (score: number) => {
+export type Game2048Session = {
+    session_id: string;
+    seed: number;
+    board: number[][];
+};
+
+export const start2048Session = async () => {
+    return api<Game2048Session>("/2048/sessions", { method: "POST" });
+};
+
+export const submit2048Score = async (sessionId: string, moves: string[]) => {
     return api("/2048/scores", {
         method: "POST",
-        body: JSON.stringify({ score })
+        body: JSON.stringify({ session_id: sessionId, moves })
     });
 };
 
 export const get2048Leaderboard = async (limit = 10) => {
-    return api<any[]>(`/leaderboard/2048?limit=${limit}`);
+    const page = await api<{ entries: any[] }>(`/games/2048/leaderboard?limit=${limit}`);
+    return page.entries;
 };
 
 // Block Blast
-export const submitBlockBlastScore = async (score: number) => {
+export type BlockBlastPiece = {
+    id: string;
+    cells: { row: number, col: number }[];
+};
+
+export type BlockBlastState = {
+    session_id: string;
+    status: string;
+    game: {
+        grid: boolean[][];
+        tray: (BlockBlastPiece | null)[];
+        score: number;
+        combo: number;
+        placements: number;
+        game_over: boolean;
+    };
+    last?: { points: number, lines_cleared: number };
+};
+
+export const startBlockBlastSession = async () => {
+    return api<BlockBlastState>("/blockblast/sessions", { method: "POST" });
+};
+
+export const getBlockBlastSession = async (sessionId: string) => {
+    return api<BlockBlastState>(`/blockblast/sessions/${sessionId}`);
+};
+
+export const placeBlockBlastPiece = async (sessionId: string, slot: number, row: number, col: number) => {
+    return api<BlockBlastState>(`/blockblast/sessions/${sessionId}/placements`, {
+        method: "POST",
+        body: JSON.stringify({ slot, row, col })
+    });
+};
+
+// Ends a session before the board fills up and records the score so far.
+export const submitBlockBlastScore = async (sessionId: string) => {
     return api("/blockblast/scores", {
         method: "POST",
-        body: JSON.stringify({ score })
+        body: JSON.stringify({ session_id: sessionId })
     });
 };
 
 export const getLeaderboardBlockBlast = async (limit = 10) => {
-    return api<any[]>(`/leaderboard/blockblast?limit=${limit}`);
+    const page = await api<{ entries: any[] }>(`/games/blockblast/leaderboard?limit=${limit}`);
+    return page.entries;
 };
@@ -0,0 +1,18 @@
+// Mulberry32, the same generator the server seeds its games with. Given the
+// session seed it yields the exact sequence the server replays, so tiles,
+// pieces and cards spawned here match what the server checks.
+export function mulberry32(seed: number) {
+    let state = seed >>> 0;
+    const next = () => {
+        state = (state + 0x6D2B79F5) | 0;
+        let t = Math.imul(state ^ (state >>> 15), state | 1);
+        t = (t + Math.imul(t ^ (t >>> 7), t | 61)) ^ t;
+        return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+    };
+    return {
+        float: next,
+        intn: (n: number) => (n <= 0 ? 0 : Math.floor(next() * n)),
+    };
+}
+
+export type Rng = ReturnType<typeof mulberry32>;
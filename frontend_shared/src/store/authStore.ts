@@ -1,5 +1,5 @@
 import { create } from 'zustand';
-import { login, getMe } from '../lib/api';
+import { login, logout as endSession, getMe, storeTokens, clearTokens } from '../lib/api';
 
 interface User {
     id: string;
@@ -11,7 +11,7 @@ interface AuthState {
     token: string | null;
     isLoading: boolean;
     login: (username: string, pin?: string, hint?: string) => Promise<{ status: string, user?: any }>;
-    logout: () => void;
+    logout: () => Promise<void>;
     checkAuth: () => Promise<void>;
 }
 
@@ -22,13 +22,15 @@ export const useAuthStore = create<AuthState>((set) => ({
     login: async (username: string, pin?: string, hint?: string) => {
         const response = await login(username, pin, hint);
         if (response.token) {
-            localStorage.setItem('token', response.token);
+            storeTokens(response.token, response.refresh_token);
             set({ user: response.user, token: response.token });
         }
         return response;
     },
-    logout: () => {
-        localStorage.removeItem('token');
+    logout: async () => {
+        // Revoke the session on the server too, so its refresh token dies.
+        await endSession().catch(console.error);
+        clearTokens();
         set({ user: null, token: null });
     },
     checkAuth: async () => {
@@ -41,7 +43,7 @@ export const useAuthStore = create<AuthState>((set) => ({
             const user = await getMe();
             set({ user });
         } catch (error) {
-            localStorage.removeItem('token');
+            clearTokens();
             set({ user: null, token: null });
         } finally {
             set({ isLoading: false });